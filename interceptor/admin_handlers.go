@@ -2,24 +2,60 @@ package main
 
 import (
 	"log"
+	"time"
 
 	"github.com/kedacore/http-add-on/pkg/http"
 	echo "github.com/labstack/echo/v4"
 )
 
-// newForwardingHandler takes in the service URL for the app backend
-// and forwards incoming requests to it. Note that it isn't multitenant.
-// It's intended to be deployed and scaled alongside the application itself
+// newQueueSizeHandler returns a handler that responds with the total
+// size of the queue, across all hosts and routes
 func newQueueSizeHandler(q http.QueueCountReader) echo.HandlerFunc {
 	return func(c echo.Context) error {
-		cur, err := q.Current()
+		counts, err := q.Snapshot()
 		if err != nil {
 			log.Printf("Error getting queue size (%s)", err)
 			c.Error(err)
 			return err
 		}
 		return c.JSON(200, map[string]int{
-			"current_size": cur,
+			"current_size": http.Total(counts),
 		})
 	}
 }
+
+// newQueueCountsHandler returns a handler that responds with the size of
+// the queue for each host and route that has requests pending
+func newQueueCountsHandler(q http.QueueCountReader) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		counts, err := q.Snapshot()
+		if err != nil {
+			log.Printf("Error getting queue counts (%s)", err)
+			c.Error(err)
+			return err
+		}
+		return c.JSON(200, map[string]map[string]int{
+			"counts": counts,
+		})
+	}
+}
+
+// staleChecker reports whether a cache may not reflect the current state
+// of the cluster. *k8s.K8sDeploymentCache is one
+type staleChecker interface {
+	Stale(maxAge time.Duration) bool
+}
+
+// newHealthHandler returns a handler that fails while cache is stale,
+// going by maxAge. The interceptor can't tell when the apps it waits on
+// have replicas while its deployment cache is stale, so it shouldn't get
+// requests until the cache catches up
+func newHealthHandler(cache staleChecker, maxAge time.Duration) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if cache.Stale(maxAge) {
+			log.Printf("Deployment cache hasn't heard from the API server in %s", maxAge)
+			return c.String(503, "deployment cache is stale")
+		}
+		return c.String(200, "OK")
+	}
+}
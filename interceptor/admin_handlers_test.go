@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -11,8 +12,11 @@ import (
 func TestQueueSizeHandlerSuccess(t *testing.T) {
 	r := require.New(t)
 	reader := &fakeQueueCountReader{
-		current: 123,
-		err:     nil,
+		counts: map[string]int{
+			"host1.com":     100,
+			"host2.com/api": 23,
+		},
+		err: nil,
 	}
 
 	handler := newQueueSizeHandler(reader)
@@ -26,7 +30,7 @@ func TestQueueSizeHandlerSuccess(t *testing.T) {
 	r.Equalf(1, len(respMap), "response JSON length was not 1")
 	sizeVal, ok := respMap["current_size"]
 	r.Truef(ok, "'current_size' entry not available in return JSON")
-	r.Equalf(reader.current, sizeVal, "returned JSON queue size was wrong")
+	r.Equalf(123, sizeVal, "returned JSON queue size was wrong")
 	reader.err = errors.New("test error")
 	r.Error(handler(echoCtx))
 }
@@ -34,8 +38,8 @@ func TestQueueSizeHandlerSuccess(t *testing.T) {
 func TestQueueSizeHandlerFail(t *testing.T) {
 	r := require.New(t)
 	reader := &fakeQueueCountReader{
-		current: 0,
-		err:     errors.New("test error"),
+		counts: nil,
+		err:    errors.New("test error"),
 	}
 
 	handler := newQueueSizeHandler(reader)
@@ -44,3 +48,63 @@ func TestQueueSizeHandlerFail(t *testing.T) {
 	r.Error(err)
 	r.Equal(500, rec.Code, "response code")
 }
+
+func TestQueueCountsHandlerSuccess(t *testing.T) {
+	r := require.New(t)
+	reader := &fakeQueueCountReader{
+		counts: map[string]int{
+			"host1.com":     100,
+			"host2.com/api": 23,
+		},
+		err: nil,
+	}
+
+	handler := newQueueCountsHandler(reader)
+	_, echoCtx, rec := newTestCtx("GET", "/queue_counts")
+	err := handler(echoCtx)
+	r.NoError(err)
+	r.Equal(200, rec.Code, "response code")
+	respMap := map[string]map[string]int{}
+	decodeErr := json.NewDecoder(rec.Body).Decode(&respMap)
+	r.NoError(decodeErr)
+	counts, ok := respMap["counts"]
+	r.Truef(ok, "'counts' entry not available in return JSON")
+	r.Equalf(reader.counts, counts, "returned JSON queue counts were wrong")
+}
+
+func TestQueueCountsHandlerFail(t *testing.T) {
+	r := require.New(t)
+	reader := &fakeQueueCountReader{
+		counts: nil,
+		err:    errors.New("test error"),
+	}
+
+	handler := newQueueCountsHandler(reader)
+	_, echoCtx, rec := newTestCtx("GET", "/queue_counts")
+	err := handler(echoCtx)
+	r.Error(err)
+	r.Equal(500, rec.Code, "response code")
+}
+
+// fakeStaleChecker is a staleChecker that's stale for any maxAge up to
+// staleAfter
+type fakeStaleChecker struct {
+	staleAfter time.Duration
+}
+
+func (f fakeStaleChecker) Stale(maxAge time.Duration) bool {
+	return maxAge <= f.staleAfter
+}
+
+func TestHealthHandlerFailsWhileCacheIsStale(t *testing.T) {
+	r := require.New(t)
+	cache := fakeStaleChecker{staleAfter: time.Minute}
+
+	_, echoCtx, rec := newTestCtx("GET", "/healthz")
+	r.NoError(newHealthHandler(cache, 2*time.Minute)(echoCtx))
+	r.Equal(200, rec.Code, "response code")
+
+	_, echoCtx, rec = newTestCtx("GET", "/healthz")
+	r.NoError(newHealthHandler(cache, time.Minute)(echoCtx))
+	r.Equal(503, rec.Code, "response code")
+}
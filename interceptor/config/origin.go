@@ -1,34 +1,54 @@
 package config
 
 import (
-	"fmt"
-	"net/url"
-
+	"github.com/kedacore/http-add-on/pkg/routing"
 	"github.com/kelseyhightower/envconfig"
 )
 
 // Origin is the configuration for where and how the proxy forwards
-// requests to a backing Kubernetes service
+// requests to a backing Kubernetes service when the routing table
+// has no entry for the incoming request's host.
+//
+// AppServiceName, AppServicePort and the target fields are optional.
+// If they're all omitted, the interceptor only forwards requests whose
+// host is in the routing table
 type Origin struct {
 	// AppServiceName is the name of the service that fronts the user's app
-	AppServiceName string `envconfig:"KEDA_HTTP_APP_SERVICE_NAME" required:"true"`
+	AppServiceName string `envconfig:"KEDA_HTTP_APP_SERVICE_NAME"`
 	// AppServiecPort the port that that the proxy should forward to
-	AppServicePort string `envconfig:"KEDA_HTTP_APP_SERVICE_PORT" required:"true"`
+	AppServicePort int `envconfig:"KEDA_HTTP_APP_SERVICE_PORT"`
 	// TargetDeploymentName is the name of the backing deployment that the interceptor
 	// should forward to
-	TargetDeploymentName string `envconfig:"KEDA_HTTP_TARGET_DEPLOYMENT_NAME" required:"true"`
+	TargetDeploymentName string `envconfig:"KEDA_HTTP_TARGET_DEPLOYMENT_NAME"`
+	// TargetAPIVersion, TargetKind and TargetName, if TargetName is set,
+	// identify the resource that backs the app service. Use them instead
+	// of TargetDeploymentName for resources other than Deployments
+	TargetAPIVersion string `envconfig:"KEDA_HTTP_TARGET_API_VERSION" default:"apps/v1"`
+	TargetKind       string `envconfig:"KEDA_HTTP_TARGET_KIND" default:"Deployment"`
+	TargetName       string `envconfig:"KEDA_HTTP_TARGET_NAME"`
 	// Namespace is the namespace that this interceptor is running in
 	Namespace string `envconfig:"KEDA_HTTP_NAMESPACE" required:"true"`
 }
 
-// ServiceURL formats the app service name and port into a URL
-func (o *Origin) ServiceURL() (*url.URL, error) {
-	urlStr := fmt.Sprintf("http://%s:%s", o.AppServiceName, o.AppServicePort)
-	u, err := url.Parse(urlStr)
-	if err != nil {
-		return nil, err
+// DefaultTarget returns the routing target that this origin describes,
+// and true. If no app service was configured, returns false
+func (o *Origin) DefaultTarget() (routing.Target, bool) {
+	if o.AppServiceName == "" {
+		return routing.Target{}, false
+	}
+	target := routing.NewTarget(
+		o.AppServiceName,
+		o.AppServicePort,
+		o.TargetDeploymentName,
+	)
+	if o.TargetName != "" {
+		target.ScaleTargetRef = &routing.ScaleTargetRef{
+			APIVersion: o.TargetAPIVersion,
+			Kind:       o.TargetKind,
+			Name:       o.TargetName,
+		}
 	}
-	return u, nil
+	return target, true
 }
 
 func MustParseOrigin() *Origin {
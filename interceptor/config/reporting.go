@@ -0,0 +1,35 @@
+package config
+
+import (
+	"time"
+
+	"github.com/kelseyhightower/envconfig"
+)
+
+// Reporting is the configuration for how the interceptor pushes its
+// pending request counts to the external scaler
+type Reporting struct {
+	// ScalerAddress is the host:port of the external scaler's gRPC
+	// server. If it's empty, the interceptor doesn't push its counts and
+	// the scaler has to poll for them instead
+	ScalerAddress string `envconfig:"KEDA_HTTP_SCALER_ADDRESS"`
+	// InterceptorID identifies this interceptor to the scaler. It must be
+	// the IP of this interceptor's pod, so that the scaler knows not to
+	// poll this interceptor while it's pushing counts
+	InterceptorID string `envconfig:"KEDA_HTTP_INTERCEPTOR_POD_IP"`
+	// ReconnectInterval is how long to wait before reconnecting to the
+	// scaler after the connection to it fails
+	ReconnectInterval time.Duration `envconfig:"KEDA_HTTP_SCALER_RECONNECT_INTERVAL" default:"1s"`
+	// SnapshotInterval is how often the interceptor sends the scaler a
+	// full snapshot of its counts, in between the changes it sends, so
+	// that the scaler's counts can't drift from the interceptor's
+	SnapshotInterval time.Duration `envconfig:"KEDA_HTTP_SCALER_SNAPSHOT_INTERVAL" default:"30s"`
+}
+
+// MustParseReporting parses reporting configs using envconfig and
+// returns a pointer to the newly created config. Panics if parsing failed
+func MustParseReporting() *Reporting {
+	ret := new(Reporting)
+	envconfig.MustProcess("", ret)
+	return ret
+}
@@ -0,0 +1,31 @@
+package config
+
+import (
+	"time"
+
+	"github.com/kelseyhightower/envconfig"
+)
+
+// Routing is the configuration for how the interceptor builds
+// its routing table
+type Routing struct {
+	// Table is a JSON-encoded routing table to start with, in the format
+	// that routing.Table's UnmarshalJSON accepts. Its host entries are
+	// replaced the first time the ConfigMap below is read, if there is one
+	Table string `envconfig:"KEDA_HTTP_ROUTING_TABLE"`
+	// ConfigMapName is the name of the ConfigMap, in the interceptor's
+	// namespace, that holds the routing table. If it's empty, the routing
+	// table only has the default target from the Origin config in it
+	ConfigMapName string `envconfig:"KEDA_HTTP_ROUTING_TABLE_CONFIG_MAP"`
+	// UpdateInterval is how often the interceptor re-reads the routing
+	// table ConfigMap
+	UpdateInterval time.Duration `envconfig:"KEDA_HTTP_ROUTING_TABLE_UPDATE_INTERVAL" default:"1s"`
+}
+
+// MustParseRouting parses routing configs using envconfig and returns a
+// pointer to the newly created config. Panics if parsing failed
+func MustParseRouting() *Routing {
+	ret := new(Routing)
+	envconfig.MustProcess("", ret)
+	return ret
+}
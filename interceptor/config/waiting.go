@@ -0,0 +1,64 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kelseyhightower/envconfig"
+)
+
+const (
+	// WaitStrategyReplicas makes the interceptor forward requests as soon
+	// as the target deployment asks for at least one replica
+	WaitStrategyReplicas = "replicas"
+	// WaitStrategyReady makes the interceptor forward requests only once
+	// the target deployment has at least one ready replica
+	WaitStrategyReady = "ready"
+)
+
+// Waiting is the configuration for how the interceptor waits for the
+// target deployment to be able to serve requests before forwarding
+// requests to it
+type Waiting struct {
+	// Strategy is what the interceptor waits for. It must be one of
+	// WaitStrategyReplicas or WaitStrategyReady
+	Strategy string `envconfig:"KEDA_HTTP_WAIT_STRATEGY" default:"replicas"`
+	// DeploymentCacheMaxAge is how long the deployment cache can go
+	// without hearing from the API server before the interceptor reports
+	// that it isn't ready, since it may be waiting on out of date
+	// deployments
+	DeploymentCacheMaxAge time.Duration `envconfig:"KEDA_HTTP_DEPLOYMENT_CACHE_MAX_AGE" default:"5m"`
+}
+
+// Validate returns a non-nil error if w is invalid
+func (w *Waiting) Validate() error {
+	switch w.Strategy {
+	case WaitStrategyReplicas, WaitStrategyReady:
+	default:
+		return fmt.Errorf(
+			"wait strategy %q is invalid. accepted values are: %s, %s",
+			w.Strategy,
+			WaitStrategyReplicas,
+			WaitStrategyReady,
+		)
+	}
+	if w.DeploymentCacheMaxAge <= 0 {
+		return fmt.Errorf(
+			"deployment cache max age %s is invalid. it must be positive",
+			w.DeploymentCacheMaxAge,
+		)
+	}
+	return nil
+}
+
+// MustParseWaiting parses waiting configs using envconfig and returns a
+// pointer to the newly created config. Panics if parsing failed or the
+// config is invalid
+func MustParseWaiting() *Waiting {
+	ret := new(Waiting)
+	envconfig.MustProcess("", ret)
+	if err := ret.Validate(); err != nil {
+		panic(err)
+	}
+	return ret
+}
@@ -1,36 +1,122 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"time"
 
+	"github.com/kedacore/http-add-on/interceptor/config"
 	"github.com/kedacore/http-add-on/pkg/k8s"
+	"github.com/kedacore/http-add-on/pkg/routing"
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	corev1 "k8s.io/api/core/v1"
 )
 
-type forwardWaitFunc func() error
+// forwardWaitFunc waits for the scale target of target to be ready to
+// accept requests. It returns a nil error when the target is ready, and a
+// non-nil error if it didn't become ready in time or ctx is done
+type forwardWaitFunc func(ctx context.Context, target routing.Target) error
 
+// scalePollInterval is how often the wait funcs for scale targets other
+// than Deployments check the target's /scale subresource
+const scalePollInterval = 100 * time.Millisecond
+
+// newForwardWaitFunc returns the forwardWaitFunc for the given wait
+// strategy, which must be one of the config.WaitStrategy* constants.
+//
+// The returned func waits on Deployments using deployCache. It waits on
+// all other scale targets using their /scale subresources from
+// scaleGetter, or with the ready strategy, using the endpoints of their
+// services from endpointsCache. endpointsCache is only used with the
+// ready strategy, so it can be nil with the others
+func newForwardWaitFunc(
+	strategy string,
+	deployCache k8s.DeploymentCache,
+	endpointsCache k8s.EndpointsCache,
+	scaleGetter k8s.ScaleGetter,
+	totalWait time.Duration,
+) (forwardWaitFunc, error) {
+	var deployWaitFunc, scaleWaitFunc forwardWaitFunc
+	switch strategy {
+	case config.WaitStrategyReplicas:
+		deployWaitFunc = newDeployReplicasForwardWaitFunc(deployCache, totalWait)
+		scaleWaitFunc = newScaleReplicasForwardWaitFunc(
+			newScalePoller(scaleGetter, scalePollInterval),
+			totalWait,
+		)
+	case config.WaitStrategyReady:
+		deployWaitFunc = newDeployReadyForwardWaitFunc(deployCache, totalWait)
+		scaleWaitFunc = newEndpointsReadyForwardWaitFunc(endpointsCache, totalWait)
+	default:
+		return nil, fmt.Errorf("unknown wait strategy %q", strategy)
+	}
+	return func(ctx context.Context, target routing.Target) error {
+		if target.ScaleTarget().IsDeployment() {
+			return deployWaitFunc(ctx, target)
+		}
+		return scaleWaitFunc(ctx, target)
+	}, nil
+}
+
+// newDeployReplicasForwardWaitFunc returns a forwardWaitFunc for
+// Deployments that waits until the deployment has requested 1 or more
+// replicas. The replicas may not be ready to serve requests yet when it
+// returns
 func newDeployReplicasForwardWaitFunc(
 	deployCache k8s.DeploymentCache,
-	deployName string,
 	totalWait time.Duration,
 ) forwardWaitFunc {
-	return func() error {
+	return newDeployConditionForwardWaitFunc(
+		deployCache,
+		totalWait,
+		"reach > 0 replicas",
+		func(deployment *appsv1.Deployment) bool {
+			return moreThanPtr(deployment.Spec.Replicas, 0)
+		},
+	)
+}
+
+// newDeployReadyForwardWaitFunc returns a forwardWaitFunc for
+// Deployments that waits until the deployment has 1 or more ready
+// replicas
+func newDeployReadyForwardWaitFunc(
+	deployCache k8s.DeploymentCache,
+	totalWait time.Duration,
+) forwardWaitFunc {
+	return newDeployConditionForwardWaitFunc(
+		deployCache,
+		totalWait,
+		"have > 0 ready replicas",
+		func(deployment *appsv1.Deployment) bool {
+			return deployment.Status.ReadyReplicas > 0
+		},
+	)
+}
+
+// newDeployConditionForwardWaitFunc returns a forwardWaitFunc for
+// Deployments that waits until cond returns true for the deployment.
+// desc describes what cond checks for, and is used in error messages
+func newDeployConditionForwardWaitFunc(
+	deployCache k8s.DeploymentCache,
+	totalWait time.Duration,
+	desc string,
+	cond func(*appsv1.Deployment) bool,
+) forwardWaitFunc {
+	return func(ctx context.Context, target routing.Target) error {
+		deployName := target.ScaleTarget().Name
 		deployment, err := deployCache.Get(deployName)
 		if err != nil {
 			// if we didn't get the initial deployment state, bail out
 			return fmt.Errorf("Error getting state for deployment %s (%s)", deployName, err)
 		}
-		// if there is 1 or more replica, we're done waiting
-		if moreThanPtr(deployment.Spec.Replicas, 0) {
+		// if the condition is already met, we're done waiting
+		if cond(deployment) {
 			return nil
 		}
 
 		watcher := deployCache.Watch(deployName)
-		if err != nil {
-			return fmt.Errorf("Error getting the stream of deployment changes")
-		}
 		defer watcher.Stop()
 		eventCh := watcher.ResultChan()
 		timer := time.NewTimer(totalWait)
@@ -41,13 +127,112 @@ func newDeployReplicasForwardWaitFunc(
 				deployment, ok := event.Object.(*appsv1.Deployment)
 				if !ok {
 					log.Println("Didn't get a deployment back in event")
+					continue
 				}
-				if moreThanPtr(deployment.Spec.Replicas, 0) {
+				if cond(deployment) {
 					return nil
 				}
 			case <-timer.C:
 				// otherwise, if we hit the end of the timeout, fail
-				return fmt.Errorf("Timeout expired waiting for deployment %s to reach > 0 replicas", deployName)
+				return fmt.Errorf("Timeout expired waiting for deployment %s to %s", deployName, desc)
+			case <-ctx.Done():
+				return fmt.Errorf("Context done waiting for deployment %s to %s (%s)", deployName, desc, ctx.Err())
+			}
+		}
+	}
+}
+
+// newScaleReplicasForwardWaitFunc returns a forwardWaitFunc that waits
+// until the scale target has requested 1 or more replicas. It gets the
+// target's /scale subresource from poller, so all the requests waiting
+// on the same target share its polling
+func newScaleReplicasForwardWaitFunc(
+	poller *scalePoller,
+	totalWait time.Duration,
+) forwardWaitFunc {
+	return newScaleConditionForwardWaitFunc(
+		poller,
+		totalWait,
+		"reach > 0 replicas",
+		func(scale *autoscalingv1.Scale) bool {
+			return scale.Spec.Replicas > 0
+		},
+	)
+}
+
+// newScaleConditionForwardWaitFunc returns a forwardWaitFunc that
+// watches the scale target's /scale subresource with poller until cond
+// returns true for it. desc describes what cond checks for, and is used
+// in error messages
+func newScaleConditionForwardWaitFunc(
+	poller *scalePoller,
+	totalWait time.Duration,
+	desc string,
+	cond func(*autoscalingv1.Scale) bool,
+) forwardWaitFunc {
+	return func(ctx context.Context, target routing.Target) error {
+		ref := target.ScaleTarget()
+		resCh, stop := poller.watch(ref)
+		defer stop()
+		timer := time.NewTimer(totalWait)
+		defer timer.Stop()
+		for {
+			select {
+			case res := <-resCh:
+				if res.err != nil {
+					return fmt.Errorf("Error getting scale for %s %s (%s)", ref.Kind, ref.Name, res.err)
+				}
+				if cond(res.scale) {
+					return nil
+				}
+			case <-timer.C:
+				return fmt.Errorf("Timeout expired waiting for %s %s to %s", ref.Kind, ref.Name, desc)
+			case <-ctx.Done():
+				return fmt.Errorf("Context done waiting for %s %s to %s (%s)", ref.Kind, ref.Name, desc, ctx.Err())
+			}
+		}
+	}
+}
+
+// newEndpointsReadyForwardWaitFunc returns a forwardWaitFunc that waits
+// until the target's service has 1 or more ready addresses in its
+// endpoints. The /scale subresource doesn't say whether replicas are
+// ready, so this is what the ready strategy waits on for scale targets
+// other than Deployments
+func newEndpointsReadyForwardWaitFunc(
+	endpointsCache k8s.EndpointsCache,
+	totalWait time.Duration,
+) forwardWaitFunc {
+	return func(ctx context.Context, target routing.Target) error {
+		svcName := target.Service
+		endpoints, err := endpointsCache.Get(svcName)
+		if err != nil {
+			return fmt.Errorf("Error getting endpoints for service %s (%s)", svcName, err)
+		}
+		if k8s.HasReadyAddresses(endpoints) {
+			return nil
+		}
+
+		watcher := endpointsCache.Watch(svcName)
+		defer watcher.Stop()
+		eventCh := watcher.ResultChan()
+		timer := time.NewTimer(totalWait)
+		defer timer.Stop()
+		for {
+			select {
+			case event := <-eventCh:
+				endpoints, ok := event.Object.(*corev1.Endpoints)
+				if !ok {
+					log.Println("Didn't get endpoints back in event")
+					continue
+				}
+				if k8s.HasReadyAddresses(endpoints) {
+					return nil
+				}
+			case <-timer.C:
+				return fmt.Errorf("Timeout expired waiting for service %s to have ready endpoints", svcName)
+			case <-ctx.Done():
+				return fmt.Errorf("Context done waiting for service %s to have ready endpoints (%s)", svcName, ctx.Err())
 			}
 		}
 	}
@@ -2,13 +2,18 @@ package main
 
 import (
 	"context"
+	"errors"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/kedacore/http-add-on/interceptor/config"
 	"github.com/kedacore/http-add-on/pkg/k8s"
+	"github.com/kedacore/http-add-on/pkg/routing"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/sync/errgroup"
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/watch"
 )
@@ -32,14 +37,15 @@ func TestForwardWaitFuncOneReplica(t *testing.T) {
 	})
 	waitFunc := newDeployReplicasForwardWaitFunc(
 		cache,
-		deployName,
 		1*time.Second,
 	)
 
 	ctx, done := context.WithTimeout(context.Background(), 100*time.Millisecond)
 	defer done()
 	group, _ := errgroup.WithContext(ctx)
-	group.Go(waitFunc)
+	group.Go(func() error {
+		return waitFunc(ctx, routing.NewTarget("testsvc", 8080, deployName))
+	})
 	r.NoError(group.Wait())
 }
 
@@ -65,11 +71,10 @@ func TestForwardWaitFuncNoReplicas(t *testing.T) {
 
 	waitFunc := newDeployReplicasForwardWaitFunc(
 		cache,
-		deployName,
 		1*time.Second,
 	)
 
-	err := waitFunc()
+	err := waitFunc(context.Background(), routing.NewTarget("testsvc", 8080, deployName))
 	r.Error(err)
 }
 
@@ -92,7 +97,7 @@ func TestWaitFuncWaitsUntilReplicas(t *testing.T) {
 	cache := k8s.NewMemoryDeploymentCache(map[string]*appsv1.Deployment{
 		deployName: deployment,
 	})
-	waitFunc := newDeployReplicasForwardWaitFunc(cache, deployName, totalWaitDur)
+	waitFunc := newDeployReplicasForwardWaitFunc(cache, totalWaitDur)
 	// this channel will be closed immediately after the replicas were increased
 	replicasIncreasedCh := make(chan struct{})
 	go func() {
@@ -105,5 +110,298 @@ func TestWaitFuncWaitsUntilReplicas(t *testing.T) {
 		watcher.Action(watch.Modified, modifiedDeployment)
 		close(replicasIncreasedCh)
 	}()
-	r.NoError(waitFunc())
+	r.NoError(waitFunc(context.Background(), routing.NewTarget("testsvc", 8080, deployName)))
+}
+
+// Test to make sure the wait function returns an error as soon as its context is
+// done, even if the total wait duration hasn't elapsed
+func TestForwardWaitFuncContextDone(t *testing.T) {
+	r := require.New(t)
+	const ns = "testNS"
+	const deployName = "TestForwardWaitFuncContextDone"
+	deployment := k8s.NewDeployment(
+		ns,
+		deployName,
+		"myimage",
+		[]int32{123},
+		nil,
+		map[string]string{},
+		corev1.PullAlways,
+	)
+	deployment.Spec.Replicas = k8s.Int32P(0)
+	cache := k8s.NewMemoryDeploymentCache(map[string]*appsv1.Deployment{
+		deployName: deployment,
+	})
+	waitFunc := newDeployReplicasForwardWaitFunc(cache, 10*time.Second)
+
+	ctx, done := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer done()
+	start := time.Now()
+	r.Error(waitFunc(ctx, routing.NewTarget("testsvc", 8080, deployName)))
+	r.Less(time.Since(start), 1*time.Second)
+}
+
+// Test to make sure the readiness-based wait function doesn't return when
+// the deployment merely has replicas, and returns as soon as one of them
+// is ready
+func TestReadyForwardWaitFuncWaitsUntilReady(t *testing.T) {
+	r := require.New(t)
+	totalWaitDur := 500 * time.Millisecond
+
+	const ns = "testNS"
+	const deployName = "TestReadyForwardWaitFunc"
+	deployment := k8s.NewDeployment(
+		ns,
+		deployName,
+		"myimage",
+		[]int32{123},
+		nil,
+		map[string]string{},
+		corev1.PullAlways,
+	)
+	deployment.Spec.Replicas = k8s.Int32P(1)
+	deployment.Status.ReadyReplicas = 0
+	cache := k8s.NewMemoryDeploymentCache(map[string]*appsv1.Deployment{
+		deployName: deployment,
+	})
+	waitFunc, err := newForwardWaitFunc(config.WaitStrategyReady, cache, nil, k8s.NewMemoryScaleGetter(nil), totalWaitDur)
+	r.NoError(err)
+
+	readyCh := make(chan struct{})
+	go func() {
+		time.Sleep(totalWaitDur / 2)
+		cache.RWM.RLock()
+		defer cache.RWM.RUnlock()
+		watcher := cache.Watchers[deployName]
+		// an update that doesn't make a replica ready shouldn't release
+		// the wait
+		notReady := deployment.DeepCopy()
+		notReady.Spec.Replicas = k8s.Int32P(2)
+		watcher.Action(watch.Modified, notReady)
+		ready := deployment.DeepCopy()
+		ready.Status.ReadyReplicas = 1
+		watcher.Action(watch.Modified, ready)
+		close(readyCh)
+	}()
+	start := time.Now()
+	r.NoError(waitFunc(context.Background(), routing.NewTarget("testsvc", 8080, deployName)))
+	r.GreaterOrEqual(time.Since(start), totalWaitDur/2)
+	<-readyCh
+}
+
+func TestNewForwardWaitFuncUnknownStrategy(t *testing.T) {
+	r := require.New(t)
+	cache := k8s.NewMemoryDeploymentCache(map[string]*appsv1.Deployment{})
+	_, err := newForwardWaitFunc(
+		"notastrategy",
+		cache,
+		nil,
+		k8s.NewMemoryScaleGetter(nil),
+		time.Second,
+	)
+	r.Error(err)
+}
+
+// Test to make sure the readiness-based wait function times out if the
+// deployment has replicas but none of them become ready
+func TestReadyForwardWaitFuncNoReadyReplicas(t *testing.T) {
+	r := require.New(t)
+	const ns = "testNS"
+	const deployName = "TestReadyForwardWaitFuncNoReadyReplicas"
+	deployment := k8s.NewDeployment(
+		ns,
+		deployName,
+		"myimage",
+		[]int32{123},
+		nil,
+		map[string]string{},
+		corev1.PullAlways,
+	)
+	deployment.Spec.Replicas = k8s.Int32P(1)
+	cache := k8s.NewMemoryDeploymentCache(map[string]*appsv1.Deployment{
+		deployName: deployment,
+	})
+	waitFunc, err := newForwardWaitFunc(config.WaitStrategyReady, cache, nil, k8s.NewMemoryScaleGetter(nil), 100*time.Millisecond)
+	r.NoError(err)
+	r.Error(waitFunc(context.Background(), routing.NewTarget("testsvc", 8080, deployName)))
+}
+
+// newScaleTarget returns a Target for the service called svc, backed by
+// ref
+func newScaleTarget(svc string, ref routing.ScaleTargetRef) routing.Target {
+	target := routing.NewTarget(svc, 8080, "")
+	target.ScaleTargetRef = &ref
+	return target
+}
+
+// Test to make sure the wait function for scale targets other than
+// Deployments polls the target's /scale subresource until it has replicas
+func TestScaleForwardWaitFuncWaitsUntilReplicas(t *testing.T) {
+	r := require.New(t)
+	totalWaitDur := 500 * time.Millisecond
+	ref := routing.ScaleTargetRef{
+		APIVersion: "apps/v1",
+		Kind:       "StatefulSet",
+		Name:       "TestScaleForwardWaitFunc",
+	}
+	key := k8s.MemoryScaleKey(ref.APIVersion, ref.Kind, ref.Name)
+	getter := k8s.NewMemoryScaleGetter(map[string]*autoscalingv1.Scale{
+		key: {},
+	})
+	waitFunc, err := newForwardWaitFunc(
+		config.WaitStrategyReplicas,
+		k8s.NewMemoryDeploymentCache(map[string]*appsv1.Deployment{}),
+		nil,
+		getter,
+		totalWaitDur,
+	)
+	r.NoError(err)
+
+	go func() {
+		time.Sleep(totalWaitDur / 2)
+		getter.RWM.Lock()
+		defer getter.RWM.Unlock()
+		getter.Scales[key] = &autoscalingv1.Scale{
+			Spec: autoscalingv1.ScaleSpec{Replicas: 1},
+		}
+	}()
+	start := time.Now()
+	r.NoError(waitFunc(context.Background(), newScaleTarget("testsvc", ref)))
+	r.GreaterOrEqual(time.Since(start), totalWaitDur/2)
+}
+
+// Test to make sure the wait function for scale targets other than
+// Deployments times out if the target never gets replicas, and fails
+// right away if the target doesn't exist
+func TestScaleForwardWaitFuncNoReplicas(t *testing.T) {
+	r := require.New(t)
+	ref := routing.ScaleTargetRef{
+		APIVersion: "argoproj.io/v1alpha1",
+		Kind:       "Rollout",
+		Name:       "TestScaleForwardWaitFuncNoReplicas",
+	}
+	getter := k8s.NewMemoryScaleGetter(map[string]*autoscalingv1.Scale{
+		k8s.MemoryScaleKey(ref.APIVersion, ref.Kind, ref.Name): {
+			Status: autoscalingv1.ScaleStatus{Replicas: 1},
+		},
+	})
+	poller := newScalePoller(getter, scalePollInterval)
+	waitFunc := newScaleReplicasForwardWaitFunc(poller, 100*time.Millisecond)
+	r.Error(waitFunc(context.Background(), newScaleTarget("testsvc", ref)))
+
+	ref.Name = "doesnotexist"
+	start := time.Now()
+	r.Error(waitFunc(context.Background(), newScaleTarget("testsvc", ref)))
+	r.Less(time.Since(start), 100*time.Millisecond)
+}
+
+// countingScaleGetter is a ScaleGetter that counts the calls to GetScale
+type countingScaleGetter struct {
+	k8s.ScaleGetter
+	calls int32
+}
+
+func (c *countingScaleGetter) GetScale(
+	ctx context.Context,
+	apiVersion,
+	kind,
+	name string,
+) (*autoscalingv1.Scale, error) {
+	atomic.AddInt32(&c.calls, 1)
+	return c.ScaleGetter.GetScale(ctx, apiVersion, kind, name)
+}
+
+// Test to make sure that requests waiting on the same scale target share
+// a single poll of its /scale subresource, which stops once they're done
+func TestScaleForwardWaitFuncSharesPolling(t *testing.T) {
+	r := require.New(t)
+	const (
+		pollInterval = 20 * time.Millisecond
+		totalWait    = 200 * time.Millisecond
+		numWaiters   = 10
+	)
+	ref := routing.ScaleTargetRef{
+		APIVersion: "apps/v1",
+		Kind:       "StatefulSet",
+		Name:       "TestScaleForwardWaitFuncSharesPolling",
+	}
+	getter := &countingScaleGetter{
+		ScaleGetter: k8s.NewMemoryScaleGetter(map[string]*autoscalingv1.Scale{
+			k8s.MemoryScaleKey(ref.APIVersion, ref.Kind, ref.Name): {},
+		}),
+	}
+	poller := newScalePoller(getter, pollInterval)
+	waitFunc := newScaleReplicasForwardWaitFunc(poller, totalWait)
+
+	group, _ := errgroup.WithContext(context.Background())
+	for i := 0; i < numWaiters; i++ {
+		group.Go(func() error {
+			if err := waitFunc(context.Background(), newScaleTarget("testsvc", ref)); err == nil {
+				return errors.New("expected the wait to time out")
+			}
+			return nil
+		})
+	}
+	r.NoError(group.Wait())
+	// the waiters together should poll about as often as one of them
+	// would on its own, rather than numWaiters times as often
+	calls := atomic.LoadInt32(&getter.calls)
+	r.LessOrEqual(calls, int32(totalWait/pollInterval)+numWaiters/2)
+
+	// once nobody is waiting, the polling stops
+	time.Sleep(pollInterval * 2)
+	r.Empty(poller.polls)
+	calls = atomic.LoadInt32(&getter.calls)
+	time.Sleep(pollInterval * 2)
+	r.Equal(calls, atomic.LoadInt32(&getter.calls))
+}
+
+// Test to make sure the ready strategy waits on the endpoints of the
+// service for scale targets other than Deployments, since their /scale
+// subresource counts replicas that aren't ready
+func TestEndpointsReadyForwardWaitFunc(t *testing.T) {
+	r := require.New(t)
+	totalWaitDur := 500 * time.Millisecond
+	const svcName = "TestEndpointsReadyForwardWaitFunc"
+	ref := routing.ScaleTargetRef{
+		APIVersion: "apps/v1",
+		Kind:       "StatefulSet",
+		Name:       "myapp",
+	}
+	notReady := &corev1.Endpoints{
+		Subsets: []corev1.EndpointSubset{{
+			NotReadyAddresses: []corev1.EndpointAddress{{IP: "1.2.3.4"}},
+		}},
+	}
+	endpointsCache := k8s.NewMemoryEndpointsCache(map[string]*corev1.Endpoints{
+		svcName: notReady,
+	})
+	waitFunc, err := newForwardWaitFunc(
+		config.WaitStrategyReady,
+		k8s.NewMemoryDeploymentCache(map[string]*appsv1.Deployment{}),
+		endpointsCache,
+		k8s.NewMemoryScaleGetter(nil),
+		totalWaitDur,
+	)
+	r.NoError(err)
+
+	go func() {
+		time.Sleep(totalWaitDur / 2)
+		endpointsCache.RWM.RLock()
+		defer endpointsCache.RWM.RUnlock()
+		watcher := endpointsCache.Watchers[svcName]
+		// an update without ready addresses shouldn't release the wait
+		watcher.Action(watch.Modified, notReady.DeepCopy())
+		ready := notReady.DeepCopy()
+		ready.Subsets[0].Addresses = []corev1.EndpointAddress{{IP: "1.2.3.5"}}
+		watcher.Action(watch.Modified, ready)
+	}()
+	start := time.Now()
+	r.NoError(waitFunc(context.Background(), newScaleTarget(svcName, ref)))
+	r.GreaterOrEqual(time.Since(start), totalWaitDur/2)
+
+	// the wait fails right away for services that have no endpoints
+	start = time.Now()
+	r.Error(waitFunc(context.Background(), newScaleTarget("doesnotexist", ref)))
+	r.Less(time.Since(start), totalWaitDur/2)
 }
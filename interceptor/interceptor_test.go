@@ -13,24 +13,37 @@ func newTestCtx(method, path string) (*echo.Echo, echo.Context, *httptest.Respon
 	return e, e.NewContext(req, rec), rec
 }
 
+type fakeQueueCounterResize struct {
+	key   string
+	delta int
+}
+
 type fakeQueueCounter struct {
-	resizedCh chan int
+	resizedCh chan fakeQueueCounterResize
 }
 
-func (f *fakeQueueCounter) Resize(i int) error {
-	f.resizedCh <- i
+func (f *fakeQueueCounter) Resize(key string, delta int) error {
+	f.resizedCh <- fakeQueueCounterResize{key: key, delta: delta}
 	return nil
 }
 
-func (f *fakeQueueCounter) Current() (int, error) {
+func (f *fakeQueueCounter) Current(string) (int, error) {
 	return 0, nil
 }
 
+func (f *fakeQueueCounter) Snapshot() (map[string]int, error) {
+	return map[string]int{}, nil
+}
+
 type fakeQueueCountReader struct {
-	current int
-	err     error
+	counts map[string]int
+	err    error
+}
+
+func (f *fakeQueueCountReader) Current(key string) (int, error) {
+	return f.counts[key], f.err
 }
 
-func (f *fakeQueueCountReader) Current() (int, error) {
-	return f.current, f.err
+func (f *fakeQueueCountReader) Snapshot() (map[string]int, error) {
+	return f.counts, f.err
 }
@@ -2,19 +2,21 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"math/rand"
 	nethttp "net/http"
-
-	"net/url"
 	"time"
 
 	"github.com/kedacore/http-add-on/interceptor/config"
 	"github.com/kedacore/http-add-on/pkg/http"
 	"github.com/kedacore/http-add-on/pkg/k8s"
 	kedanet "github.com/kedacore/http-add-on/pkg/net"
+	"github.com/kedacore/http-add-on/pkg/routing"
+	externalscaler "github.com/kedacore/http-add-on/proto"
 	echo "github.com/labstack/echo/v4"
+	"google.golang.org/grpc"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 )
@@ -27,18 +29,37 @@ func main() {
 	timeoutCfg := config.MustParseTimeouts()
 	originCfg := config.MustParseOrigin()
 	servingCfg := config.MustParseServing()
+	routingCfg := config.MustParseRouting()
+	reportingCfg := config.MustParseReporting()
+	waitingCfg := config.MustParseWaiting()
 	ctx := context.Background()
 
-	deployName := originCfg.TargetDeploymentName
 	ns := originCfg.Namespace
 	proxyPort := servingCfg.ProxyPort
 	adminPort := servingCfg.AdminPort
-	svcURL, err := originCfg.ServiceURL()
-	if err != nil {
-		log.Fatalf("Invalid origin service URL: %s", err)
+
+	q := newReportingQueueCounter(http.NewMemoryQueue())
+	routingTable := routing.NewTable()
+	if defaultTarget, ok := originCfg.DefaultTarget(); ok {
+		if _, err := defaultTarget.ServiceURL(); err != nil {
+			log.Fatalf("Invalid origin service URL: %s", err)
+		}
+		routingTable.SetDefaultTarget(defaultTarget)
+		log.Printf(
+			"Forwarding requests for unknown hosts to service %s:%d, watching %s %s",
+			defaultTarget.Service,
+			defaultTarget.Port,
+			defaultTarget.ScaleTarget().Kind,
+			defaultTarget.ScaleTarget().Name,
+		)
 	}
 
-	q := http.NewMemoryQueue()
+	if routingCfg.Table != "" {
+		if err := json.Unmarshal([]byte(routingCfg.Table), routingTable); err != nil {
+			log.Fatalf("Invalid routing table (%s)", err)
+		}
+		log.Printf("Loaded routing table from the environment")
+	}
 
 	cfg, err := rest.InClusterConfig()
 	if err != nil {
@@ -56,22 +77,91 @@ func main() {
 	if err != nil {
 		log.Fatalf("Error creating new deployment cache (%s)", err)
 	}
-	waitFunc := newDeployReplicasForwardWaitFunc(deployCache, deployName, 1*time.Second)
-
-	log.Printf(
-		"Interceptor started, forwarding to service %s:%s, watching deployment %s",
-		originCfg.AppServiceName,
-		originCfg.AppServicePort,
-		originCfg.TargetDeploymentName,
+	scaleGetter, err := k8s.NewK8sScaleGetter(cfg, ns)
+	if err != nil {
+		log.Fatalf("Error creating new scale getter (%s)", err)
+	}
+	// only the ready strategy waits on endpoints, so don't make the
+	// others need permission to watch them
+	var endpointsCache k8s.EndpointsCache
+	if waitingCfg.Strategy == config.WaitStrategyReady {
+		endpointsCache, err = k8s.NewK8sEndpointsCache(ctx, cl.CoreV1().Endpoints(ns))
+		if err != nil {
+			log.Fatalf("Error creating new endpoints cache (%s)", err)
+		}
+	}
+	waitFunc, err := newForwardWaitFunc(
+		waitingCfg.Strategy,
+		deployCache,
+		endpointsCache,
+		scaleGetter,
+		timeoutCfg.DeploymentReplicas,
 	)
+	if err != nil {
+		log.Fatalf("Error creating wait function (%s)", err)
+	}
+	log.Printf("Waiting for scale targets using the %s strategy", waitingCfg.Strategy)
+
+	if routingCfg.ConfigMapName != "" {
+		log.Printf(
+			"Reading routing table from ConfigMap %s every %s",
+			routingCfg.ConfigMapName,
+			routingCfg.UpdateInterval,
+		)
+		go func() {
+			err := routing.StartConfigMapUpdateLoop(
+				ctx,
+				time.NewTicker(routingCfg.UpdateInterval),
+				cl.CoreV1().ConfigMaps(ns),
+				routingCfg.ConfigMapName,
+				routingTable,
+			)
+			log.Printf("Routing table update loop stopped (%s)", err)
+		}()
+	}
+
+	if reportingCfg.ScalerAddress != "" {
+		if reportingCfg.InterceptorID == "" {
+			log.Fatalf("An interceptor ID is required to report counts to the scaler")
+		}
+		conn, err := grpc.DialContext(
+			ctx,
+			reportingCfg.ScalerAddress,
+			grpc.WithInsecure(),
+		)
+		if err != nil {
+			log.Fatalf("Error connecting to the scaler (%s)", err)
+		}
+		log.Printf(
+			"Reporting counts to the scaler at %s as %s",
+			reportingCfg.ScalerAddress,
+			reportingCfg.InterceptorID,
+		)
+		go func() {
+			err := startQueueReportLoop(
+				ctx,
+				q,
+				externalscaler.NewQueueReporterClient(conn),
+				reportingCfg.InterceptorID,
+				reportingCfg.ReconnectInterval,
+				reportingCfg.SnapshotInterval,
+			)
+			log.Printf("Count reporting loop stopped (%s)", err)
+		}()
+	}
+
+	log.Printf("Interceptor started")
+
+	m := newMetrics(q)
+	m.watchDeploymentCache(deployCache)
 
-	go runAdminServer(q, adminPort)
+	go runAdminServer(q, m, deployCache, waitingCfg.DeploymentCacheMaxAge, adminPort)
 
 	go runProxyServer(
 		q,
-		deployName,
+		m,
+		routingTable,
 		waitFunc,
-		svcURL,
 		timeoutCfg,
 		proxyPort,
 	)
@@ -79,9 +169,18 @@ func main() {
 	select {}
 }
 
-func runAdminServer(q http.QueueCountReader, port int) {
+func runAdminServer(
+	q http.QueueCountReader,
+	m *metrics,
+	deployCache staleChecker,
+	deployCacheMaxAge time.Duration,
+	port int,
+) {
 	adminServer := echo.New()
+	adminServer.GET("/healthz", newHealthHandler(deployCache, deployCacheMaxAge))
 	adminServer.GET("/queue", newQueueSizeHandler(q))
+	adminServer.GET("/queue_counts", newQueueCountsHandler(q))
+	adminServer.GET("/metrics", echo.WrapHandler(m.handler()))
 
 	addr := fmt.Sprintf("0.0.0.0:%d", port)
 	log.Printf("admin server running on %s", addr)
@@ -90,23 +189,27 @@ func runAdminServer(q http.QueueCountReader, port int) {
 
 func runProxyServer(
 	q http.QueueCounter,
-	targetDeployName string,
+	m *metrics,
+	routingTable *routing.Table,
 	waitFunc forwardWaitFunc,
-	svcURL *url.URL,
 	timeouts *config.Timeouts,
 	port int,
 ) {
 	dialer := kedanet.NewNetDialer(timeouts.Connect, timeouts.KeepAlive)
-	dialContextFunc := kedanet.DialContextWithRetry(dialer, timeouts.DefaultBackoff())
+	dialContextFunc := kedanet.DialContextWithRetry(
+		dialer,
+		timeouts.DefaultBackoff(),
+		m.countDialRetry,
+	)
 	proxyHdl := newForwardingHandler(
-		svcURL,
+		routingTable,
 		dialContextFunc,
-		waitFunc,
+		m.instrumentWaitFunc(waitFunc, timeouts.DeploymentReplicas),
 		timeouts.DeploymentReplicas,
 		timeouts.ResponseHeader,
 	)
 
 	addr := fmt.Sprintf("0.0.0.0:%d", port)
 	log.Printf("proxy server starting on %s", addr)
-	nethttp.ListenAndServe(addr, countMiddleware(q, proxyHdl))
+	nethttp.ListenAndServe(addr, m.instrumentProxy(countMiddleware(q, routingTable, proxyHdl)))
 }
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"log"
+	"math"
+	nethttp "net/http"
+	"time"
+
+	"github.com/kedacore/http-add-on/pkg/http"
+	"github.com/kedacore/http-add-on/pkg/routing"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metricsNamespace is the prefix of the names of all the interceptor's
+// metrics
+const metricsNamespace = "interceptor"
+
+// durationBuckets are the histogram buckets for the interceptor's
+// durations, in seconds. They go up to over a minute, since requests
+// that wait for an app to scale up from zero take that long
+var durationBuckets = prometheus.ExponentialBuckets(0.005, 2, 15)
+
+// metrics are the Prometheus metrics that the interceptor serves on its
+// admin server. Each metrics has its own registry, so tests can create
+// as many as they like
+type metrics struct {
+	registry        *prometheus.Registry
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	waitDuration    prometheus.Histogram
+	waitTimeouts    prometheus.Counter
+	dialRetries     prometheus.Counter
+}
+
+// newMetrics creates the interceptor's metrics. Its queue size gauge
+// reports the total size of q, across all hosts and routes, when it's
+// scraped
+func newMetrics(q http.QueueCountReader) *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "requests_total",
+			Help:      "Requests that the proxy served, by response status code",
+		}, []string{"code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "request_duration_seconds",
+			Help:      "How long the proxy took to serve requests, including waiting for replicas, by response status code",
+			Buckets:   durationBuckets,
+		}, []string{"code"}),
+		waitDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "wait_duration_seconds",
+			Help:      "How long requests waited for their scale targets to have replicas before they were forwarded",
+			Buckets:   durationBuckets,
+		}),
+		waitTimeouts: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "wait_timeouts_total",
+			Help:      "Requests that gave up waiting for their scale targets to have replicas",
+		}),
+		dialRetries: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "dial_retries_total",
+			Help:      "Failed connections to backends that were retried",
+		}),
+	}
+	queueSize := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "pending_requests",
+		Help:      "Requests that are currently pending, across all hosts and routes",
+	}, func() float64 {
+		counts, err := q.Snapshot()
+		if err != nil {
+			log.Printf("Error getting queue size for metrics (%s)", err)
+			return math.NaN()
+		}
+		return float64(http.Total(counts))
+	})
+	m.registry.MustRegister(
+		m.requests,
+		m.requestDuration,
+		m.waitDuration,
+		m.waitTimeouts,
+		m.dialRetries,
+		queueSize,
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// lastContacter reports the last time that a cache heard from the API
+// server. *k8s.K8sDeploymentCache is one
+type lastContacter interface {
+	LastContact() time.Time
+}
+
+// watchDeploymentCache adds a gauge to m that reports how long ago cache
+// last heard from the API server when it's scraped
+func (m *metrics) watchDeploymentCache(cache lastContacter) {
+	m.registry.MustRegister(newLastContactAgeGauge(cache))
+}
+
+// newLastContactAgeGauge returns a gauge that reports how long ago cache
+// last heard from the API server, or NaN if it hasn't yet
+func newLastContactAgeGauge(cache lastContacter) prometheus.GaugeFunc {
+	return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "deployment_cache_last_contact_age_seconds",
+		Help:      "How long ago the deployment cache last heard from the API server",
+	}, func() float64 {
+		lastContact := cache.LastContact()
+		if lastContact.IsZero() {
+			return math.NaN()
+		}
+		return time.Since(lastContact).Seconds()
+	})
+}
+
+// handler returns a handler that serves m in the Prometheus text format
+func (m *metrics) handler() nethttp.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// instrumentProxy returns a handler that counts and times the requests
+// that next serves
+func (m *metrics) instrumentProxy(next nethttp.Handler) nethttp.Handler {
+	return promhttp.InstrumentHandlerDuration(
+		m.requestDuration,
+		promhttp.InstrumentHandlerCounter(m.requests, next),
+	)
+}
+
+// instrumentWaitFunc returns a forwardWaitFunc that times waitFunc. A
+// wait that fails because ctx's deadline passed or because it took
+// totalWait or longer counts as a timeout
+func (m *metrics) instrumentWaitFunc(
+	waitFunc forwardWaitFunc,
+	totalWait time.Duration,
+) forwardWaitFunc {
+	return func(ctx context.Context, target routing.Target) error {
+		start := time.Now()
+		err := waitFunc(ctx, target)
+		elapsed := time.Since(start)
+		m.waitDuration.Observe(elapsed.Seconds())
+		if err != nil && (ctx.Err() == context.DeadlineExceeded || elapsed >= totalWait) {
+			m.waitTimeouts.Inc()
+		}
+		return err
+	}
+}
+
+// countDialRetry counts a failed connection to a backend that's going to
+// be retried. It's for kedanet.DialContextWithRetry
+func (m *metrics) countDialRetry(error) {
+	m.dialRetries.Inc()
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kedacore/http-add-on/pkg/routing"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsCountProxiedRequests(t *testing.T) {
+	r := require.New(t)
+	m := newMetrics(&fakeQueueCountReader{})
+	hdl := m.instrumentProxy(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/missing" {
+			w.WriteHeader(404)
+			return
+		}
+		w.WriteHeader(200)
+	}))
+	for _, path := range []string{"/", "/", "/missing"} {
+		res, req, err := reqAndRes(path)
+		r.NoError(err)
+		hdl.ServeHTTP(res, req)
+	}
+	r.Equal(2.0, testutil.ToFloat64(m.requests.WithLabelValues("200")))
+	r.Equal(1.0, testutil.ToFloat64(m.requests.WithLabelValues("404")))
+	r.Equal(2, testutil.CollectAndCount(m.requestDuration))
+}
+
+func TestMetricsTimeWaits(t *testing.T) {
+	r := require.New(t)
+	m := newMetrics(&fakeQueueCountReader{})
+	const totalWait = 50 * time.Millisecond
+	target := routing.NewTarget("testsvc", 8080, "testdepl")
+
+	ready := m.instrumentWaitFunc(func(context.Context, routing.Target) error {
+		return nil
+	}, totalWait)
+	r.NoError(ready(context.Background(), target))
+	r.Equal(0.0, testutil.ToFloat64(m.waitTimeouts))
+
+	// an error before the timeout isn't a timeout
+	failed := m.instrumentWaitFunc(func(context.Context, routing.Target) error {
+		return errors.New("no such deployment")
+	}, totalWait)
+	r.Error(failed(context.Background(), target))
+	r.Equal(0.0, testutil.ToFloat64(m.waitTimeouts))
+
+	timedOut := m.instrumentWaitFunc(func(context.Context, routing.Target) error {
+		time.Sleep(totalWait)
+		return errors.New("timed out")
+	}, totalWait)
+	r.Error(timedOut(context.Background(), target))
+	r.Equal(1.0, testutil.ToFloat64(m.waitTimeouts))
+
+	// the wait func doesn't notice that the context is done
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	<-ctx.Done()
+	r.Error(failed(ctx, target))
+	r.Equal(2.0, testutil.ToFloat64(m.waitTimeouts))
+}
+
+func TestMetricsHandler(t *testing.T) {
+	r := require.New(t)
+	m := newMetrics(&fakeQueueCountReader{
+		counts: map[string]int{
+			"host1.com":     100,
+			"host2.com/api": 23,
+		},
+	})
+	m.countDialRetry(errors.New("connection refused"))
+
+	rec := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/metrics", nil)
+	r.NoError(err)
+	m.handler().ServeHTTP(rec, req)
+	r.Equal(200, rec.Code, "response code")
+	body := rec.Body.String()
+	r.Contains(body, "interceptor_pending_requests 123\n")
+	r.Contains(body, "interceptor_dial_retries_total 1\n")
+	r.Contains(body, "interceptor_wait_timeouts_total 0\n")
+	r.Contains(body, "go_goroutines", "Go runtime metrics weren't served")
+}
+
+// fakeLastContacter is a lastContacter that last heard from the API
+// server at lastContact
+type fakeLastContacter struct {
+	lastContact time.Time
+}
+
+func (f *fakeLastContacter) LastContact() time.Time {
+	return f.lastContact
+}
+
+func TestMetricsDeploymentCacheLastContactAge(t *testing.T) {
+	r := require.New(t)
+	cache := &fakeLastContacter{}
+	gauge := newLastContactAgeGauge(cache)
+
+	// the cache hasn't heard from the API server yet
+	r.True(math.IsNaN(testutil.ToFloat64(gauge)))
+
+	cache.lastContact = time.Now().Add(-time.Minute)
+	age := testutil.ToFloat64(gauge)
+	r.GreaterOrEqual(age, time.Minute.Seconds())
+	r.Less(age, (2 * time.Minute).Seconds())
+
+	m := newMetrics(&fakeQueueCountReader{})
+	m.watchDeploymentCache(cache)
+	rec := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/metrics", nil)
+	r.NoError(err)
+	m.handler().ServeHTTP(rec, req)
+	r.Contains(rec.Body.String(), "interceptor_deployment_cache_last_contact_age_seconds 6")
+}
@@ -5,24 +5,35 @@ import (
 	nethttp "net/http"
 
 	"github.com/kedacore/http-add-on/pkg/http"
+	"github.com/kedacore/http-add-on/pkg/routing"
 )
 
 // countMiddleware takes que MemoryQueue previously initiated and increments the
 // size of it before sending the request to the original app, after the request
-// is finished, it decrements the queue size
-func countMiddleware(q http.QueueCounter, next nethttp.Handler) nethttp.Handler {
+// is finished, it decrements the queue size.
+//
+// Requests are counted under the queue key of the target that routingTable
+// routes them to, so that each host and route has its own count. Requests
+// that have no route aren't counted
+func countMiddleware(
+	q http.QueueCounter,
+	routingTable *routing.Table,
+	next nethttp.Handler,
+) nethttp.Handler {
 	return nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
-		// TODO: need to figure out a way to get the increment
-		// to happen before fn(w, r) happens below. otherwise,
-		// the counter won't get incremented right away and the actual
-		// handler will hang longer than it needs to
-		go func() {
-			if err := q.Resize(+1); err != nil {
-				log.Printf("Error incrementing queue for %q (%s)", r.RequestURI, err)
-			}
-		}()
+		target, err := routingTable.Lookup(r.Host, r.URL.Path, r.Header)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		key := target.QueueKey(r.Host)
+		// increment before the request is forwarded, so that the count
+		// is never decremented before it's incremented
+		if err := q.Resize(key, +1); err != nil {
+			log.Printf("Error incrementing queue for %q (%s)", r.RequestURI, err)
+		}
 		defer func() {
-			if err := q.Resize(-1); err != nil {
+			if err := q.Resize(key, -1); err != nil {
 				log.Printf("Error decrementing queue for %q (%s)", r.RequestURI, err)
 			}
 		}()
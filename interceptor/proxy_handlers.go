@@ -5,10 +5,10 @@ import (
 	"fmt"
 	"log"
 	"net/http"
-	"net/url"
 	"time"
 
 	kedanet "github.com/kedacore/http-add-on/pkg/net"
+	"github.com/kedacore/http-add-on/pkg/routing"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -16,14 +16,19 @@ func moreThanPtr(i *int32, target int32) bool {
 	return i != nil && *i > target
 }
 
-// newForwardingHandler takes in the service URL for the app backend
-// and forwards incoming requests to it. Note that it isn't multitenant.
-// It's intended to be deployed and scaled alongside the application itself.
+// newForwardingHandler takes in a routing table and forwards incoming
+// requests to the target in the table that matches each request's host,
+// path and headers. Before forwarding, it calls waitFunc with the target
+// so that the request isn't sent to an app that has no replicas. Since
+// each target names its own scale target, requests for different
+// targets wait independently of each other. Requests for
+// paused targets are forwarded without waiting, since their replica
+// counts are pinned and won't change because of them.
 //
-// fwdSvcURL must have a valid scheme in it. The best way to do this is
-// create a URL with url.Parse("https://...")
+// Since every request is routed according to routingTable, a single
+// interceptor can front many apps
 func newForwardingHandler(
-	fwdSvcURL *url.URL,
+	routingTable *routing.Table,
 	dialCtxFunc kedanet.DialContextFunc,
 	waitFunc forwardWaitFunc,
 	waitTimeout time.Duration,
@@ -40,18 +45,42 @@ func newForwardingHandler(
 		ResponseHeaderTimeout: respHeaderTimeout,
 	}
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ctx, done := context.WithTimeout(r.Context(), waitTimeout)
-		defer done()
-		grp, _ := errgroup.WithContext(ctx)
-		grp.Go(waitFunc)
-		waitErr := grp.Wait()
-		if waitErr != nil {
-			log.Printf("Error, not forwarding request")
-			w.WriteHeader(502)
-			w.Write([]byte(fmt.Sprintf("error on backend (%s)", waitErr)))
+		target, err := routingTable.Lookup(r.Host, r.URL.Path, r.Header)
+		if err != nil {
+			log.Printf("Error looking up route for host %q, path %q (%s)", r.Host, r.URL.Path, err)
+			w.WriteHeader(404)
+			w.Write([]byte(fmt.Sprintf("No route found for host %s and path %s", r.Host, r.URL.Path)))
+			return
+		}
+		fwdSvcURL, err := target.ServiceURL()
+		if err != nil {
+			log.Printf("Error parsing service URL for host %q (%s)", r.Host, err)
+			w.WriteHeader(500)
+			w.Write([]byte(fmt.Sprintf("error parsing backend URL (%s)", err)))
 			return
 		}
 
+		if !target.Paused {
+			ctx, done := context.WithTimeout(r.Context(), waitTimeout)
+			defer done()
+			grp, _ := errgroup.WithContext(ctx)
+			grp.Go(func() error {
+				return waitFunc(ctx, target)
+			})
+			waitErr := grp.Wait()
+			if waitErr != nil {
+				log.Printf("Error, not forwarding request")
+				w.WriteHeader(502)
+				w.Write([]byte(fmt.Sprintf("error on backend (%s)", waitErr)))
+				return
+			}
+		}
+
+		if target.StripPrefix {
+			// forwardRequest copies the path from r, so strip it here
+			r.URL.Path = target.StripPath(r.URL.Path)
+			r.URL.RawPath = ""
+		}
 		forwardRequest(w, r, roundTripper, fwdSvcURL)
 	})
 }
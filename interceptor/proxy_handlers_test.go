@@ -1,15 +1,22 @@
 package main
 
 import (
+	"context"
+	"net"
 	"net/http"
 	"net/url"
+	"strconv"
 	"testing"
 	"time"
 
 	kedanet "github.com/kedacore/http-add-on/pkg/net"
+	"github.com/kedacore/http-add-on/pkg/routing"
 	"github.com/stretchr/testify/require"
 )
 
+// the host that the tests in this file send requests to
+const host = "testhost.com"
+
 // the proxy should successfully forward a request to a running server
 func TestImmediatelySuccessfulProxy(t *testing.T) {
 	r := require.New(t)
@@ -24,11 +31,13 @@ func TestImmediatelySuccessfulProxy(t *testing.T) {
 
 	timeouts := defaultTimeouts()
 	dialCtxFunc := retryDialContextFunc(timeouts, timeouts.DefaultBackoff())
-	waitFunc := func() error {
+	waitFunc := func(context.Context, routing.Target) error {
 		return nil
 	}
+	routingTable, err := newRoutingTableForURL(host, originURL)
+	r.NoError(err)
 	hdl := newForwardingHandler(
-		originURL,
+		routingTable,
 		dialCtxFunc,
 		waitFunc,
 		timeouts.DeploymentReplicas,
@@ -37,6 +46,7 @@ func TestImmediatelySuccessfulProxy(t *testing.T) {
 	const path = "/testfwd"
 	res, req, err := reqAndRes(path)
 	r.NoError(err)
+	req.Host = host
 
 	hdl.ServeHTTP(res, req)
 
@@ -51,13 +61,15 @@ func TestWaitFailedConnection(t *testing.T) {
 
 	timeouts := defaultTimeouts()
 	dialCtxFunc := retryDialContextFunc(timeouts, timeouts.DefaultBackoff())
-	waitFunc := func() error {
+	waitFunc := func(context.Context, routing.Target) error {
 		return nil
 	}
 	noSuchURL, err := url.Parse("http://localhost:60002")
 	r.NoError(err)
+	routingTable, err := newRoutingTableForURL(host, noSuchURL)
+	r.NoError(err)
 	hdl := newForwardingHandler(
-		noSuchURL,
+		routingTable,
 		dialCtxFunc,
 		waitFunc,
 		timeouts.DeploymentReplicas,
@@ -66,6 +78,7 @@ func TestWaitFailedConnection(t *testing.T) {
 	const path = "/testfwd"
 	res, req, err := reqAndRes(path)
 	r.NoError(err)
+	req.Host = host
 
 	hdl.ServeHTTP(res, req)
 
@@ -84,15 +97,17 @@ func TestTimesOutOnWaitFunc(t *testing.T) {
 	waitFuncCalledCh := make(chan struct{})
 	// the wait func will wait for waitFuncCh to receive or be closed before it proceeds
 	waitFuncCh := make(chan struct{})
-	waitFunc := func() error {
+	waitFunc := func(context.Context, routing.Target) error {
 		close(waitFuncCalledCh)
 		<-waitFuncCh
 		return nil
 	}
 	noSuchURL, err := url.Parse("http://localhost:60002")
 	r.NoError(err)
+	routingTable, err := newRoutingTableForURL(host, noSuchURL)
+	r.NoError(err)
 	hdl := newForwardingHandler(
-		noSuchURL,
+		routingTable,
 		dialCtxFunc,
 		waitFunc,
 		timeouts.DeploymentReplicas,
@@ -101,6 +116,7 @@ func TestTimesOutOnWaitFunc(t *testing.T) {
 	const path = "/testfwd"
 	res, req, err := reqAndRes(path)
 	r.NoError(err)
+	req.Host = host
 
 	start := time.Now()
 	waitDur := timeouts.DeploymentReplicas * 2
@@ -130,15 +146,17 @@ func TestWaitsForWaitFunc(t *testing.T) {
 	waitFuncCalledCh := make(chan struct{})
 	// the wait func will wait for waitFuncCh to receive or be closed before it proceeds
 	waitFuncCh := make(chan struct{})
-	waitFunc := func() error {
+	waitFunc := func(context.Context, routing.Target) error {
 		close(waitFuncCalledCh)
 		<-waitFuncCh
 		return nil
 	}
 	noSuchURL, err := url.Parse("http://localhost:60002")
 	r.NoError(err)
+	routingTable, err := newRoutingTableForURL(host, noSuchURL)
+	r.NoError(err)
 	hdl := newForwardingHandler(
-		noSuchURL,
+		routingTable,
 		dialCtxFunc,
 		waitFunc,
 		timeouts.DeploymentReplicas,
@@ -147,6 +165,7 @@ func TestWaitsForWaitFunc(t *testing.T) {
 	const path = "/testfwd"
 	res, req, err := reqAndRes(path)
 	r.NoError(err)
+	req.Host = host
 
 	start := time.Now()
 	waitDur := 10 * time.Millisecond
@@ -184,11 +203,13 @@ func TestWaitHeaderTimeout(t *testing.T) {
 
 	timeouts := defaultTimeouts()
 	dialCtxFunc := retryDialContextFunc(timeouts, timeouts.DefaultBackoff())
-	waitFunc := func() error {
+	waitFunc := func(context.Context, routing.Target) error {
 		return nil
 	}
+	routingTable, err := newRoutingTableForURL(host, originURL)
+	r.NoError(err)
 	hdl := newForwardingHandler(
-		originURL,
+		routingTable,
 		dialCtxFunc,
 		waitFunc,
 		timeouts.DeploymentReplicas,
@@ -197,6 +218,7 @@ func TestWaitHeaderTimeout(t *testing.T) {
 	const path = "/testfwd"
 	res, req, err := reqAndRes(path)
 	r.NoError(err)
+	req.Host = host
 
 	hdl.ServeHTTP(res, req)
 
@@ -204,6 +226,181 @@ func TestWaitHeaderTimeout(t *testing.T) {
 	close(originHdlCh)
 }
 
+// the proxy should return a 404 if the request's host isn't in the routing
+// table, and never call the wait function
+func TestNoRouteForHost(t *testing.T) {
+	r := require.New(t)
+
+	timeouts := defaultTimeouts()
+	dialCtxFunc := retryDialContextFunc(timeouts, timeouts.DefaultBackoff())
+	waitFuncCalled := false
+	waitFunc := func(context.Context, routing.Target) error {
+		waitFuncCalled = true
+		return nil
+	}
+	hdl := newForwardingHandler(
+		routing.NewTable(),
+		dialCtxFunc,
+		waitFunc,
+		timeouts.DeploymentReplicas,
+		timeouts.ResponseHeader,
+	)
+	res, req, err := reqAndRes("/testfwd")
+	r.NoError(err)
+	req.Host = host
+
+	hdl.ServeHTTP(res, req)
+
+	r.Equal(404, res.Code, "response code was unexpected")
+	r.False(waitFuncCalled, "the wait function was called")
+}
+
+// the proxy should wait on the deployment for the target that matches the
+// request's host, not any other
+func TestWaitsOnTargetDeployment(t *testing.T) {
+	r := require.New(t)
+
+	originHdl := kedanet.NewTestHTTPHandlerWrapper(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+	})
+	srv, originURL, err := kedanet.StartTestServer(originHdl)
+	r.NoError(err)
+	defer srv.Close()
+
+	timeouts := defaultTimeouts()
+	dialCtxFunc := retryDialContextFunc(timeouts, timeouts.DefaultBackoff())
+	waitedDeployments := make(chan string, 1)
+	waitFunc := func(_ context.Context, target routing.Target) error {
+		waitedDeployments <- target.ScaleTarget().Name
+		return nil
+	}
+	routingTable, err := newRoutingTableForURL(host, originURL)
+	r.NoError(err)
+	routingTable.AddTarget("otherhost.com", routing.NewTarget("othersvc", 8080, "otherdepl"))
+	hdl := newForwardingHandler(
+		routingTable,
+		dialCtxFunc,
+		waitFunc,
+		timeouts.DeploymentReplicas,
+		timeouts.ResponseHeader,
+	)
+	res, req, err := reqAndRes("/testfwd")
+	r.NoError(err)
+	req.Host = host
+
+	hdl.ServeHTTP(res, req)
+
+	r.Equal(200, res.Code, "response code was unexpected")
+	r.Equal(host+"-deployment", <-waitedDeployments)
+}
+
+// the proxy should route requests by path prefix, and strip the prefix
+// before forwarding if the matching target says to
+func TestRoutesByPathPrefix(t *testing.T) {
+	r := require.New(t)
+
+	originHdl := kedanet.NewTestHTTPHandlerWrapper(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+	})
+	srv, originURL, err := kedanet.StartTestServer(originHdl)
+	r.NoError(err)
+	defer srv.Close()
+
+	timeouts := defaultTimeouts()
+	dialCtxFunc := retryDialContextFunc(timeouts, timeouts.DefaultBackoff())
+	waitedDeployments := make(chan string, 1)
+	waitFunc := func(_ context.Context, target routing.Target) error {
+		waitedDeployments <- target.ScaleTarget().Name
+		return nil
+	}
+	routingTable, err := newRoutingTableForURL(host, originURL)
+	r.NoError(err)
+	apiTarget, err := routingTable.Lookup(host, "/", nil)
+	r.NoError(err)
+	apiTarget.Deployment = "apideployment"
+	apiTarget.PathPrefix = "/api"
+	apiTarget.StripPrefix = true
+	routingTable.AddTarget(host, apiTarget)
+	hdl := newForwardingHandler(
+		routingTable,
+		dialCtxFunc,
+		waitFunc,
+		timeouts.DeploymentReplicas,
+		timeouts.ResponseHeader,
+	)
+	res, req, err := reqAndRes("/api/users")
+	r.NoError(err)
+	req.Host = host
+
+	hdl.ServeHTTP(res, req)
+
+	r.Equal(200, res.Code, "response code was unexpected")
+	r.Equal("apideployment", <-waitedDeployments)
+	forwardedRequests := originHdl.IncomingRequests()
+	r.Equal(1, len(forwardedRequests), "number of requests forwarded")
+	r.Equal("/users", forwardedRequests[0].URL.Path)
+}
+
+// the proxy should forward requests for paused targets without waiting
+// for them to have replicas
+func TestDoesntWaitOnPausedTarget(t *testing.T) {
+	r := require.New(t)
+
+	originHdl := kedanet.NewTestHTTPHandlerWrapper(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+	})
+	srv, originURL, err := kedanet.StartTestServer(originHdl)
+	r.NoError(err)
+	defer srv.Close()
+
+	timeouts := defaultTimeouts()
+	dialCtxFunc := retryDialContextFunc(timeouts, timeouts.DefaultBackoff())
+	waited := false
+	waitFunc := func(context.Context, routing.Target) error {
+		waited = true
+		return nil
+	}
+	routingTable, err := newRoutingTableForURL(host, originURL)
+	r.NoError(err)
+	target, err := routingTable.Lookup(host, "/", nil)
+	r.NoError(err)
+	target.Paused = true
+	routingTable.AddTarget(host, target)
+	hdl := newForwardingHandler(
+		routingTable,
+		dialCtxFunc,
+		waitFunc,
+		timeouts.DeploymentReplicas,
+		timeouts.ResponseHeader,
+	)
+	res, req, err := reqAndRes("/testfwd")
+	r.NoError(err)
+	req.Host = host
+
+	hdl.ServeHTTP(res, req)
+
+	r.Equal(200, res.Code, "response code was unexpected")
+	r.False(waited, "the wait function was called for a paused target")
+	r.Equal(1, len(originHdl.IncomingRequests()), "number of requests forwarded")
+}
+
+// newRoutingTableForURL returns a routing table that routes requests for
+// host to the host and port in u, and waits on a deployment called
+// "<host>-deployment"
+func newRoutingTableForURL(host string, u *url.URL) (*routing.Table, error) {
+	svc, portStr, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, err
+	}
+	table := routing.NewTable()
+	table.AddTarget(host, routing.NewTarget(svc, port, host+"-deployment"))
+	return table, nil
+}
+
 // ensureSignalAfter returns true if signalCh receives before timeout, false otherwise.
 // it blocks for timeout at most
 func ensureSignalBeforeTimeout(signalCh <-chan struct{}, timeout time.Duration) bool {
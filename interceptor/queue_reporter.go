@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/kedacore/http-add-on/pkg/http"
+	externalscaler "github.com/kedacore/http-add-on/proto"
+)
+
+// reportingQueueCounter is an http.QueueCounter that keeps track of every
+// change made to it since the last time those changes were taken, so that
+// they can be pushed to the scaler
+type reportingQueueCounter struct {
+	http.QueueCounter
+	mut    *sync.Mutex
+	deltas map[string]int
+	// changedCh receives a value every time the counts change. It's
+	// buffered, so changes made while nobody is receiving are signaled
+	// only once
+	changedCh chan struct{}
+}
+
+func newReportingQueueCounter(q http.QueueCounter) *reportingQueueCounter {
+	return &reportingQueueCounter{
+		QueueCounter: q,
+		mut:          new(sync.Mutex),
+		deltas:       map[string]int{},
+		changedCh:    make(chan struct{}, 1),
+	}
+}
+
+func (r *reportingQueueCounter) Resize(key string, delta int) error {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	if err := r.QueueCounter.Resize(key, delta); err != nil {
+		return err
+	}
+	r.deltas[key] += delta
+	select {
+	case r.changedCh <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// snapshot returns the full counts and discards the changes that
+// haven't been taken yet, since they're already included in the counts
+func (r *reportingQueueCounter) snapshot() (map[string]int, error) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	counts, err := r.QueueCounter.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	r.deltas = map[string]int{}
+	return counts, nil
+}
+
+// takeDeltas returns the changes made to the counts since the last call
+// to takeDeltas or snapshot. Keys whose changes canceled out are left out
+func (r *reportingQueueCounter) takeDeltas() map[string]int {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	ret := map[string]int{}
+	for key, delta := range r.deltas {
+		if delta != 0 {
+			ret[key] = delta
+		}
+	}
+	r.deltas = map[string]int{}
+	return ret
+}
+
+func toCountsUpdate(id string, counts map[string]int, snapshot bool) *externalscaler.QueueCountsUpdate {
+	protoCounts := make(map[string]int64, len(counts))
+	for key, count := range counts {
+		protoCounts[key] = int64(count)
+	}
+	return &externalscaler.QueueCountsUpdate{
+		InterceptorID: id,
+		Counts:        protoCounts,
+		Snapshot:      snapshot,
+	}
+}
+
+// reportCounts opens a QueueReporter stream to the scaler, sends a
+// snapshot of q's counts, and then sends q's changes as soon as they
+// happen. It sends another snapshot every snapshotInterval, so that the
+// scaler's counts can't drift from q's. It returns when ctx is done or
+// the stream fails
+func reportCounts(
+	ctx context.Context,
+	q *reportingQueueCounter,
+	cl externalscaler.QueueReporterClient,
+	id string,
+	snapshotInterval time.Duration,
+) error {
+	stream, err := cl.ReportCounts(ctx)
+	if err != nil {
+		return err
+	}
+	sendSnapshot := func() error {
+		counts, err := q.snapshot()
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(toCountsUpdate(id, counts, true)); err != nil {
+			_, err = stream.CloseAndRecv()
+			return err
+		}
+		return nil
+	}
+	if err := sendSnapshot(); err != nil {
+		return err
+	}
+	ticker := time.NewTicker(snapshotInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			stream.CloseAndRecv()
+			return ctx.Err()
+		case <-ticker.C:
+			if err := sendSnapshot(); err != nil {
+				return err
+			}
+		case <-q.changedCh:
+			deltas := q.takeDeltas()
+			if len(deltas) == 0 {
+				continue
+			}
+			if err := stream.Send(toCountsUpdate(id, deltas, false)); err != nil {
+				// Send returns io.EOF when the stream was closed, and the
+				// reason it was closed comes from CloseAndRecv
+				_, err = stream.CloseAndRecv()
+				return err
+			}
+		}
+	}
+}
+
+// startQueueReportLoop pushes q's counts to the scaler with reportCounts,
+// reconnecting reconnectInterval after every failure. It sends a full
+// snapshot of q's counts every snapshotInterval.
+//
+// This function blocks until ctx is done, so you'll usually want to call
+// it in a goroutine
+func startQueueReportLoop(
+	ctx context.Context,
+	q *reportingQueueCounter,
+	cl externalscaler.QueueReporterClient,
+	id string,
+	reconnectInterval time.Duration,
+	snapshotInterval time.Duration,
+) error {
+	for {
+		err := reportCounts(ctx, q, cl, id, snapshotInterval)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		log.Printf(
+			"Reporting counts to the scaler failed, reconnecting in %s (%s)",
+			reconnectInterval,
+			err,
+		)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(reconnectInterval):
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/kedacore/http-add-on/pkg/http"
+	externalscaler "github.com/kedacore/http-add-on/proto"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fakeQueueReporterServer is an externalscaler.QueueReporterServer that
+// sends every update it receives down a channel
+type fakeQueueReporterServer struct {
+	updateCh chan *externalscaler.QueueCountsUpdate
+	externalscaler.UnimplementedQueueReporterServer
+}
+
+func (f *fakeQueueReporterServer) ReportCounts(
+	stream externalscaler.QueueReporter_ReportCountsServer,
+) error {
+	for {
+		update, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		f.updateCh <- update
+	}
+}
+
+func TestReportingQueueCounterDeltas(t *testing.T) {
+	r := require.New(t)
+	q := newReportingQueueCounter(http.NewMemoryQueue())
+	r.NoError(q.Resize("a.com", 2))
+	r.NoError(q.Resize("b.com", 1))
+	r.NoError(q.Resize("b.com", -1))
+	r.Equal(map[string]int{"a.com": 2}, q.takeDeltas())
+	r.Equal(map[string]int{}, q.takeDeltas())
+
+	r.NoError(q.Resize("a.com", 1))
+	snap, err := q.snapshot()
+	r.NoError(err)
+	r.Equal(map[string]int{"a.com": 3}, snap)
+	// the snapshot already has the change in it
+	r.Equal(map[string]int{}, q.takeDeltas())
+}
+
+func TestReportCounts(t *testing.T) {
+	r := require.New(t)
+	ctx, done := context.WithCancel(context.Background())
+	defer done()
+
+	lis := bufconn.Listen(1024 * 1024)
+	fakeSrv := &fakeQueueReporterServer{
+		updateCh: make(chan *externalscaler.QueueCountsUpdate, 100),
+	}
+	srv := grpc.NewServer()
+	externalscaler.RegisterQueueReporterServer(srv, fakeSrv)
+	go srv.Serve(lis)
+	defer srv.Stop()
+	conn, err := grpc.DialContext(
+		ctx,
+		"bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return lis.Dial()
+		}),
+		grpc.WithInsecure(),
+	)
+	r.NoError(err)
+	defer conn.Close()
+
+	q := newReportingQueueCounter(http.NewMemoryQueue())
+	r.NoError(q.Resize("a.com", 1))
+	go startQueueReportLoop(
+		ctx,
+		q,
+		externalscaler.NewQueueReporterClient(conn),
+		"1.2.3.4",
+		time.Millisecond,
+		100*time.Millisecond,
+	)
+
+	nextUpdate := func() *externalscaler.QueueCountsUpdate {
+		select {
+		case update := <-fakeSrv.updateCh:
+			return update
+		case <-time.After(time.Second):
+			r.FailNow("no update was pushed")
+			return nil
+		}
+	}
+
+	// the first update is a snapshot of what's already been counted
+	update := nextUpdate()
+	r.True(update.Snapshot)
+	r.Equal("1.2.3.4", update.InterceptorID)
+	r.Equal(map[string]int64{"a.com": 1}, update.Counts)
+
+	// then changes are pushed as they happen
+	r.NoError(q.Resize("a.com", -1))
+	update = nextUpdate()
+	r.False(update.Snapshot)
+	r.Equal("1.2.3.4", update.InterceptorID)
+	r.Equal(map[string]int64{"a.com": -1}, update.Counts)
+
+	// and the full counts are sent again every snapshot interval
+	update = nextUpdate()
+	r.True(update.Snapshot)
+	r.Empty(update.Counts)
+}
@@ -42,7 +42,7 @@ func retryDialContextFunc(
 	backoff wait.Backoff,
 ) kedanet.DialContextFunc {
 	dialer := kedanet.NewNetDialer(timeouts.Connect, timeouts.KeepAlive)
-	return kedanet.DialContextWithRetry(dialer, backoff)
+	return kedanet.DialContextWithRetry(dialer, backoff, nil)
 }
 
 func reqAndRes(path string) (*httptest.ResponseRecorder, *http.Request, error) {
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/kedacore/http-add-on/pkg/k8s"
+	"github.com/kedacore/http-add-on/pkg/routing"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+)
+
+// scaleResult is the result of getting a scale target's /scale
+// subresource
+type scaleResult struct {
+	scale *autoscalingv1.Scale
+	err   error
+}
+
+// scalePoller polls the /scale subresources of scale targets other than
+// Deployments, which can't be watched through a DeploymentCache. It polls
+// each target at most once every interval, no matter how many requests
+// are waiting on it, and only while at least one of them is. Use
+// newScalePoller to create one of these
+type scalePoller struct {
+	getter   k8s.ScaleGetter
+	interval time.Duration
+	mut      *sync.Mutex
+	polls    map[routing.ScaleTargetRef]*scalePoll
+}
+
+// scalePoll is the polling of a single scale target, which is shared by
+// everyone watching it
+type scalePoll struct {
+	cancel   context.CancelFunc
+	watchers map[chan scaleResult]struct{}
+	// last is the last result the poll got, if it's gotten one yet
+	last *scaleResult
+}
+
+func newScalePoller(getter k8s.ScaleGetter, interval time.Duration) *scalePoller {
+	return &scalePoller{
+		getter:   getter,
+		interval: interval,
+		mut:      new(sync.Mutex),
+		polls:    map[routing.ScaleTargetRef]*scalePoll{},
+	}
+}
+
+// watch returns a channel that receives the result of every poll of
+// ref's /scale subresource, starting with the latest one, and a function
+// to stop receiving them. The channel is buffered, so a receiver that
+// falls behind only gets the latest result
+func (p *scalePoller) watch(ref routing.ScaleTargetRef) (<-chan scaleResult, func()) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	poll, ok := p.polls[ref]
+	if !ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		poll = &scalePoll{
+			cancel:   cancel,
+			watchers: map[chan scaleResult]struct{}{},
+		}
+		p.polls[ref] = poll
+		go p.run(ctx, ref, poll)
+	}
+	ch := make(chan scaleResult, 1)
+	poll.watchers[ch] = struct{}{}
+	if poll.last != nil {
+		ch <- *poll.last
+	}
+	return ch, func() {
+		p.mut.Lock()
+		defer p.mut.Unlock()
+		delete(poll.watchers, ch)
+		if len(poll.watchers) == 0 && p.polls[ref] == poll {
+			poll.cancel()
+			delete(p.polls, ref)
+		}
+	}
+}
+
+// run polls ref's /scale subresource every p.interval and sends each
+// result to poll's watchers, until ctx is done
+func (p *scalePoller) run(ctx context.Context, ref routing.ScaleTargetRef, poll *scalePoll) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for ctx.Err() == nil {
+		scale, err := p.getter.GetScale(ctx, ref.APIVersion, ref.Kind, ref.Name)
+		res := scaleResult{scale: scale, err: err}
+		p.mut.Lock()
+		poll.last = &res
+		for ch := range poll.watchers {
+			// replace any result the watcher hasn't received yet
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- res
+		}
+		p.mut.Unlock()
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+		}
+	}
+}
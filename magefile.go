@@ -325,6 +325,7 @@ func (Scaler) GenerateProto() error {
 		"--go-grpc_opt",
 		"paths=source_relative",
 		"proto/scaler.proto",
+		"proto/queue.proto",
 	); err != nil {
 		return err
 	}
@@ -0,0 +1,140 @@
+package v1alpha1
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/kedacore/http-add-on/operator/api/v1alpha2"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+)
+
+// V1alpha2SpecAnnotation is the annotation that holds the v1alpha2 spec
+// of an HTTPScaledObject that can't be fully expressed in v1alpha1, like
+// one with several targets. It's what lets such an HTTPScaledObject be
+// read and written as v1alpha1 without losing the rest of its spec
+const V1alpha2SpecAnnotation = "http.keda.sh/v1alpha2-spec"
+
+var _ conversion.Convertible = &HTTPScaledObject{}
+
+// ConvertTo converts httpso to the v1alpha2 HTTPScaledObject in dstRaw.
+// The fields that v1alpha1 has are taken from httpso, and the rest are
+// restored from the V1alpha2SpecAnnotation if httpso has it
+func (httpso *HTTPScaledObject) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1alpha2.HTTPScaledObject)
+	httpso.ObjectMeta.DeepCopyInto(&dst.ObjectMeta)
+	dst.Spec = v1alpha2.HTTPScaledObjectSpec{}
+	if raw, ok := httpso.Annotations[V1alpha2SpecAnnotation]; ok {
+		if err := json.Unmarshal([]byte(raw), &dst.Spec); err != nil {
+			return fmt.Errorf("decoding annotation %s: %w", V1alpha2SpecAnnotation, err)
+		}
+		delete(dst.Annotations, V1alpha2SpecAnnotation)
+		if len(dst.Annotations) == 0 {
+			dst.Annotations = nil
+		}
+	}
+	httpso.Spec.convertTo(&dst.Spec)
+	dst.Status = v1alpha2.HTTPScaledObjectStatus{
+		ObservedGeneration: httpso.Status.ObservedGeneration,
+		Conditions:         copyConditions(httpso.Status.Conditions),
+	}
+	return nil
+}
+
+// ConvertFrom converts the v1alpha2 HTTPScaledObject in srcRaw to
+// httpso. If the v1alpha2 spec has anything in it that v1alpha1 can't
+// express, all of it is kept in the V1alpha2SpecAnnotation
+func (httpso *HTTPScaledObject) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1alpha2.HTTPScaledObject)
+	src.ObjectMeta.DeepCopyInto(&httpso.ObjectMeta)
+	httpso.Spec = HTTPScaledObjectSpec{
+		Replicas: ReplicaStruct{
+			Min: src.Spec.ScalingPolicy.Replicas.Min,
+			Max: src.Spec.ScalingPolicy.Replicas.Max,
+		},
+		TargetPendingRequests: src.Spec.ScalingPolicy.TargetPendingRequests,
+	}
+	if len(src.Spec.Targets) > 0 {
+		httpso.Spec.ScaleTargetRef = scaleTargetRefFrom(src.Spec.Targets[0])
+	}
+	httpso.Status = HTTPScaledObjectStatus{
+		ObservedGeneration: src.Status.ObservedGeneration,
+		Conditions:         copyConditions(src.Status.Conditions),
+	}
+
+	var roundTrip v1alpha2.HTTPScaledObjectSpec
+	httpso.Spec.convertTo(&roundTrip)
+	if !equality.Semantic.DeepEqual(roundTrip, src.Spec) {
+		raw, err := json.Marshal(src.Spec)
+		if err != nil {
+			return err
+		}
+		if httpso.Annotations == nil {
+			httpso.Annotations = map[string]string{}
+		}
+		httpso.Annotations[V1alpha2SpecAnnotation] = string(raw)
+	}
+	return nil
+}
+
+// convertTo sets the fields in dst that v1alpha1 has to the values in
+// spec, and leaves the rest of dst alone. The first target in dst, if
+// there is one, keeps its weight
+func (spec HTTPScaledObjectSpec) convertTo(dst *v1alpha2.HTTPScaledObjectSpec) {
+	dst.ScalingPolicy.Replicas = v1alpha2.ReplicaStruct{
+		Min: spec.Replicas.Min,
+		Max: spec.Replicas.Max,
+	}
+	dst.ScalingPolicy.TargetPendingRequests = spec.TargetPendingRequests
+	if spec.ScaleTargetRef == nil {
+		return
+	}
+	target := spec.ScaleTargetRef.targetRef()
+	if len(dst.Targets) == 0 {
+		dst.Targets = []v1alpha2.TargetRef{target}
+		return
+	}
+	target.Weight = dst.Targets[0].Weight
+	dst.Targets[0] = target
+}
+
+// targetRef converts s to a v1alpha2 TargetRef
+func (s ScaleTargetRef) targetRef() v1alpha2.TargetRef {
+	return v1alpha2.TargetRef{
+		APIVersion: s.APIVersion,
+		Kind:       s.Kind,
+		Name:       s.TargetName(),
+		Service:    s.Service,
+		Port:       s.Port,
+	}
+}
+
+// scaleTargetRefFrom converts target to a v1alpha1 ScaleTargetRef. If
+// target doesn't say what kind of resource it is, it's a Deployment, so
+// it's named with the deployment field
+func scaleTargetRefFrom(target v1alpha2.TargetRef) *ScaleTargetRef {
+	ret := &ScaleTargetRef{
+		APIVersion: target.APIVersion,
+		Kind:       target.Kind,
+		Service:    target.Service,
+		Port:       target.Port,
+	}
+	if target.APIVersion == "" && target.Kind == "" {
+		ret.Deployment = target.Name
+	} else {
+		ret.Name = target.Name
+	}
+	return ret
+}
+
+func copyConditions(conditions []metav1.Condition) []metav1.Condition {
+	if conditions == nil {
+		return nil
+	}
+	ret := make([]metav1.Condition, len(conditions))
+	for i := range conditions {
+		conditions[i].DeepCopyInto(&ret[i])
+	}
+	return ret
+}
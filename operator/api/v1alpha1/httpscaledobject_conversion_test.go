@@ -0,0 +1,104 @@
+package v1alpha1
+
+import (
+	"testing"
+
+	"github.com/kedacore/http-add-on/operator/api/v1alpha2"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestConvertToAndFrom(t *testing.T) {
+	r := require.New(t)
+	httpso := &HTTPScaledObject{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "testns",
+			Name:        "testso",
+			Annotations: map[string]string{"keep": "me"},
+		},
+		Spec: HTTPScaledObjectSpec{
+			ScaleTargetRef: &ScaleTargetRef{
+				Deployment: "testdepl",
+				Service:    "testsvc",
+				Port:       8080,
+			},
+			Replicas:              ReplicaStruct{Min: 1, Max: 10},
+			TargetPendingRequests: 50,
+		},
+		Status: HTTPScaledObjectStatus{
+			ObservedGeneration: 2,
+			Conditions: []metav1.Condition{
+				{Type: string(Ready), Status: metav1.ConditionTrue},
+			},
+		},
+	}
+
+	hub := &v1alpha2.HTTPScaledObject{}
+	r.NoError(httpso.ConvertTo(hub))
+	r.Equal(httpso.ObjectMeta, hub.ObjectMeta)
+	r.Equal([]v1alpha2.TargetRef{
+		{Name: "testdepl", Service: "testsvc", Port: 8080},
+	}, hub.Spec.Targets)
+	r.Equal(v1alpha2.ScalingPolicy{
+		Replicas:              v1alpha2.ReplicaStruct{Min: 1, Max: 10},
+		TargetPendingRequests: 50,
+	}, hub.Spec.ScalingPolicy)
+	r.Equal(int64(2), hub.Status.ObservedGeneration)
+	r.Equal(httpso.Status.Conditions, hub.Status.Conditions)
+
+	// a spec that v1alpha1 can express shouldn't need an annotation
+	back := &HTTPScaledObject{}
+	r.NoError(back.ConvertFrom(hub))
+	r.Equal(httpso, back)
+}
+
+func TestConvertFromLossy(t *testing.T) {
+	r := require.New(t)
+	weight := int32(9)
+	hub := &v1alpha2.HTTPScaledObject{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "testns",
+			Name:      "testso",
+		},
+		Spec: v1alpha2.HTTPScaledObjectSpec{
+			Hosts: []string{"testhost.com"},
+			Paths: []v1alpha2.PathRule{{Prefix: "/api", StripPrefix: true}},
+			Targets: []v1alpha2.TargetRef{
+				{
+					Kind:    "StatefulSet",
+					Name:    "stable",
+					Service: "stablesvc",
+					Port:    8080,
+					Weight:  &weight,
+				},
+				{Name: "canary", Service: "canarysvc", Port: 8080},
+			},
+		},
+	}
+
+	httpso := &HTTPScaledObject{}
+	r.NoError(httpso.ConvertFrom(hub))
+	r.Equal(&ScaleTargetRef{
+		Kind:    "StatefulSet",
+		Name:    "stable",
+		Service: "stablesvc",
+		Port:    8080,
+	}, httpso.Spec.ScaleTargetRef)
+	r.Contains(httpso.Annotations, V1alpha2SpecAnnotation)
+
+	// converting back should restore everything, without the annotation
+	roundTrip := &v1alpha2.HTTPScaledObject{}
+	r.NoError(httpso.ConvertTo(roundTrip))
+	r.Equal(hub, roundTrip)
+
+	// changes made through v1alpha1 should apply to the first target and
+	// leave the rest alone
+	httpso.Spec.ScaleTargetRef.Port = 9090
+	httpso.Spec.Replicas.Max = 5
+	r.NoError(httpso.ConvertTo(roundTrip))
+	r.Equal(int32(9090), roundTrip.Spec.Targets[0].Port)
+	r.Equal(&weight, roundTrip.Spec.Targets[0].Weight)
+	r.Equal(int32(5), roundTrip.Spec.ScalingPolicy.Replicas.Max)
+	r.Equal(hub.Spec.Targets[1], roundTrip.Spec.Targets[1])
+	r.Equal(hub.Spec.Hosts, roundTrip.Spec.Hosts)
+}
@@ -20,83 +20,49 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-// HTTPScaledObjectCreationStatus describes the creation status
-// of the scaler's additional resources such as Services, Ingresses and Deployments
-// +kubebuilder:validation:Enum=Created;Error;Pending;Unknown;Terminating;Terminated;Ready
-type HTTPScaledObjectCreationStatus string
-
-// HTTPScaledObjectConditionReason describes the reason why the condition transitioned
-// +kubebuilder:validation:Enum=ErrorCreatingExternalScaler;ErrorCreatingExternalScalerService;CreatedExternalScaler;ErrorCreatingInterceptorScaledObject;ErrorCreatingAppScaledObject;AppScaledObjectCreated;InterceptorScaledObjectCreated;ErrorCreatingInterceptor;ErrorCreatingInterceptorAdminService;ErrorCreatingInterceptorProxyService;InterceptorCreated;TerminatingResources;InterceptorDeploymentTerminated;InterceptorDeploymentTerminationError;InterceptorAdminServiceTerminationError;InterceptorAdminServiceTerminated;InterceptorProxyServiceTerminationError;InterceptorProxyServiceTerminated;ExternalScalerDeploymentTerminationError;ExternalScalerDeploymentTerminated;ExternalScalerServiceTerminationError;ExternalScalerServiceTerminated;InterceptorScaledObjectTerminated;AppScaledObjectTerminated;AppScaledObjectTerminationError;InterceptorScaledObjectTerminationError;PendingCreation;HTTPScaledObjectIsReady;
-type HTTPScaledObjectConditionReason string
+// HTTPScaledObjectConditionType is the type of a status condition on an
+// HTTPScaledObject. An HTTPScaledObject has at most one condition of each
+// type
+type HTTPScaledObjectConditionType string
 
 const (
-	ErrorCreatingExternalScaler              HTTPScaledObjectConditionReason = "ErrorCreatingExternalScaler"
-	ErrorCreatingExternalScalerService       HTTPScaledObjectConditionReason = "ErrorCreatingExternalScalerService"
-	CreatedExternalScaler                    HTTPScaledObjectConditionReason = "CreatedExternalScaler"
-	ErrorCreatingInterceptorScaledObject     HTTPScaledObjectConditionReason = "ErrorCreatingInterceptorScaledObject"
-	ErrorCreatingAppScaledObject             HTTPScaledObjectConditionReason = "ErrorCreatingAppScaledObject"
-	AppScaledObjectCreated                   HTTPScaledObjectConditionReason = "AppScaledObjectCreated"
-	InterceptorScaledObjectCreated           HTTPScaledObjectConditionReason = "InterceptorScaledObjectCreated"
-	ErrorCreatingInterceptor                 HTTPScaledObjectConditionReason = "ErrorCreatingInterceptor"
-	ErrorCreatingInterceptorAdminService     HTTPScaledObjectConditionReason = "ErrorCreatingInterceptorAdminService"
-	ErrorCreatingInterceptorProxyService     HTTPScaledObjectConditionReason = "ErrorCreatingInterceptorProxyService"
-	InterceptorCreated                       HTTPScaledObjectConditionReason = "InterceptorCreated"
-	TerminatingResources                     HTTPScaledObjectConditionReason = "TerminatingResources"
-	InterceptorDeploymentTerminated          HTTPScaledObjectConditionReason = "InterceptorDeploymentTerminated"
-	InterceptorDeploymentTerminationError    HTTPScaledObjectConditionReason = "InterceptorDeploymentTerminationError"
-	InterceptorAdminServiceTerminationError  HTTPScaledObjectConditionReason = "InterceptorAdminServiceTerminationError"
-	InterceptorAdminServiceTerminated        HTTPScaledObjectConditionReason = "InterceptorAdminServiceTerminated"
-	InterceptorProxyServiceTerminationError  HTTPScaledObjectConditionReason = "InterceptorProxyServiceTerminationError"
-	InterceptorProxyServiceTerminated        HTTPScaledObjectConditionReason = "InterceptorProxyServiceTerminated"
-	ExternalScalerDeploymentTerminationError HTTPScaledObjectConditionReason = "ExternalScalerDeploymentTerminationError"
-	ExternalScalerDeploymentTerminated       HTTPScaledObjectConditionReason = "ExternalScalerDeploymentTerminated"
-	ExternalScalerServiceTerminationError    HTTPScaledObjectConditionReason = "ExternalScalerServiceTerminationError"
-	ExternalScalerServiceTerminated          HTTPScaledObjectConditionReason = "ExternalScalerServiceTerminated"
-	InterceptorScaledObjectTerminated        HTTPScaledObjectConditionReason = "InterceptorScaledObjectTerminated"
-	AppScaledObjectTerminated                HTTPScaledObjectConditionReason = "AppScaledObjectTerminated"
-	AppScaledObjectTerminationError          HTTPScaledObjectConditionReason = "AppScaledObjectTerminationError"
-	InterceptorScaledObjectTerminationError  HTTPScaledObjectConditionReason = "InterceptorScaledObjectTerminationError"
-	PendingCreation                          HTTPScaledObjectConditionReason = "PendingCreation"
-	HTTPScaledObjectIsReady                  HTTPScaledObjectConditionReason = "HTTPScaledObjectIsReady"
+	// ExternalScalerReady indicates whether the external scaler's
+	// deployment and service are up to date
+	ExternalScalerReady HTTPScaledObjectConditionType = "ExternalScalerReady"
+	// InterceptorReady indicates whether the interceptor's deployment
+	// and services are up to date
+	InterceptorReady HTTPScaledObjectConditionType = "InterceptorReady"
+	// AppScaledObjectReady indicates whether the KEDA ScaledObject for
+	// the app is up to date
+	AppScaledObjectReady HTTPScaledObjectConditionType = "AppScaledObjectReady"
+	// InterceptorScaledObjectReady indicates whether the KEDA
+	// ScaledObject for the interceptor is up to date
+	InterceptorScaledObjectReady HTTPScaledObjectConditionType = "InterceptorScaledObjectReady"
+	// Ready indicates whether everything that the HTTPScaledObject needs
+	// is up to date
+	Ready HTTPScaledObjectConditionType = "Ready"
 )
 
+// HTTPScaledObjectConditionReason describes the reason why the condition transitioned
+type HTTPScaledObjectConditionReason string
+
 const (
-	// Created indicates the resource has been created
-	Created HTTPScaledObjectCreationStatus = "Created"
-	// Terminated indicates the resource has been terminated
-	Terminated HTTPScaledObjectCreationStatus = "Terminated"
-	// Error indicates the resource had an error
-	Error HTTPScaledObjectCreationStatus = "Error"
-	// Pending indicates the resource hasn't been created
-	Pending HTTPScaledObjectCreationStatus = "Pending"
-	// Terminating indicates that the resource is marked for deletion but hasn't
-	// been deleted yet
-	Terminating HTTPScaledObjectCreationStatus = "Terminating"
-	// Unknown indicates the status is unavailable
-	Unknown HTTPScaledObjectCreationStatus = "Unknown"
-	// Ready indicates the object is fully created
-	Ready HTTPScaledObjectCreationStatus = "Ready"
+	ErrorCreatingExternalScaler          HTTPScaledObjectConditionReason = "ErrorCreatingExternalScaler"
+	ErrorCreatingExternalScalerService   HTTPScaledObjectConditionReason = "ErrorCreatingExternalScalerService"
+	CreatedExternalScaler                HTTPScaledObjectConditionReason = "CreatedExternalScaler"
+	ErrorCreatingInterceptorScaledObject HTTPScaledObjectConditionReason = "ErrorCreatingInterceptorScaledObject"
+	ErrorCreatingAppScaledObject         HTTPScaledObjectConditionReason = "ErrorCreatingAppScaledObject"
+	AppScaledObjectCreated               HTTPScaledObjectConditionReason = "AppScaledObjectCreated"
+	InterceptorScaledObjectCreated       HTTPScaledObjectConditionReason = "InterceptorScaledObjectCreated"
+	ErrorCreatingInterceptor             HTTPScaledObjectConditionReason = "ErrorCreatingInterceptor"
+	ErrorCreatingInterceptorAdminService HTTPScaledObjectConditionReason = "ErrorCreatingInterceptorAdminService"
+	ErrorCreatingInterceptorProxyService HTTPScaledObjectConditionReason = "ErrorCreatingInterceptorProxyService"
+	InterceptorCreated                   HTTPScaledObjectConditionReason = "InterceptorCreated"
+	ErrorCreatingAppResources            HTTPScaledObjectConditionReason = "ErrorCreatingAppResources"
+	PendingCreation                      HTTPScaledObjectConditionReason = "PendingCreation"
+	HTTPScaledObjectIsReady              HTTPScaledObjectConditionReason = "HTTPScaledObjectIsReady"
 )
 
-// Condition to store the condition state
-type HTTPScaledObjectCondition struct {
-	// Timestamp of the condition
-	// +optional
-	Timestamp string `json:"timestamp" description:"Timestamp of this condition"`
-	// Type of condition
-	// +required
-	Type HTTPScaledObjectCreationStatus `json:"type" description:"type of status condition"`
-	// Status of the condition, one of True, False, Unknown.
-	// +required
-	Status metav1.ConditionStatus `json:"status" description:"status of the condition, one of True, False, Unknown"`
-	// The reason for the condition's last transition.
-	// +optional
-	Reason HTTPScaledObjectConditionReason `json:"reason,omitempty" description:"one-word CamelCase reason for the condition's last transition"`
-	// A human readable message indicating details about the transition.
-	// +optional
-	Message string `json:"message,omitempty" description:"human-readable message indicating details about last transition"`
-}
-
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 // Important: Run "make" to regenerate code after modifying this file
 
@@ -115,22 +81,77 @@ type HTTPScaledObjectSpec struct {
 	// (optional) Replica information
 	//+optional
 	Replicas ReplicaStruct `json:"replicas,omitempty"`
+	// (optional) The number of pending requests per replica that the app
+	// should be scaled to handle (Default 100)
+	//+optional
+	//+kubebuilder:validation:Minimum=1
+	TargetPendingRequests int32 `json:"targetPendingRequests,omitempty" description:"The number of pending requests per replica that the app should be scaled to handle (Default 100)"`
 }
 
 // ScaleTargetRef contains all the details about an HTTP application to scale and route to
 type ScaleTargetRef struct {
-	// The name of the deployment to scale according to HTTP traffic
-	Deployment string `json:"deployment"`
+	// The name of the deployment to scale according to HTTP traffic. Either this
+	// or Name must be set
+	//+optional
+	Deployment string `json:"deployment,omitempty"`
+	// (optional) The API version of the resource to scale according to HTTP
+	// traffic (Default apps/v1)
+	//+optional
+	APIVersion string `json:"apiVersion,omitempty" description:"The API version of the resource to scale (Default apps/v1)"`
+	// (optional) The kind of the resource to scale according to HTTP traffic.
+	// It must have a /scale subresource (Default Deployment)
+	//+optional
+	Kind string `json:"kind,omitempty" description:"The kind of the resource to scale (Default Deployment)"`
+	// (optional) The name of the resource to scale according to HTTP traffic.
+	// Use this instead of Deployment for resources other than Deployments
+	//+optional
+	Name string `json:"name,omitempty" description:"The name of the resource to scale"`
 	// The name of the service to route to
 	Service string `json:"service"`
 	// The port to route to
 	Port int32 `json:"port"`
 }
 
+// TargetAPIVersion returns the API version of the resource to scale,
+// defaulting to apps/v1
+func (s ScaleTargetRef) TargetAPIVersion() string {
+	if s.APIVersion != "" {
+		return s.APIVersion
+	}
+	return "apps/v1"
+}
+
+// TargetKind returns the kind of the resource to scale, defaulting to
+// Deployment
+func (s ScaleTargetRef) TargetKind() string {
+	if s.Kind != "" {
+		return s.Kind
+	}
+	return "Deployment"
+}
+
+// TargetName returns the name of the resource to scale. That's Name if
+// it's set, and Deployment otherwise
+func (s ScaleTargetRef) TargetName() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return s.Deployment
+}
+
 // HTTPScaledObjectStatus defines the observed state of HTTPScaledObject
 type HTTPScaledObjectStatus struct {
-	// List of auditable conditions of the operator
-	Conditions []HTTPScaledObjectCondition `json:"conditions,omitempty" description:"List of auditable conditions of the operator"`
+	// The generation of the HTTPScaledObject that the operator last reconciled
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty" description:"The generation of the HTTPScaledObject that the operator last reconciled"`
+	// The current state of the HTTPScaledObject, with at most one condition
+	// of each type
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" description:"The current state of the HTTPScaledObject, with at most one condition of each type"`
 }
 
 // +kubebuilder:object:root=true
@@ -145,7 +166,7 @@ type HTTPScaledObjectStatus struct {
 // +kubebuilder:printcolumn:name="MinReplicas",type="integer",JSONPath=".spec.replicas.min"
 // +kubebuilder:printcolumn:name="MaxReplicas",type="integer",JSONPath=".spec.replicas.max"
 // +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
-// +kubebuilder:printcolumn:name="Active",type="string",JSONPath=".status.conditions[?(@.type==\"HTTPScaledObjectIsReady\")].status"
+// +kubebuilder:printcolumn:name="Active",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
 
 type HTTPScaledObject struct {
 	metav1.TypeMeta   `json:",inline"`
@@ -0,0 +1,104 @@
+package v1alpha2
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// SaveStatus will trigger an object update to save the current status
+// conditions. Conditions that the CRD's schema would reject are dropped
+// first; see pruneConditions
+func (httpso *HTTPScaledObject) SaveStatus(
+	ctx context.Context,
+	logger logr.Logger,
+	cl client.Client,
+) {
+	httpso.pruneConditions()
+	logger.Info("Updating status on HTTPScaledObject", "resource version", httpso.ResourceVersion)
+
+	err := cl.Status().Update(ctx, httpso)
+	if err != nil {
+		logger.Error(err, "failed to update status on HTTPScaledObject", "httpso", httpso)
+	} else {
+		logger.Info("Updated status on HTTPScaledObject", "resource version", httpso.ResourceVersion)
+	}
+}
+
+// SetCondition sets the condition of type condType on the HTTPScaledObject,
+// replacing the existing condition of that type if there is one. The
+// condition's LastTransitionTime only changes when its status does
+func (httpso *HTTPScaledObject) SetCondition(
+	condType HTTPScaledObjectConditionType,
+	status metav1.ConditionStatus,
+	reason HTTPScaledObjectConditionReason,
+	message string,
+) *HTTPScaledObject {
+	httpso.pruneConditions()
+	meta.SetStatusCondition(&httpso.Status.Conditions, metav1.Condition{
+		Type:               string(condType),
+		Status:             status,
+		ObservedGeneration: httpso.Generation,
+		Reason:             string(reason),
+		Message:            message,
+	})
+	return httpso
+}
+
+// GetCondition returns the condition of type condType on the
+// HTTPScaledObject, or nil if it doesn't have one
+func (httpso *HTTPScaledObject) GetCondition(
+	condType HTTPScaledObjectConditionType,
+) *metav1.Condition {
+	return meta.FindStatusCondition(httpso.Status.Conditions, string(condType))
+}
+
+// RemoveCondition removes the condition of type condType from the
+// HTTPScaledObject, if it has one
+func (httpso *HTTPScaledObject) RemoveCondition(
+	condType HTTPScaledObjectConditionType,
+) *HTTPScaledObject {
+	meta.RemoveStatusCondition(&httpso.Status.Conditions, string(condType))
+	return httpso
+}
+
+// knownConditionType returns whether t is one of the
+// HTTPScaledObjectConditionTypes
+func knownConditionType(t string) bool {
+	switch HTTPScaledObjectConditionType(t) {
+	case ExternalScalerReady,
+		InterceptorReady,
+		AppScaledObjectReady,
+		InterceptorScaledObjectReady,
+		IngressReady,
+		Paused,
+		Ready:
+		return true
+	}
+	return false
+}
+
+// pruneConditions drops the conditions that older versions of the
+// operator left behind, which the schema of the conditions list rejects:
+// conditions of types that aren't HTTPScaledObjectConditionTypes, like
+// Created, conditions with no LastTransitionTime, and all but the first
+// condition of each type. Without this, no status update of an
+// HTTPScaledObject created by an older operator would go through
+func (httpso *HTTPScaledObject) pruneConditions() {
+	conditions := httpso.Status.Conditions
+	pruned := conditions[:0]
+	seen := map[string]bool{}
+	for _, cond := range conditions {
+		if !knownConditionType(cond.Type) ||
+			cond.LastTransitionTime.IsZero() ||
+			seen[cond.Type] {
+			continue
+		}
+		seen[cond.Type] = true
+		pruned = append(pruned, cond)
+	}
+	httpso.Status.Conditions = pruned
+}
@@ -0,0 +1,92 @@
+package v1alpha2
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSetCondition(t *testing.T) {
+	r := require.New(t)
+	httpso := &HTTPScaledObject{
+		ObjectMeta: metav1.ObjectMeta{Generation: 1},
+	}
+	httpso.SetCondition(Ready, metav1.ConditionUnknown, PendingCreation, "pending")
+	httpso.SetCondition(InterceptorReady, metav1.ConditionTrue, InterceptorCreated, "created")
+	r.Equal(2, len(httpso.Status.Conditions))
+
+	ready := httpso.GetCondition(Ready)
+	r.NotNil(ready)
+	r.Equal(string(PendingCreation), ready.Reason)
+	r.Equal(int64(1), ready.ObservedGeneration)
+	r.False(ready.LastTransitionTime.IsZero())
+
+	// setting the same type again should replace the condition, not add one
+	transitioned := metav1.NewTime(ready.LastTransitionTime.Add(-1))
+	ready.LastTransitionTime = transitioned
+	httpso.Generation = 2
+	httpso.SetCondition(Ready, metav1.ConditionUnknown, PendingCreation, "still pending")
+	r.Equal(2, len(httpso.Status.Conditions))
+	ready = httpso.GetCondition(Ready)
+	r.Equal("still pending", ready.Message)
+	r.Equal(int64(2), ready.ObservedGeneration)
+	// the status didn't change, so neither should the transition time
+	r.Equal(transitioned, ready.LastTransitionTime)
+
+	httpso.SetCondition(Ready, metav1.ConditionTrue, HTTPScaledObjectIsReady, "ready")
+	ready = httpso.GetCondition(Ready)
+	r.Equal(metav1.ConditionTrue, ready.Status)
+	r.NotEqual(transitioned, ready.LastTransitionTime)
+
+	r.Nil(httpso.GetCondition(ExternalScalerReady))
+
+	httpso.RemoveCondition(InterceptorReady)
+	r.Nil(httpso.GetCondition(InterceptorReady))
+	r.Equal(1, len(httpso.Status.Conditions))
+}
+
+func TestSetConditionDropsLegacyConditions(t *testing.T) {
+	r := require.New(t)
+	// the status that older versions of the operator wrote, with repeated
+	// conditions of types that no longer exist and no transition times
+	const legacy = `{
+		"status": {
+			"conditions": [
+				{"type": "Created", "status": "True", "reason": "InterceptorCreated", "timestamp": "2021-06-01T00:00:00Z"},
+				{"type": "Created", "status": "True", "reason": "CreatedExternalScaler", "timestamp": "2021-06-01T00:00:01Z"},
+				{"type": "Ready", "status": "True", "reason": "HTTPScaledObjectIsReady", "timestamp": "2021-06-01T00:00:02Z"}
+			]
+		}
+	}`
+	httpso := &HTTPScaledObject{}
+	r.NoError(json.Unmarshal([]byte(legacy), httpso))
+	r.Equal(3, len(httpso.Status.Conditions))
+
+	httpso.SetCondition(InterceptorReady, metav1.ConditionTrue, InterceptorCreated, "created")
+	r.Equal(1, len(httpso.Status.Conditions))
+	r.NotNil(httpso.GetCondition(InterceptorReady))
+	r.Nil(httpso.GetCondition(Ready))
+
+	httpso.SetCondition(Ready, metav1.ConditionTrue, HTTPScaledObjectIsReady, "ready")
+	r.Equal(2, len(httpso.Status.Conditions))
+	for _, cond := range httpso.Status.Conditions {
+		r.False(cond.LastTransitionTime.IsZero(), "condition %s", cond.Type)
+	}
+
+	// a list that has duplicates of a known type keeps the first one
+	httpso.Status.Conditions = append(httpso.Status.Conditions, metav1.Condition{
+		Type:               string(Ready),
+		Status:             metav1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+	})
+	httpso.RemoveCondition(InterceptorReady).SetCondition(
+		IngressReady,
+		metav1.ConditionTrue,
+		HTTPScaledObjectIsReady,
+		"ready",
+	)
+	r.Equal(2, len(httpso.Status.Conditions))
+	r.Equal(metav1.ConditionTrue, httpso.GetCondition(Ready).Status)
+}
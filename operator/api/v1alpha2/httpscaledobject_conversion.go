@@ -0,0 +1,5 @@
+package v1alpha2
+
+// Hub marks v1alpha2 as the version that the other versions of
+// HTTPScaledObject convert to and from
+func (*HTTPScaledObject) Hub() {}
@@ -0,0 +1,414 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha2
+
+import (
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// HTTPScaledObjectConditionType is the type of a status condition on an
+// HTTPScaledObject. An HTTPScaledObject has at most one condition of each
+// type
+type HTTPScaledObjectConditionType string
+
+const (
+	// ExternalScalerReady indicates whether the external scaler's
+	// deployment and service are up to date
+	ExternalScalerReady HTTPScaledObjectConditionType = "ExternalScalerReady"
+	// InterceptorReady indicates whether the interceptor's deployment
+	// and services are up to date
+	InterceptorReady HTTPScaledObjectConditionType = "InterceptorReady"
+	// AppScaledObjectReady indicates whether the KEDA ScaledObjects for
+	// the app's targets are up to date
+	AppScaledObjectReady HTTPScaledObjectConditionType = "AppScaledObjectReady"
+	// InterceptorScaledObjectReady indicates whether the KEDA
+	// ScaledObject for the interceptor is up to date
+	InterceptorScaledObjectReady HTTPScaledObjectConditionType = "InterceptorScaledObjectReady"
+	// IngressReady indicates whether the Ingress or HTTPRoute that routes
+	// traffic from outside the cluster to the interceptor is up to date
+	IngressReady HTTPScaledObjectConditionType = "IngressReady"
+	// Paused indicates that the scaling of the app's targets is paused
+	// by the HTTPScaledObject's pause annotations. It's only present
+	// while they're paused
+	Paused HTTPScaledObjectConditionType = "Paused"
+	// Ready indicates whether everything that the HTTPScaledObject needs
+	// is up to date
+	Ready HTTPScaledObjectConditionType = "Ready"
+)
+
+// HTTPScaledObjectConditionReason describes the reason why the condition transitioned
+type HTTPScaledObjectConditionReason string
+
+const (
+	ErrorCreatingExternalScaler          HTTPScaledObjectConditionReason = "ErrorCreatingExternalScaler"
+	ErrorCreatingExternalScalerService   HTTPScaledObjectConditionReason = "ErrorCreatingExternalScalerService"
+	CreatedExternalScaler                HTTPScaledObjectConditionReason = "CreatedExternalScaler"
+	ErrorCreatingInterceptorScaledObject HTTPScaledObjectConditionReason = "ErrorCreatingInterceptorScaledObject"
+	ErrorCreatingAppScaledObject         HTTPScaledObjectConditionReason = "ErrorCreatingAppScaledObject"
+	AppScaledObjectCreated               HTTPScaledObjectConditionReason = "AppScaledObjectCreated"
+	InterceptorScaledObjectCreated       HTTPScaledObjectConditionReason = "InterceptorScaledObjectCreated"
+	ErrorCreatingInterceptor             HTTPScaledObjectConditionReason = "ErrorCreatingInterceptor"
+	ErrorCreatingInterceptorAdminService HTTPScaledObjectConditionReason = "ErrorCreatingInterceptorAdminService"
+	ErrorCreatingInterceptorProxyService HTTPScaledObjectConditionReason = "ErrorCreatingInterceptorProxyService"
+	InterceptorCreated                   HTTPScaledObjectConditionReason = "InterceptorCreated"
+	ErrorUpdatingRoutingTable            HTTPScaledObjectConditionReason = "ErrorUpdatingRoutingTable"
+	ErrorCreatingIngress                 HTTPScaledObjectConditionReason = "ErrorCreatingIngress"
+	IngressCreated                       HTTPScaledObjectConditionReason = "IngressCreated"
+	ScalingPaused                        HTTPScaledObjectConditionReason = "ScalingPaused"
+	ErrorCreatingAppResources            HTTPScaledObjectConditionReason = "ErrorCreatingAppResources"
+	PendingCreation                      HTTPScaledObjectConditionReason = "PendingCreation"
+	HTTPScaledObjectIsReady              HTTPScaledObjectConditionReason = "HTTPScaledObjectIsReady"
+)
+
+// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
+// Important: Run "make" to regenerate code after modifying this file
+
+// ReplicaStruct is the range of replica counts that each of an app's
+// targets is scaled within
+type ReplicaStruct struct {
+	// Minimum amount of replicas to have in each target (Default 0)
+	Min int32 `json:"min,omitempty" description:"Minimum amount of replicas to have in each target (Default 0)"`
+	// Maximum amount of replicas to have in each target (Default 100)
+	Max int32 `json:"max,omitempty" description:"Maximum amount of replicas to have in each target (Default 100)"`
+}
+
+// ScalingPolicy describes how an app's targets are scaled according to
+// HTTP traffic
+type ScalingPolicy struct {
+	// (optional) Replica information
+	//+optional
+	Replicas ReplicaStruct `json:"replicas,omitempty"`
+	// (optional) The number of pending requests per replica that the app
+	// should be scaled to handle (Default 100)
+	//+optional
+	//+kubebuilder:validation:Minimum=1
+	TargetPendingRequests int32 `json:"targetPendingRequests,omitempty" description:"The number of pending requests per replica that the app should be scaled to handle (Default 100)"`
+	// (optional) How often KEDA checks whether to scale from or to zero
+	// replicas, in seconds (Default 250)
+	//+optional
+	//+kubebuilder:validation:Minimum=1
+	PollingInterval *int32 `json:"pollingInterval,omitempty" description:"How often KEDA checks whether to scale from or to zero replicas, in seconds (Default 250)"`
+	// (optional) How long KEDA waits after the app last had pending
+	// requests before it scales it to zero, in seconds (Default 300)
+	//+optional
+	//+kubebuilder:validation:Minimum=0
+	CooldownPeriod *int32 `json:"cooldownPeriod,omitempty" description:"How long KEDA waits after the app last had pending requests before it scales it to zero, in seconds (Default 300)"`
+	// (optional) The scale up and scale down behavior of the
+	// HorizontalPodAutoscaler that KEDA creates, like stabilization
+	// windows and policies that limit how fast replicas are added or
+	// removed. If it's empty, the HorizontalPodAutoscaler's defaults apply
+	//+optional
+	Behavior *autoscalingv2beta2.HorizontalPodAutoscalerBehavior `json:"behavior,omitempty" description:"The scale up and scale down behavior of the HorizontalPodAutoscaler that KEDA creates"`
+	// (optional) Scheduled windows during which the app is kept at a
+	// minimum number of replicas, whatever its traffic, so that it's
+	// warm before a spike that's known to come
+	//+optional
+	PrewarmWindows []PrewarmWindow `json:"prewarmWindows,omitempty" description:"Scheduled windows during which the app is kept at a minimum number of replicas"`
+}
+
+// PrewarmWindow is a recurring window of time during which an app is
+// kept at a minimum number of replicas. It's rendered as a KEDA cron
+// trigger, so the schedules have the same format
+type PrewarmWindow struct {
+	// The IANA time zone that Start and End are in, like Europe/Berlin
+	//+kubebuilder:validation:MinLength=1
+	Timezone string `json:"timezone" description:"The IANA time zone that start and end are in"`
+	// The cron schedule (minute, hour, day of month, month and day of
+	// week) that the window starts on, like 0 9 * * 1-5
+	//+kubebuilder:validation:MinLength=1
+	Start string `json:"start" description:"The cron schedule that the window starts on"`
+	// The cron schedule that the window ends on, like 0 18 * * 1-5
+	//+kubebuilder:validation:MinLength=1
+	End string `json:"end" description:"The cron schedule that the window ends on"`
+	// The number of replicas that each target is kept at or above
+	// during the window
+	//+kubebuilder:validation:Minimum=1
+	MinReplicas int32 `json:"minReplicas" description:"The number of replicas that each target is kept at or above during the window"`
+}
+
+// PathRule restricts the requests that are routed to an app to the ones
+// under a path prefix
+type PathRule struct {
+	// The path prefix to route. It matches whole path segments, so /api
+	// matches /api and /api/users but not /apis
+	//+kubebuilder:validation:Pattern=`^/`
+	Prefix string `json:"prefix" description:"The path prefix to route"`
+	// (optional) Whether to remove the prefix from the path before
+	// forwarding requests to the app
+	//+optional
+	StripPrefix bool `json:"stripPrefix,omitempty" description:"Whether to remove the prefix from the path before forwarding requests"`
+}
+
+// TargetRef is a resource to scale according to HTTP traffic, along with
+// the service that routes to it
+type TargetRef struct {
+	// (optional) The API version of the resource to scale (Default apps/v1)
+	//+optional
+	APIVersion string `json:"apiVersion,omitempty" description:"The API version of the resource to scale (Default apps/v1)"`
+	// (optional) The kind of the resource to scale. It must have a /scale
+	// subresource (Default Deployment)
+	//+optional
+	Kind string `json:"kind,omitempty" description:"The kind of the resource to scale (Default Deployment)"`
+	// The name of the resource to scale
+	Name string `json:"name" description:"The name of the resource to scale"`
+	// The name of the service to route to
+	Service string `json:"service" description:"The name of the service to route to"`
+	// The port to route to
+	Port int32 `json:"port" description:"The port to route to"`
+	// (optional) The share of traffic that this target gets, relative to
+	// the app's other targets. A target with weight 0 gets no traffic
+	// (Default 1)
+	//+optional
+	//+kubebuilder:validation:Minimum=0
+	Weight *int32 `json:"weight,omitempty" description:"The share of traffic that this target gets, relative to the other targets (Default 1)"`
+}
+
+// TargetAPIVersion returns the API version of the resource to scale,
+// defaulting to apps/v1
+func (t TargetRef) TargetAPIVersion() string {
+	if t.APIVersion != "" {
+		return t.APIVersion
+	}
+	return "apps/v1"
+}
+
+// TargetKind returns the kind of the resource to scale, defaulting to
+// Deployment
+func (t TargetRef) TargetKind() string {
+	if t.Kind != "" {
+		return t.Kind
+	}
+	return "Deployment"
+}
+
+// TargetWeight returns the share of traffic that the target gets,
+// defaulting to 1
+func (t TargetRef) TargetWeight() int32 {
+	if t.Weight != nil {
+		return *t.Weight
+	}
+	return 1
+}
+
+// IngressKind is the kind of object that the operator creates to route
+// traffic from outside the cluster to the interceptor
+// +kubebuilder:validation:Enum=Ingress;HTTPRoute
+type IngressKind string
+
+const (
+	// IngressKindIngress is a networking.k8s.io/v1 Ingress
+	IngressKindIngress IngressKind = "Ingress"
+	// IngressKindHTTPRoute is a Gateway API gateway.networking.k8s.io/v1
+	// HTTPRoute
+	IngressKindHTTPRoute IngressKind = "HTTPRoute"
+)
+
+// GatewayRef is the Gateway API Gateway that an HTTPRoute attaches to
+type GatewayRef struct {
+	// The name of the Gateway
+	Name string `json:"name" description:"The name of the Gateway"`
+	// (optional) The namespace of the Gateway (Default the
+	// HTTPScaledObject's namespace)
+	//+optional
+	Namespace string `json:"namespace,omitempty" description:"The namespace of the Gateway"`
+	// (optional) The name of the listener on the Gateway to attach to. If
+	// it's empty, the HTTPRoute attaches to all of the Gateway's listeners
+	//+optional
+	SectionName string `json:"sectionName,omitempty" description:"The name of the listener on the Gateway to attach to"`
+}
+
+// IngressSpec describes the Ingress or HTTPRoute that the operator
+// creates to route traffic for an app's hosts and paths from outside the
+// cluster to the interceptor's proxy service
+type IngressSpec struct {
+	// (optional) The kind of object to create, Ingress or HTTPRoute
+	// (Default Ingress)
+	//+optional
+	Kind IngressKind `json:"kind,omitempty" description:"The kind of object to create, Ingress or HTTPRoute (Default Ingress)"`
+	// (optional) The ingressClassName of the Ingress. Only for Ingresses
+	//+optional
+	ClassName string `json:"className,omitempty" description:"The ingressClassName of the Ingress"`
+	// (optional) The name of the secret that holds the TLS certificate
+	// for the app's hosts. Only for Ingresses, since an HTTPRoute's TLS
+	// is configured on its Gateway
+	//+optional
+	TLSSecretName string `json:"tlsSecretName,omitempty" description:"The name of the secret that holds the TLS certificate for the app's hosts"`
+	// (optional) The Gateway that the HTTPRoute attaches to. Required for
+	// HTTPRoutes
+	//+optional
+	Gateway *GatewayRef `json:"gateway,omitempty" description:"The Gateway that the HTTPRoute attaches to"`
+	// (optional) Annotations to add to the Ingress or HTTPRoute, like the
+	// ones that configure an ingress controller
+	//+optional
+	Annotations map[string]string `json:"annotations,omitempty" description:"Annotations to add to the Ingress or HTTPRoute"`
+}
+
+// IngressKind returns the kind of object to create, defaulting to
+// Ingress
+func (i IngressSpec) IngressKind() IngressKind {
+	if i.Kind != "" {
+		return i.Kind
+	}
+	return IngressKindIngress
+}
+
+// HTTPScaledObjectSpec defines the desired state of HTTPScaledObject
+type HTTPScaledObjectSpec struct {
+	// (optional) The hosts to route to the app. If it's empty, requests
+	// for any host are routed to it
+	//+optional
+	Hosts []string `json:"hosts,omitempty" description:"The hosts to route to the app"`
+	// (optional) The path prefixes to route to the app. If it's empty,
+	// all paths are routed to it
+	//+optional
+	Paths []PathRule `json:"paths,omitempty" description:"The path prefixes to route to the app"`
+	// The resources to route HTTP requests to and to autoscale. Requests
+	// are split between them according to their weights
+	//+kubebuilder:validation:MinItems=1
+	Targets []TargetRef `json:"targets" description:"The resources to route HTTP requests to and to autoscale"`
+	// (optional) How the targets are scaled
+	//+optional
+	ScalingPolicy ScalingPolicy `json:"scalingPolicy,omitempty" description:"How the targets are scaled"`
+	// (optional) How the interceptor is scaled, separately from the
+	// targets. Anything it leaves out, or sets to 0, comes from the
+	// operator's config, so a replicas.min of 0 means the operator's
+	// minimum. The interceptor is never scaled below 1 replica, so that
+	// there's always an interceptor to receive the request that wakes the
+	// targets up from zero. Only for dedicated interceptors, since shared
+	// ones are scaled according to the operator's config
+	//+optional
+	InterceptorScalingPolicy *ScalingPolicy `json:"interceptorScalingPolicy,omitempty" description:"How the interceptor is scaled, separately from the targets"`
+	// (optional) The Ingress or HTTPRoute to create to route traffic from
+	// outside the cluster to the interceptor. If it's empty, none is
+	// created
+	//+optional
+	Ingress *IngressSpec `json:"ingress,omitempty" description:"The Ingress or HTTPRoute to create to route traffic from outside the cluster to the interceptor"`
+}
+
+// HTTPScaledObjectStatus defines the observed state of HTTPScaledObject
+type HTTPScaledObjectStatus struct {
+	// The generation of the HTTPScaledObject that the operator last reconciled
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty" description:"The generation of the HTTPScaledObject that the operator last reconciled"`
+	// The current state of the HTTPScaledObject, with at most one condition
+	// of each type
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" description:"The current state of the HTTPScaledObject, with at most one condition of each type"`
+	// The URL that the app can be reached at from outside the cluster,
+	// if the operator created an Ingress or HTTPRoute for it
+	// +optional
+	URL string `json:"url,omitempty" description:"The URL that the app can be reached at from outside the cluster"`
+	// The URL of the interceptor's proxy service inside the cluster
+	// +optional
+	ProxyURL string `json:"proxyURL,omitempty" description:"The URL of the interceptor's proxy service inside the cluster"`
+	// The total number of replicas of the targets, as of the last status
+	// refresh
+	// +optional
+	AppReplicas int32 `json:"appReplicas" description:"The total number of replicas of the targets, as of the last status refresh"`
+	// The number of replicas of the interceptor, as of the last status
+	// refresh
+	// +optional
+	InterceptorReplicas int32 `json:"interceptorReplicas" description:"The number of replicas of the interceptor, as of the last status refresh"`
+	// The number of pending requests for the app, summed across
+	// interceptors by the external scaler, as of the last status refresh
+	// +optional
+	PendingRequests int64 `json:"pendingRequests" description:"The number of pending requests for the app, summed across interceptors by the external scaler, as of the last status refresh"`
+	// The replica count that each target was paused at, keyed by the
+	// target's name. It's recorded the first time the HTTPScaledObject is
+	// reconciled while paused without a paused replica count, and cleared
+	// once it's resumed
+	// +optional
+	PausedReplicas map[string]int32 `json:"pausedReplicas,omitempty" description:"The replica count that each target was paused at, keyed by the target's name"`
+	// The last time that the operator saw the targets scaled up from zero
+	// replicas
+	// +optional
+	LastScaledFromZeroTime *metav1.Time `json:"lastScaledFromZeroTime,omitempty" description:"The last time that the operator saw the targets scaled up from zero replicas"`
+	// The last time that the replica and pending request counts were
+	// refreshed
+	// +optional
+	LastRefreshTime *metav1.Time `json:"lastRefreshTime,omitempty" description:"The last time that the replica and pending request counts were refreshed"`
+	// The names of the objects that the operator created for the
+	// HTTPScaledObject
+	// +optional
+	Resources *GeneratedResources `json:"resources,omitempty" description:"The names of the objects that the operator created for the HTTPScaledObject"`
+}
+
+// GeneratedResources holds the names of the objects that the operator
+// created for an HTTPScaledObject, all in its namespace. If interceptors
+// are shared, the interceptor and external scaler objects are shared with
+// the other HTTPScaledObjects in the namespace
+type GeneratedResources struct {
+	// The interceptor's Deployment
+	InterceptorDeployment string `json:"interceptorDeployment" description:"The interceptor's Deployment"`
+	// The interceptor's admin and proxy Services
+	InterceptorServices []string `json:"interceptorServices" description:"The interceptor's admin and proxy Services"`
+	// The ConfigMap holding the routing table of the interceptors
+	// +optional
+	RoutingTableConfigMap string `json:"routingTableConfigMap,omitempty" description:"The ConfigMap holding the routing table of the interceptors"`
+	// The external scaler's Deployment
+	ExternalScalerDeployment string `json:"externalScalerDeployment" description:"The external scaler's Deployment"`
+	// The external scaler's Service
+	ExternalScalerService string `json:"externalScalerService" description:"The external scaler's Service"`
+	// The KEDA ScaledObjects for the targets and the interceptor
+	ScaledObjects []string `json:"scaledObjects" description:"The KEDA ScaledObjects for the targets and the interceptor"`
+	// The Ingress or HTTPRoute, if the HTTPScaledObject has an ingress
+	// section
+	// +optional
+	Ingress string `json:"ingress,omitempty" description:"The Ingress or HTTPRoute, if the HTTPScaledObject has an ingress section"`
+}
+
+// +kubebuilder:object:root=true
+
+// HTTPScaledObject is the Schema for the scaledobjects API
+// +k8s:openapi-gen=true
+// +kubebuilder:storageversion
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=httpscaledobjects,scope=Namespaced,shortName=httpso
+// +kubebuilder:printcolumn:name="Hosts",type="string",JSONPath=".spec.hosts"
+// +kubebuilder:printcolumn:name="MinReplicas",type="integer",JSONPath=".spec.scalingPolicy.replicas.min"
+// +kubebuilder:printcolumn:name="MaxReplicas",type="integer",JSONPath=".spec.scalingPolicy.replicas.max"
+// +kubebuilder:printcolumn:name="Replicas",type="integer",JSONPath=".status.appReplicas"
+// +kubebuilder:printcolumn:name="Pending",type="integer",JSONPath=".status.pendingRequests"
+// +kubebuilder:printcolumn:name="URL",type="string",JSONPath=".status.url"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+// +kubebuilder:printcolumn:name="Active",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
+
+type HTTPScaledObject struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   HTTPScaledObjectSpec   `json:"spec,omitempty"`
+	Status HTTPScaledObjectStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// HTTPScaledObjectList contains a list of HTTPScaledObject
+type HTTPScaledObjectList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []HTTPScaledObject `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&HTTPScaledObject{}, &HTTPScaledObjectList{})
+}
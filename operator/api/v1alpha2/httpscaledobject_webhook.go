@@ -0,0 +1,531 @@
+package v1alpha2
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// DefaultMaxReplicas is the maximum number of replicas that an app is
+// scaled to if the HTTPScaledObject doesn't specify one. The minimum
+// defaults to 0
+const DefaultMaxReplicas int32 = 100
+
+// ValidatePath is the path that the validating webhook for
+// HTTPScaledObjects is served on
+const ValidatePath = "/validate-http-keda-sh-v1alpha2-httpscaledobject"
+
+// SetupWebhookWithManager registers the defaulting and validating webhooks
+// for HTTPScaledObjects with mgr's webhook server. If mgr's scheme also
+// has the other versions of HTTPScaledObject in it, it registers the
+// webhook that converts between them too. Pass true for
+// sharedInterceptors if the operator runs a shared interceptor fleet in
+// each namespace
+func SetupWebhookWithManager(mgr ctrl.Manager, sharedInterceptors bool) error {
+	if err := ctrl.NewWebhookManagedBy(mgr).
+		For(&HTTPScaledObject{}).
+		Complete(); err != nil {
+		return err
+	}
+	mgr.GetWebhookServer().Register(ValidatePath, &webhook.Admission{
+		Handler: &Validator{
+			Client:             mgr.GetClient(),
+			SharedInterceptors: sharedInterceptors,
+		},
+	})
+	return nil
+}
+
+// +kubebuilder:webhook:path=/mutate-http-keda-sh-v1alpha2-httpscaledobject,mutating=true,failurePolicy=fail,sideEffects=None,groups=http.keda.sh,resources=httpscaledobjects,verbs=create;update,versions=v1alpha2,name=mhttpscaledobject.kb.io,admissionReviewVersions={v1,v1beta1}
+
+var _ admission.Defaulter = &HTTPScaledObject{}
+
+// Default fills in the maximum replica count if httpso doesn't set one
+func (httpso *HTTPScaledObject) Default() {
+	if httpso.Spec.ScalingPolicy.Replicas.Max == 0 {
+		httpso.Spec.ScalingPolicy.Replicas.Max = DefaultMaxReplicas
+	}
+}
+
+// ValidateSpec returns all the problems with httpso's spec. It returns
+// an empty list if the spec is valid
+func (httpso *HTTPScaledObject) ValidateSpec() field.ErrorList {
+	var errs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	hostsPath := specPath.Child("hosts")
+	seenHosts := map[string]bool{}
+	for i, host := range httpso.Spec.Hosts {
+		if host == "" {
+			errs = append(errs, field.Required(hostsPath.Index(i), "hosts must not be empty"))
+		} else if seenHosts[host] {
+			errs = append(errs, field.Duplicate(hostsPath.Index(i), host))
+		}
+		seenHosts[host] = true
+	}
+
+	pathsPath := specPath.Child("paths")
+	seenPaths := map[string]bool{}
+	for i, path := range httpso.Spec.Paths {
+		prefixPath := pathsPath.Index(i).Child("prefix")
+		if !strings.HasPrefix(path.Prefix, "/") {
+			errs = append(errs, field.Invalid(prefixPath, path.Prefix, "must start with /"))
+		} else if seenPaths[path.Prefix] {
+			errs = append(errs, field.Duplicate(prefixPath, path.Prefix))
+		}
+		seenPaths[path.Prefix] = true
+	}
+
+	targetsPath := specPath.Child("targets")
+	if len(httpso.Spec.Targets) == 0 {
+		errs = append(errs, field.Required(targetsPath, "at least one target to scale and route to must be set"))
+	}
+	var totalWeight int32
+	// the objects that the operator creates for each target are named
+	// after it, so names have to be unique even across kinds
+	seenTargets := map[string]bool{}
+	for i, target := range httpso.Spec.Targets {
+		targetPath := targetsPath.Index(i)
+		if target.Name == "" {
+			errs = append(errs, field.Required(targetPath.Child("name"), "the resource to scale must be set"))
+		} else if seenTargets[target.Name] {
+			errs = append(errs, field.Duplicate(targetPath.Child("name"), target.Name))
+		}
+		seenTargets[target.Name] = true
+		if target.Service == "" {
+			errs = append(errs, field.Required(targetPath.Child("service"), "the service to route to must be set"))
+		}
+		if target.Port < 1 || target.Port > 65535 {
+			errs = append(errs, field.Invalid(
+				targetPath.Child("port"),
+				target.Port,
+				"must be between 1 and 65535",
+			))
+		}
+		if target.TargetWeight() < 0 {
+			errs = append(errs, field.Invalid(targetPath.Child("weight"), target.TargetWeight(), "must not be negative"))
+		}
+		totalWeight += target.TargetWeight()
+	}
+	if len(httpso.Spec.Targets) > 0 && totalWeight <= 0 {
+		errs = append(errs, field.Invalid(targetsPath, totalWeight, "at least one target must have a positive weight"))
+	}
+
+	policy := httpso.Spec.ScalingPolicy
+	policyPath := specPath.Child("scalingPolicy")
+	replicasPath := policyPath.Child("replicas")
+	if policy.Replicas.Min < 0 {
+		errs = append(errs, field.Invalid(replicasPath.Child("min"), policy.Replicas.Min, "must not be negative"))
+	}
+	if policy.Replicas.Max < policy.Replicas.Min {
+		errs = append(errs, field.Invalid(
+			replicasPath.Child("max"),
+			policy.Replicas.Max,
+			fmt.Sprintf("must not be less than min (%d)", policy.Replicas.Min),
+		))
+	}
+	if policy.TargetPendingRequests < 0 {
+		errs = append(errs, field.Invalid(
+			policyPath.Child("targetPendingRequests"),
+			policy.TargetPendingRequests,
+			"must not be negative",
+		))
+	}
+	errs = append(errs, policy.validateBehavior(policyPath)...)
+	errs = append(errs, policy.validatePrewarmWindows(policyPath)...)
+
+	if interceptorPolicy := httpso.Spec.InterceptorScalingPolicy; interceptorPolicy != nil {
+		errs = append(errs, interceptorPolicy.validateInterceptor(specPath.Child("interceptorScalingPolicy"))...)
+	}
+
+	if ingress := httpso.Spec.Ingress; ingress != nil {
+		errs = append(errs, ingress.validate(specPath.Child("ingress"))...)
+	}
+	return errs
+}
+
+// validateInterceptor returns all the problems with p as the
+// interceptor's scaling policy, which is at path in the HTTPScaledObject.
+// Fields that are 0 come from the operator's config, so only the ones
+// that are set are checked
+func (p ScalingPolicy) validateInterceptor(path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	replicasPath := path.Child("replicas")
+	if p.Replicas.Min < 0 {
+		errs = append(errs, field.Invalid(
+			replicasPath.Child("min"),
+			p.Replicas.Min,
+			"must not be negative",
+		))
+	}
+	if p.Replicas.Max < 0 {
+		errs = append(errs, field.Invalid(replicasPath.Child("max"), p.Replicas.Max, "must not be negative"))
+	} else if p.Replicas.Max > 0 && p.Replicas.Max < p.Replicas.Min {
+		errs = append(errs, field.Invalid(
+			replicasPath.Child("max"),
+			p.Replicas.Max,
+			fmt.Sprintf("must not be less than min (%d)", p.Replicas.Min),
+		))
+	}
+	if p.TargetPendingRequests < 0 {
+		errs = append(errs, field.Invalid(
+			path.Child("targetPendingRequests"),
+			p.TargetPendingRequests,
+			"must not be negative",
+		))
+	}
+	errs = append(errs, p.validateBehavior(path)...)
+	errs = append(errs, p.validatePrewarmWindows(path)...)
+	return errs
+}
+
+// validateBehavior returns all the problems with p's polling interval,
+// cooldown period and HorizontalPodAutoscaler behavior, where p is at
+// path in the HTTPScaledObject. The limits are the ones that KEDA and
+// the HorizontalPodAutoscaler enforce
+func (p ScalingPolicy) validateBehavior(path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if p.PollingInterval != nil && *p.PollingInterval < 1 {
+		errs = append(errs, field.Invalid(path.Child("pollingInterval"), *p.PollingInterval, "must be at least 1"))
+	}
+	if p.CooldownPeriod != nil && *p.CooldownPeriod < 0 {
+		errs = append(errs, field.Invalid(path.Child("cooldownPeriod"), *p.CooldownPeriod, "must not be negative"))
+	}
+	if p.Behavior == nil {
+		return errs
+	}
+	behaviorPath := path.Child("behavior")
+	rules := []struct {
+		name  string
+		rules *autoscalingv2beta2.HPAScalingRules
+	}{
+		{"scaleUp", p.Behavior.ScaleUp},
+		{"scaleDown", p.Behavior.ScaleDown},
+	}
+	for _, r := range rules {
+		if r.rules == nil {
+			continue
+		}
+		rulesPath := behaviorPath.Child(r.name)
+		if window := r.rules.StabilizationWindowSeconds; window != nil && (*window < 0 || *window > 3600) {
+			errs = append(errs, field.Invalid(
+				rulesPath.Child("stabilizationWindowSeconds"),
+				*window,
+				"must be between 0 and 3600",
+			))
+		}
+		if selectPolicy := r.rules.SelectPolicy; selectPolicy != nil {
+			switch *selectPolicy {
+			case autoscalingv2beta2.MaxPolicySelect, autoscalingv2beta2.MinPolicySelect, autoscalingv2beta2.DisabledPolicySelect:
+			default:
+				errs = append(errs, field.NotSupported(
+					rulesPath.Child("selectPolicy"),
+					*selectPolicy,
+					[]string{
+						string(autoscalingv2beta2.MaxPolicySelect),
+						string(autoscalingv2beta2.MinPolicySelect),
+						string(autoscalingv2beta2.DisabledPolicySelect),
+					},
+				))
+			}
+		}
+		for i, policy := range r.rules.Policies {
+			policyPath := rulesPath.Child("policies").Index(i)
+			switch policy.Type {
+			case autoscalingv2beta2.PodsScalingPolicy, autoscalingv2beta2.PercentScalingPolicy:
+			default:
+				errs = append(errs, field.NotSupported(
+					policyPath.Child("type"),
+					policy.Type,
+					[]string{
+						string(autoscalingv2beta2.PodsScalingPolicy),
+						string(autoscalingv2beta2.PercentScalingPolicy),
+					},
+				))
+			}
+			if policy.Value <= 0 {
+				errs = append(errs, field.Invalid(policyPath.Child("value"), policy.Value, "must be positive"))
+			}
+			if policy.PeriodSeconds <= 0 || policy.PeriodSeconds > 1800 {
+				errs = append(errs, field.Invalid(
+					policyPath.Child("periodSeconds"),
+					policy.PeriodSeconds,
+					"must be between 1 and 1800",
+				))
+			}
+		}
+	}
+	return errs
+}
+
+// validatePrewarmWindows returns all the problems with p's pre-warming
+// windows, where p is at path in the HTTPScaledObject. Schedules are only
+// checked for having the five fields that KEDA's cron scaler expects,
+// since KEDA parses them
+func (p ScalingPolicy) validatePrewarmWindows(path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	for i, window := range p.PrewarmWindows {
+		windowPath := path.Child("prewarmWindows").Index(i)
+		if window.Timezone == "" {
+			errs = append(errs, field.Required(windowPath.Child("timezone"), "the time zone of the schedules must be set"))
+		} else if _, err := time.LoadLocation(window.Timezone); err != nil {
+			errs = append(errs, field.Invalid(windowPath.Child("timezone"), window.Timezone, "must be an IANA time zone"))
+		}
+		schedules := []struct {
+			name     string
+			schedule string
+		}{
+			{"start", window.Start},
+			{"end", window.End},
+		}
+		for _, schedule := range schedules {
+			if len(strings.Fields(schedule.schedule)) != 5 {
+				errs = append(errs, field.Invalid(
+					windowPath.Child(schedule.name),
+					schedule.schedule,
+					"must be a cron schedule with minute, hour, day of month, month and day of week fields",
+				))
+			}
+		}
+		if window.Start != "" && window.Start == window.End {
+			errs = append(errs, field.Invalid(windowPath.Child("end"), window.End, "must not be the same as start"))
+		}
+		if window.MinReplicas < 1 {
+			errs = append(errs, field.Invalid(windowPath.Child("minReplicas"), window.MinReplicas, "must be at least 1"))
+		} else if p.Replicas.Max > 0 && window.MinReplicas > p.Replicas.Max {
+			errs = append(errs, field.Invalid(
+				windowPath.Child("minReplicas"),
+				window.MinReplicas,
+				fmt.Sprintf("must not be more than max replicas (%d)", p.Replicas.Max),
+			))
+		}
+	}
+	return errs
+}
+
+// validate returns all the problems with i, which is at path in the
+// HTTPScaledObject
+func (i IngressSpec) validate(path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	switch i.IngressKind() {
+	case IngressKindIngress:
+		if i.Gateway != nil {
+			errs = append(errs, field.Forbidden(path.Child("gateway"), "only HTTPRoutes attach to a gateway"))
+		}
+	case IngressKindHTTPRoute:
+		if i.Gateway == nil {
+			errs = append(errs, field.Required(path.Child("gateway"), "HTTPRoutes must attach to a gateway"))
+		} else if i.Gateway.Name == "" {
+			errs = append(errs, field.Required(path.Child("gateway", "name"), ""))
+		}
+		if i.ClassName != "" {
+			errs = append(errs, field.Forbidden(path.Child("className"), "only Ingresses have a class"))
+		}
+		if i.TLSSecretName != "" {
+			errs = append(errs, field.Forbidden(
+				path.Child("tlsSecretName"),
+				"TLS for HTTPRoutes is configured on their gateway",
+			))
+		}
+	default:
+		errs = append(errs, field.NotSupported(
+			path.Child("kind"),
+			i.Kind,
+			[]string{string(IngressKindIngress), string(IngressKindHTTPRoute)},
+		))
+	}
+	return errs
+}
+
+// +kubebuilder:webhook:path=/validate-http-keda-sh-v1alpha2-httpscaledobject,mutating=false,failurePolicy=fail,sideEffects=None,groups=http.keda.sh,resources=httpscaledobjects,verbs=create;update,versions=v1alpha2,name=vhttpscaledobject.kb.io,admissionReviewVersions={v1,v1beta1}
+
+// Validator is the validating webhook for HTTPScaledObjects. Besides
+// checking each HTTPScaledObject's spec, it makes sure that no two
+// HTTPScaledObjects scale the same resource
+type Validator struct {
+	Client client.Reader
+	// SharedInterceptors is whether the HTTPScaledObjects in a namespace
+	// share an interceptor fleet. If they do, each one must list its
+	// hosts, and no two of them can list the same host
+	SharedInterceptors bool
+	decoder            *admission.Decoder
+}
+
+var _ admission.DecoderInjector = &Validator{}
+
+// InjectDecoder implements admission.DecoderInjector
+func (v *Validator) InjectDecoder(d *admission.Decoder) error {
+	v.decoder = d
+	return nil
+}
+
+// Handle implements admission.Handler
+func (v *Validator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Create && req.Operation != admissionv1.Update {
+		return admission.Allowed("")
+	}
+	httpso := &HTTPScaledObject{}
+	if err := v.decoder.Decode(req, httpso); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if err := v.validate(ctx, httpso); err != nil {
+		if apiStatus, ok := err.(apierrors.APIStatus); ok {
+			status := apiStatus.Status()
+			return admission.Response{
+				AdmissionResponse: admissionv1.AdmissionResponse{
+					Allowed: false,
+					Result:  &status,
+				},
+			}
+		}
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.Allowed("")
+}
+
+// validate returns an Invalid API error if httpso's spec or pause
+// annotations are invalid or if another HTTPScaledObject in its namespace already scales one of the
+// same resources (or, with shared interceptors, routes one of the same
+// hosts), and nil if httpso can be admitted
+func (v *Validator) validate(ctx context.Context, httpso *HTTPScaledObject) error {
+	errs := httpso.ValidateSpec()
+	errs = append(errs, httpso.validatePause()...)
+	if v.SharedInterceptors && len(httpso.Spec.Hosts) == 0 {
+		errs = append(errs, field.Required(
+			field.NewPath("spec", "hosts"),
+			"hosts are required when interceptors are shared",
+		))
+	}
+	if v.SharedInterceptors && httpso.Spec.InterceptorScalingPolicy != nil {
+		errs = append(errs, field.Forbidden(
+			field.NewPath("spec", "interceptorScalingPolicy"),
+			"shared interceptors are scaled according to the operator's config",
+		))
+	}
+	if len(errs) == 0 {
+		claimErrs, err := v.checkClaims(ctx, httpso)
+		if err != nil {
+			return err
+		}
+		errs = append(errs, claimErrs...)
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(
+		GroupVersion.WithKind("HTTPScaledObject").GroupKind(),
+		httpso.Name,
+		errs,
+	)
+}
+
+// checkClaims returns an error for each of httpso's targets that has the
+// same name as a target of another HTTPScaledObject in its namespace.
+// Like in ValidateSpec, the kind doesn't matter, since the objects that
+// the operator generates for each target are named after it. With shared
+// interceptors, it also returns an error for each of httpso's hosts that
+// another HTTPScaledObject in its namespace already routes
+func (v *Validator) checkClaims(ctx context.Context, httpso *HTTPScaledObject) (field.ErrorList, error) {
+	var list HTTPScaledObjectList
+	if err := v.Client.List(ctx, &list, client.InNamespace(httpso.Namespace)); err != nil {
+		return nil, err
+	}
+	var errs field.ErrorList
+	for i, target := range httpso.Spec.Targets {
+		for _, other := range list.Items {
+			if other.Name == httpso.Name {
+				continue
+			}
+			otherTarget, ok := other.targetNamed(target.Name)
+			if !ok {
+				continue
+			}
+			msg := fmt.Sprintf(
+				"%s %s is already scaled by HTTPScaledObject %s",
+				target.TargetKind(),
+				target.Name,
+				other.Name,
+			)
+			if !sameTarget(target, otherTarget) {
+				msg = fmt.Sprintf(
+					"%s %s has the same name as %s %s in HTTPScaledObject %s, so the objects generated for them would collide",
+					target.TargetKind(),
+					target.Name,
+					otherTarget.TargetKind(),
+					otherTarget.Name,
+					other.Name,
+				)
+			}
+			errs = append(errs, field.Forbidden(
+				field.NewPath("spec", "targets").Index(i),
+				msg,
+			))
+			break
+		}
+	}
+	if !v.SharedInterceptors {
+		return errs, nil
+	}
+	for i, host := range httpso.Spec.Hosts {
+		for _, other := range list.Items {
+			if other.Name == httpso.Name || !other.routes(host) {
+				continue
+			}
+			errs = append(errs, field.Forbidden(
+				field.NewPath("spec", "hosts").Index(i),
+				fmt.Sprintf("host %s is already routed to HTTPScaledObject %s", host, other.Name),
+			))
+			break
+		}
+	}
+	return errs, nil
+}
+
+// routes returns true if host is one of httpso's hosts, ignoring case
+func (httpso *HTTPScaledObject) routes(host string) bool {
+	for _, other := range httpso.Spec.Hosts {
+		if strings.EqualFold(host, other) {
+			return true
+		}
+	}
+	return false
+}
+
+// targetNamed returns httpso's target called name, and whether it has
+// one
+func (httpso *HTTPScaledObject) targetNamed(name string) (TargetRef, bool) {
+	for _, target := range httpso.Spec.Targets {
+		if target.Name == name {
+			return target, true
+		}
+	}
+	return TargetRef{}, false
+}
+
+// sameTarget returns true if a and b refer to the same resource. Only
+// the API group is compared, not the version, since the same resource can
+// be served under more than one version
+func sameTarget(a, b TargetRef) bool {
+	groupOf := func(apiVersion string) string {
+		gv, err := schema.ParseGroupVersion(apiVersion)
+		if err != nil {
+			return apiVersion
+		}
+		return gv.Group
+	}
+	return a.Name == b.Name &&
+		a.TargetKind() == b.TargetKind() &&
+		groupOf(a.TargetAPIVersion()) == groupOf(b.TargetAPIVersion())
+}
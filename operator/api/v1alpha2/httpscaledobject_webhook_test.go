@@ -0,0 +1,411 @@
+package v1alpha2
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func newTestHTTPSO(name, deployment string) *HTTPScaledObject {
+	return &HTTPScaledObject{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: GroupVersion.String(),
+			Kind:       "HTTPScaledObject",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "testns",
+			Name:      name,
+		},
+		Spec: HTTPScaledObjectSpec{
+			Targets: []TargetRef{
+				{
+					Name:    deployment,
+					Service: "testsvc",
+					Port:    8080,
+				},
+			},
+		},
+	}
+}
+
+func int32P(i int32) *int32 {
+	return &i
+}
+
+func TestDefault(t *testing.T) {
+	r := require.New(t)
+	httpso := newTestHTTPSO("testso", "testdepl")
+	httpso.Default()
+	r.Equal(int32(0), httpso.Spec.ScalingPolicy.Replicas.Min)
+	r.Equal(DefaultMaxReplicas, httpso.Spec.ScalingPolicy.Replicas.Max)
+
+	httpso.Spec.ScalingPolicy.Replicas = ReplicaStruct{Min: 2, Max: 5}
+	httpso.Default()
+	r.Equal(ReplicaStruct{Min: 2, Max: 5}, httpso.Spec.ScalingPolicy.Replicas)
+}
+
+func TestValidateSpec(t *testing.T) {
+	type testCase struct {
+		name   string
+		modify func(*HTTPScaledObject)
+		fields []string
+	}
+	cases := []testCase{
+		{
+			name:   "valid",
+			modify: func(*HTTPScaledObject) {},
+		},
+		{
+			name: "valid with hosts, paths and weighted targets",
+			modify: func(httpso *HTTPScaledObject) {
+				httpso.Spec.Hosts = []string{"a.com", "b.com"}
+				httpso.Spec.Paths = []PathRule{{Prefix: "/api", StripPrefix: true}}
+				httpso.Spec.Targets[0].Weight = int32P(9)
+				httpso.Spec.Targets = append(httpso.Spec.Targets, TargetRef{
+					Kind:    "StatefulSet",
+					Name:    "teststs",
+					Service: "canarysvc",
+					Port:    8080,
+					Weight:  int32P(1),
+				})
+			},
+		},
+		{
+			name: "no targets",
+			modify: func(httpso *HTTPScaledObject) {
+				httpso.Spec.Targets = nil
+			},
+			fields: []string{"spec.targets"},
+		},
+		{
+			name: "no name",
+			modify: func(httpso *HTTPScaledObject) {
+				httpso.Spec.Targets[0].Name = ""
+			},
+			fields: []string{"spec.targets[0].name"},
+		},
+		{
+			name: "same target twice",
+			modify: func(httpso *HTTPScaledObject) {
+				httpso.Spec.Targets = append(httpso.Spec.Targets, httpso.Spec.Targets[0])
+			},
+			fields: []string{"spec.targets[1].name"},
+		},
+		{
+			name: "no service and port 0",
+			modify: func(httpso *HTTPScaledObject) {
+				httpso.Spec.Targets[0].Service = ""
+				httpso.Spec.Targets[0].Port = 0
+			},
+			fields: []string{"spec.targets[0].service", "spec.targets[0].port"},
+		},
+		{
+			name: "no traffic",
+			modify: func(httpso *HTTPScaledObject) {
+				httpso.Spec.Targets[0].Weight = int32P(0)
+			},
+			fields: []string{"spec.targets"},
+		},
+		{
+			name: "bad hosts and paths",
+			modify: func(httpso *HTTPScaledObject) {
+				httpso.Spec.Hosts = []string{"a.com", "", "a.com"}
+				httpso.Spec.Paths = []PathRule{{Prefix: "api"}}
+			},
+			fields: []string{"spec.hosts[1]", "spec.hosts[2]", "spec.paths[0].prefix"},
+		},
+		{
+			name: "valid ingress",
+			modify: func(httpso *HTTPScaledObject) {
+				httpso.Spec.Ingress = &IngressSpec{ClassName: "nginx", TLSSecretName: "testtls"}
+			},
+		},
+		{
+			name: "valid HTTPRoute",
+			modify: func(httpso *HTTPScaledObject) {
+				httpso.Spec.Ingress = &IngressSpec{
+					Kind:    IngressKindHTTPRoute,
+					Gateway: &GatewayRef{Name: "testgw", Namespace: "gwns"},
+				}
+			},
+		},
+		{
+			name: "HTTPRoute with Ingress fields and no gateway",
+			modify: func(httpso *HTTPScaledObject) {
+				httpso.Spec.Ingress = &IngressSpec{
+					Kind:          IngressKindHTTPRoute,
+					ClassName:     "nginx",
+					TLSSecretName: "testtls",
+				}
+			},
+			fields: []string{"spec.ingress.gateway", "spec.ingress.className", "spec.ingress.tlsSecretName"},
+		},
+		{
+			name: "Ingress with a gateway",
+			modify: func(httpso *HTTPScaledObject) {
+				httpso.Spec.Ingress = &IngressSpec{Gateway: &GatewayRef{Name: "testgw"}}
+			},
+			fields: []string{"spec.ingress.gateway"},
+		},
+		{
+			name: "max less than min",
+			modify: func(httpso *HTTPScaledObject) {
+				httpso.Spec.ScalingPolicy.Replicas = ReplicaStruct{Min: 5, Max: 2}
+			},
+			fields: []string{"spec.scalingPolicy.replicas.max"},
+		},
+		{
+			name: "negative min and targetPendingRequests",
+			modify: func(httpso *HTTPScaledObject) {
+				httpso.Spec.ScalingPolicy.Replicas = ReplicaStruct{Min: -1, Max: 2}
+				httpso.Spec.ScalingPolicy.TargetPendingRequests = -1
+			},
+			fields: []string{"spec.scalingPolicy.replicas.min", "spec.scalingPolicy.targetPendingRequests"},
+		},
+		{
+			name: "valid polling, cooldown and behavior",
+			modify: func(httpso *HTTPScaledObject) {
+				pollingInterval, cooldownPeriod, window := int32(10), int32(0), int32(600)
+				httpso.Spec.ScalingPolicy.PollingInterval = &pollingInterval
+				httpso.Spec.ScalingPolicy.CooldownPeriod = &cooldownPeriod
+				httpso.Spec.ScalingPolicy.Behavior = &autoscalingv2beta2.HorizontalPodAutoscalerBehavior{
+					ScaleDown: &autoscalingv2beta2.HPAScalingRules{
+						StabilizationWindowSeconds: &window,
+						Policies: []autoscalingv2beta2.HPAScalingPolicy{
+							{Type: autoscalingv2beta2.PodsScalingPolicy, Value: 1, PeriodSeconds: 60},
+						},
+					},
+				}
+			},
+		},
+		{
+			name: "invalid polling, cooldown and behavior",
+			modify: func(httpso *HTTPScaledObject) {
+				pollingInterval, cooldownPeriod, window := int32(0), int32(-1), int32(4000)
+				selectPolicy := autoscalingv2beta2.ScalingPolicySelect("Sometimes")
+				httpso.Spec.ScalingPolicy.PollingInterval = &pollingInterval
+				httpso.Spec.ScalingPolicy.CooldownPeriod = &cooldownPeriod
+				httpso.Spec.ScalingPolicy.Behavior = &autoscalingv2beta2.HorizontalPodAutoscalerBehavior{
+					ScaleUp: &autoscalingv2beta2.HPAScalingRules{
+						StabilizationWindowSeconds: &window,
+						SelectPolicy:               &selectPolicy,
+						Policies: []autoscalingv2beta2.HPAScalingPolicy{
+							{Type: "Replicas", Value: 0, PeriodSeconds: 0},
+						},
+					},
+				}
+			},
+			fields: []string{
+				"spec.scalingPolicy.pollingInterval",
+				"spec.scalingPolicy.cooldownPeriod",
+				"spec.scalingPolicy.behavior.scaleUp.stabilizationWindowSeconds",
+				"spec.scalingPolicy.behavior.scaleUp.selectPolicy",
+				"spec.scalingPolicy.behavior.scaleUp.policies[0].type",
+				"spec.scalingPolicy.behavior.scaleUp.policies[0].value",
+				"spec.scalingPolicy.behavior.scaleUp.policies[0].periodSeconds",
+			},
+		},
+		{
+			name: "valid pre-warming windows",
+			modify: func(httpso *HTTPScaledObject) {
+				httpso.Spec.ScalingPolicy.PrewarmWindows = []PrewarmWindow{
+					{Timezone: "Europe/Berlin", Start: "45 8 * * 1-5", End: "0 10 * * 1-5", MinReplicas: 5},
+				}
+			},
+		},
+		{
+			name: "invalid pre-warming windows",
+			modify: func(httpso *HTTPScaledObject) {
+				httpso.Spec.ScalingPolicy.Replicas.Max = 10
+				httpso.Spec.ScalingPolicy.PrewarmWindows = []PrewarmWindow{
+					{Timezone: "Mars/Olympus_Mons", Start: "every morning", End: "0 10 * * 1-5", MinReplicas: 0},
+					{Start: "0 9 * * *", End: "0 9 * * *", MinReplicas: 11},
+				}
+			},
+			fields: []string{
+				"spec.scalingPolicy.prewarmWindows[0].timezone",
+				"spec.scalingPolicy.prewarmWindows[0].start",
+				"spec.scalingPolicy.prewarmWindows[0].minReplicas",
+				"spec.scalingPolicy.prewarmWindows[1].timezone",
+				"spec.scalingPolicy.prewarmWindows[1].end",
+				"spec.scalingPolicy.prewarmWindows[1].minReplicas",
+			},
+		},
+		{
+			name: "interceptor scaling policy with defaults left out",
+			modify: func(httpso *HTTPScaledObject) {
+				httpso.Spec.ScalingPolicy.Replicas = ReplicaStruct{Min: 0, Max: 2}
+				httpso.Spec.InterceptorScalingPolicy = &ScalingPolicy{
+					Replicas: ReplicaStruct{Min: 3},
+				}
+			},
+		},
+		{
+			// 0 means the operator's minimum, which is never below 1
+			name: "interceptor scaling policy with a min of 0",
+			modify: func(httpso *HTTPScaledObject) {
+				httpso.Spec.InterceptorScalingPolicy = &ScalingPolicy{
+					Replicas: ReplicaStruct{Min: 0, Max: 5},
+				}
+			},
+		},
+		{
+			name: "invalid interceptor scaling policy",
+			modify: func(httpso *HTTPScaledObject) {
+				httpso.Spec.InterceptorScalingPolicy = &ScalingPolicy{
+					Replicas:              ReplicaStruct{Min: -1, Max: -2},
+					TargetPendingRequests: -1,
+				}
+			},
+			fields: []string{
+				"spec.interceptorScalingPolicy.replicas.min",
+				"spec.interceptorScalingPolicy.replicas.max",
+				"spec.interceptorScalingPolicy.targetPendingRequests",
+			},
+		},
+		{
+			name: "interceptor max less than min",
+			modify: func(httpso *HTTPScaledObject) {
+				httpso.Spec.InterceptorScalingPolicy = &ScalingPolicy{
+					Replicas: ReplicaStruct{Min: 3, Max: 2},
+				}
+			},
+			fields: []string{"spec.interceptorScalingPolicy.replicas.max"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := require.New(t)
+			httpso := newTestHTTPSO("testso", "testdepl")
+			httpso.Default()
+			c.modify(httpso)
+			errs := httpso.ValidateSpec()
+			fields := []string{}
+			for _, err := range errs {
+				fields = append(fields, err.Field)
+			}
+			if len(c.fields) == 0 {
+				r.Empty(fields)
+				return
+			}
+			r.Equal(c.fields, fields)
+		})
+	}
+}
+
+func TestValidatorRejectsClaimedTarget(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	r.NoError(AddToScheme(scheme))
+	existing := newTestHTTPSO("existing", "testdepl")
+	existing.Default()
+	validator := &Validator{
+		Client: fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(existing).
+			Build(),
+	}
+	decoder, err := admission.NewDecoder(scheme)
+	r.NoError(err)
+	r.NoError(validator.InjectDecoder(decoder))
+
+	handle := func(httpso *HTTPScaledObject) admission.Response {
+		raw, err := json.Marshal(httpso)
+		r.NoError(err)
+		return validator.Handle(ctx, admission.Request{
+			AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: admissionv1.Create,
+				Object:    runtime.RawExtension{Raw: raw},
+			},
+		})
+	}
+
+	// updating the one that already claims the deployment is fine
+	res := handle(existing)
+	r.True(res.Allowed)
+
+	// a second one for the same deployment isn't, even if it's only
+	// one of its targets and the API version is spelled out
+	second := newTestHTTPSO("second", "otherdepl")
+	second.Spec.Targets = append(second.Spec.Targets, TargetRef{
+		APIVersion: "apps/v1",
+		Kind:       "Deployment",
+		Name:       "testdepl",
+		Service:    "testsvc",
+		Port:       8080,
+	})
+	second.Default()
+	res = handle(second)
+	r.False(res.Allowed)
+	r.Contains(res.Result.Message, "spec.targets[1]")
+	r.Contains(res.Result.Message, "already scaled by HTTPScaledObject existing")
+
+	// a different kind with the same name is a different resource, but
+	// the objects generated for the two would have the same names
+	second.Spec.Targets[1].Kind = "StatefulSet"
+	res = handle(second)
+	r.False(res.Allowed)
+	r.Contains(res.Result.Message, "spec.targets[1]")
+	r.Contains(res.Result.Message, "same name as Deployment testdepl in HTTPScaledObject existing")
+
+	// a different name is fine
+	second.Spec.Targets[1].Name = "teststatefulset"
+	res = handle(second)
+	r.True(res.Allowed)
+
+	// invalid specs are rejected with the reason
+	invalid := newTestHTTPSO("invalid", "thirddepl")
+	invalid.Spec.Targets[0].Port = 0
+	res = handle(invalid)
+	r.False(res.Allowed)
+	r.Contains(res.Result.Message, "spec.targets[0].port")
+}
+
+func TestValidatorSharedInterceptors(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	r.NoError(AddToScheme(scheme))
+	existing := newTestHTTPSO("existing", "testdepl")
+	existing.Spec.Hosts = []string{"a.com"}
+	existing.Default()
+	validator := &Validator{
+		Client: fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(existing).
+			Build(),
+		SharedInterceptors: true,
+	}
+
+	// hosts are required
+	second := newTestHTTPSO("second", "otherdepl")
+	second.Default()
+	err := validator.validate(ctx, second)
+	r.Error(err)
+	r.Contains(err.Error(), "spec.hosts")
+
+	// and can't be routed to another HTTPScaledObject already
+	second.Spec.Hosts = []string{"b.com", "A.com"}
+	err = validator.validate(ctx, second)
+	r.Error(err)
+	r.Contains(err.Error(), "spec.hosts[1]")
+	r.Contains(err.Error(), "already routed to HTTPScaledObject existing")
+
+	second.Spec.Hosts = []string{"b.com"}
+	r.NoError(validator.validate(ctx, second))
+
+	// the shared fleet is scaled according to the operator's config
+	second.Spec.InterceptorScalingPolicy = &ScalingPolicy{Replicas: ReplicaStruct{Min: 2}}
+	err = validator.validate(ctx, second)
+	r.Error(err)
+	r.Contains(err.Error(), "spec.interceptorScalingPolicy")
+}
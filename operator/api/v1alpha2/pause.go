@@ -0,0 +1,55 @@
+package v1alpha2
+
+import (
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	// PausedAnnotation pauses the scaling of an HTTPScaledObject's
+	// targets at their current replica counts when it's set to "true"
+	PausedAnnotation = "http.keda.sh/paused"
+	// PausedReplicasAnnotation pauses the scaling of an
+	// HTTPScaledObject's targets and scales each of them to the replica
+	// count it's set to. It takes precedence over PausedAnnotation
+	PausedReplicasAnnotation = "http.keda.sh/paused-replicas"
+)
+
+// Paused returns whether the scaling of httpso's targets is paused, and
+// if it is, the replica count to scale them to. The replica count is nil
+// if they should stay at their current counts. Returns an error if
+// either of the pause annotations has an invalid value
+func (httpso *HTTPScaledObject) Paused() (bool, *int32, error) {
+	if errs := httpso.validatePause(); len(errs) > 0 {
+		return false, nil, errs.ToAggregate()
+	}
+	if raw, ok := httpso.Annotations[PausedReplicasAnnotation]; ok {
+		replicas, _ := strconv.ParseInt(raw, 10, 32)
+		ret := int32(replicas)
+		return true, &ret, nil
+	}
+	paused, _ := strconv.ParseBool(httpso.Annotations[PausedAnnotation])
+	return paused, nil, nil
+}
+
+// validatePause returns all the problems with httpso's pause annotations
+func (httpso *HTTPScaledObject) validatePause() field.ErrorList {
+	var errs field.ErrorList
+	annotationsPath := field.NewPath("metadata", "annotations")
+	if raw, ok := httpso.Annotations[PausedAnnotation]; ok {
+		if _, err := strconv.ParseBool(raw); err != nil {
+			errs = append(errs, field.Invalid(annotationsPath.Key(PausedAnnotation), raw, "must be true or false"))
+		}
+	}
+	if raw, ok := httpso.Annotations[PausedReplicasAnnotation]; ok {
+		if replicas, err := strconv.ParseInt(raw, 10, 32); err != nil || replicas < 0 {
+			errs = append(errs, field.Invalid(
+				annotationsPath.Key(PausedReplicasAnnotation),
+				raw,
+				"must be a replica count of 0 or more",
+			))
+		}
+	}
+	return errs
+}
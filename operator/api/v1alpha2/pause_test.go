@@ -0,0 +1,86 @@
+package v1alpha2
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestPaused(t *testing.T) {
+	type testCase struct {
+		name        string
+		annotations map[string]string
+		paused      bool
+		replicas    *int32
+		err         bool
+	}
+	cases := []testCase{
+		{
+			name: "not paused",
+		},
+		{
+			name:        "paused at the current replica counts",
+			annotations: map[string]string{PausedAnnotation: "true"},
+			paused:      true,
+		},
+		{
+			name:        "explicitly not paused",
+			annotations: map[string]string{PausedAnnotation: "false"},
+		},
+		{
+			name: "paused at a replica count",
+			annotations: map[string]string{
+				PausedAnnotation:         "false",
+				PausedReplicasAnnotation: "2",
+			},
+			paused:   true,
+			replicas: int32P(2),
+		},
+		{
+			name:        "invalid paused",
+			annotations: map[string]string{PausedAnnotation: "sometimes"},
+			err:         true,
+		},
+		{
+			name:        "negative replicas",
+			annotations: map[string]string{PausedReplicasAnnotation: "-1"},
+			err:         true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := require.New(t)
+			httpso := newTestHTTPSO("testso", "testdepl")
+			httpso.Annotations = c.annotations
+			paused, replicas, err := httpso.Paused()
+			if c.err {
+				r.Error(err)
+				return
+			}
+			r.NoError(err)
+			r.Equal(c.paused, paused)
+			r.Equal(c.replicas, replicas)
+		})
+	}
+}
+
+func TestValidatorRejectsInvalidPause(t *testing.T) {
+	r := require.New(t)
+	scheme := runtime.NewScheme()
+	r.NoError(AddToScheme(scheme))
+	validator := &Validator{
+		Client: fake.NewClientBuilder().WithScheme(scheme).Build(),
+	}
+	httpso := newTestHTTPSO("testso", "testdepl")
+	httpso.Default()
+	httpso.Annotations = map[string]string{PausedReplicasAnnotation: "lots"}
+	err := validator.validate(context.Background(), httpso)
+	r.Error(err)
+	r.Contains(err.Error(), "metadata.annotations[http.keda.sh/paused-replicas]")
+
+	httpso.Annotations[PausedReplicasAnnotation] = "0"
+	r.NoError(validator.validate(context.Background(), httpso))
+}
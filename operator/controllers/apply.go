@@ -0,0 +1,255 @@
+package controllers
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	"github.com/kedacore/http-add-on/pkg/k8s"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// sharedOwner is an owner for the apply functions below that doesn't
+// control the objects it owns. It's for objects that several
+// HTTPScaledObjects share, like a shared interceptor fleet, so that they
+// can all own them. Kubernetes garbage collects such an object once all
+// of its owners are deleted
+type sharedOwner struct {
+	metav1.Object
+}
+
+// setOwner makes owner the controller of obj, or just one of its owners
+// if owner is a sharedOwner
+func setOwner(owner, obj metav1.Object, scheme *runtime.Scheme) error {
+	if shared, ok := owner.(sharedOwner); ok {
+		return controllerutil.SetOwnerReference(shared.Object, obj, scheme)
+	}
+	return controllerutil.SetControllerReference(owner, obj, scheme)
+}
+
+// applyDeployment creates desired, controlled by owner, if it doesn't
+// exist yet. If it does, applyDeployment updates the existing deployment
+// so that its labels, pod template and (if manageReplicas is true)
+// replica count match desired, and makes owner its controller. That
+// reverts any changes made to those fields outside of the operator.
+//
+// Fields that desired doesn't set, like the ones the API server defaults,
+// are left alone, and so is the selector, since it can't be changed.
+// Pass false for manageReplicas for deployments that KEDA scales
+func applyDeployment(
+	ctx context.Context,
+	cl client.Client,
+	logger logr.Logger,
+	owner metav1.Object,
+	desired *appsv1.Deployment,
+	manageReplicas bool,
+) (controllerutil.OperationResult, error) {
+	existing := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: desired.Namespace,
+			Name:      desired.Name,
+		},
+	}
+	res, err := controllerutil.CreateOrUpdate(ctx, cl, existing, func() error {
+		existing.Labels = mergeLabels(existing.Labels, desired.Labels)
+		if existing.Spec.Selector == nil {
+			existing.Spec.Selector = desired.Spec.Selector
+		}
+		// the deployment is new if it has no resource version yet
+		if existing.ResourceVersion == "" || manageReplicas {
+			existing.Spec.Replicas = desired.Spec.Replicas
+		}
+		if !equality.Semantic.DeepDerivative(desired.Spec.Template, existing.Spec.Template) {
+			existing.Spec.Template = desired.Spec.Template
+		}
+		return setOwner(owner, existing, cl.Scheme())
+	})
+	logApplyResult(logger, "Deployment", desired.Name, res, err)
+	return res, err
+}
+
+// applyService creates desired, controlled by owner, if it doesn't exist
+// yet. If it does, applyService updates the existing service so that its
+// labels, type, selector and ports match desired, and makes owner its
+// controller. It leaves the cluster IP alone, since it can't be changed
+func applyService(
+	ctx context.Context,
+	cl client.Client,
+	logger logr.Logger,
+	owner metav1.Object,
+	desired *corev1.Service,
+) (controllerutil.OperationResult, error) {
+	existing := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: desired.Namespace,
+			Name:      desired.Name,
+		},
+	}
+	res, err := controllerutil.CreateOrUpdate(ctx, cl, existing, func() error {
+		existing.Labels = mergeLabels(existing.Labels, desired.Labels)
+		existing.Spec.Type = desired.Spec.Type
+		existing.Spec.Selector = desired.Spec.Selector
+		if !equality.Semantic.DeepDerivative(desired.Spec.Ports, existing.Spec.Ports) {
+			existing.Spec.Ports = desired.Spec.Ports
+		}
+		return setOwner(owner, existing, cl.Scheme())
+	})
+	logApplyResult(logger, "Service", desired.Name, res, err)
+	return res, err
+}
+
+// applyUnstructured creates desired, which must be an object with a spec
+// like the KEDA ScaledObjects that k8s.NewScaledObject returns, controlled
+// by owner, if it doesn't exist yet. If it does, applyUnstructured
+// replaces the existing object's spec with desired's, adds desired's
+// labels and annotations to it and makes owner its controller. KEDA's
+// paused-replicas annotation is removed from it if desired doesn't have
+// one
+func applyUnstructured(
+	ctx context.Context,
+	cl client.Client,
+	logger logr.Logger,
+	owner metav1.Object,
+	desired *unstructured.Unstructured,
+) (controllerutil.OperationResult, error) {
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(desired.GroupVersionKind())
+	existing.SetNamespace(desired.GetNamespace())
+	existing.SetName(desired.GetName())
+	res, err := controllerutil.CreateOrUpdate(ctx, cl, existing, func() error {
+		existing.SetLabels(mergeLabels(existing.GetLabels(), desired.GetLabels()))
+		annotations := existing.GetAnnotations()
+		if len(desired.GetAnnotations()) > 0 {
+			annotations = mergeLabels(annotations, desired.GetAnnotations())
+		}
+		// KEDA keeps the target paused for as long as the annotation is
+		// there, so it has to go when desired isn't paused anymore
+		if _, ok := desired.GetAnnotations()[k8s.PausedReplicasAnnotation]; !ok {
+			delete(annotations, k8s.PausedReplicasAnnotation)
+		}
+		existing.SetAnnotations(annotations)
+		existing.Object["spec"] = desired.Object["spec"]
+		return setOwner(owner, existing, cl.Scheme())
+	})
+	logApplyResult(logger, desired.GetKind(), desired.GetName(), res, err)
+	return res, err
+}
+
+// applyIngress creates desired, controlled by owner, if it doesn't exist
+// yet. If it does, applyIngress updates the existing Ingress so that its
+// spec matches desired, adds desired's labels and annotations to it and
+// makes owner its controller
+func applyIngress(
+	ctx context.Context,
+	cl client.Client,
+	logger logr.Logger,
+	owner metav1.Object,
+	desired *networkingv1.Ingress,
+) (*networkingv1.Ingress, controllerutil.OperationResult, error) {
+	existing := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: desired.Namespace,
+			Name:      desired.Name,
+		},
+	}
+	res, err := controllerutil.CreateOrUpdate(ctx, cl, existing, func() error {
+		existing.Labels = mergeLabels(existing.Labels, desired.Labels)
+		if len(desired.Annotations) > 0 {
+			existing.Annotations = mergeLabels(existing.Annotations, desired.Annotations)
+		}
+		if !equality.Semantic.DeepDerivative(desired.Spec, existing.Spec) {
+			existing.Spec = desired.Spec
+		}
+		return setOwner(owner, existing, cl.Scheme())
+	})
+	logApplyResult(logger, "Ingress", desired.Name, res, err)
+	return existing, res, err
+}
+
+// applyConfigMap creates desired, controlled by owner, if it doesn't
+// exist yet. If it does, applyConfigMap updates the existing ConfigMap so
+// that its labels and data match desired, and makes owner its controller
+func applyConfigMap(
+	ctx context.Context,
+	cl client.Client,
+	logger logr.Logger,
+	owner metav1.Object,
+	desired *corev1.ConfigMap,
+) (controllerutil.OperationResult, error) {
+	existing := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: desired.Namespace,
+			Name:      desired.Name,
+		},
+	}
+	res, err := controllerutil.CreateOrUpdate(ctx, cl, existing, func() error {
+		existing.Labels = mergeLabels(existing.Labels, desired.Labels)
+		existing.Data = desired.Data
+		return setOwner(owner, existing, cl.Scheme())
+	})
+	logApplyResult(logger, "ConfigMap", desired.Name, res, err)
+	return res, err
+}
+
+// deleteIfControlled deletes obj, which must have its namespace and name
+// set, if it exists and owner controls it. It does nothing if obj's kind
+// isn't served by the cluster, like when the CRD for it isn't installed
+func deleteIfControlled(
+	ctx context.Context,
+	cl client.Client,
+	logger logr.Logger,
+	owner metav1.Object,
+	obj client.Object,
+) error {
+	err := cl.Get(ctx, client.ObjectKeyFromObject(obj), obj)
+	if errors.IsNotFound(err) || meta.IsNoMatchError(err) || runtime.IsNotRegisteredError(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !metav1.IsControlledBy(obj, owner) {
+		return nil
+	}
+	logger.Info("Deleting object that's no longer needed", "name", obj.GetName())
+	if err := cl.Delete(ctx, obj); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// mergeLabels returns existing with all the labels in desired added to
+// it, overwriting any that existing already has. Labels that only
+// existing has are kept, so that labels added by other tools survive.
+// It works the same way for annotations
+func mergeLabels(existing, desired map[string]string) map[string]string {
+	if existing == nil {
+		existing = make(map[string]string, len(desired))
+	}
+	for k, v := range desired {
+		existing[k] = v
+	}
+	return existing
+}
+
+func logApplyResult(
+	logger logr.Logger,
+	kind,
+	name string,
+	res controllerutil.OperationResult,
+	err error,
+) {
+	if err != nil {
+		logger.Error(err, "Applying "+kind, "name", name)
+		return
+	}
+	logger.Info("Applied "+kind, "name", name, "result", res)
+}
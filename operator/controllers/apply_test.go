@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"github.com/kedacore/http-add-on/pkg/k8s"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+var _ = Describe("Apply", func() {
+	var testInfra *commonTestInfra
+	BeforeEach(func() {
+		testInfra = newCommonTestInfra("testns", "testapp")
+	})
+	Context("Applying a Deployment", func() {
+		newDepl := func(image string) *appsv1.Deployment {
+			return k8s.NewDeployment(
+				testInfra.ns,
+				"testdepl",
+				image,
+				[]int32{8080},
+				nil,
+				k8s.Labels("testdepl"),
+				corev1.PullAlways,
+			)
+		}
+		getDepl := func() *appsv1.Deployment {
+			depl := new(appsv1.Deployment)
+			Expect(testInfra.cl.Get(testInfra.ctx, client.ObjectKey{
+				Namespace: testInfra.ns,
+				Name:      "testdepl",
+			}, depl)).To(BeNil())
+			return depl
+		}
+
+		It("Should create, update and revert drift", func() {
+			res, err := applyDeployment(testInfra.ctx, testInfra.cl, testInfra.logger, &testInfra.httpso, newDepl("image1"), true)
+			Expect(err).To(BeNil())
+			Expect(res).To(Equal(controllerutil.OperationResultCreated))
+
+			// applying the same thing again shouldn't change anything
+			res, err = applyDeployment(testInfra.ctx, testInfra.cl, testInfra.logger, &testInfra.httpso, newDepl("image1"), true)
+			Expect(err).To(BeNil())
+			Expect(res).To(Equal(controllerutil.OperationResultNone))
+
+			// a new image should be rolled out
+			res, err = applyDeployment(testInfra.ctx, testInfra.cl, testInfra.logger, &testInfra.httpso, newDepl("image2"), true)
+			Expect(err).To(BeNil())
+			Expect(res).To(Equal(controllerutil.OperationResultUpdated))
+			Expect(getDepl().Spec.Template.Spec.Containers[0].Image).To(Equal("image2"))
+
+			// manual changes should be reverted, but extra labels kept
+			depl := getDepl()
+			depl.Spec.Template.Spec.Containers[0].Image = "manual"
+			depl.Spec.Replicas = k8s.Int32P(5)
+			depl.Labels["extra"] = "label"
+			Expect(testInfra.cl.Update(testInfra.ctx, depl)).To(BeNil())
+			res, err = applyDeployment(testInfra.ctx, testInfra.cl, testInfra.logger, &testInfra.httpso, newDepl("image2"), true)
+			Expect(err).To(BeNil())
+			Expect(res).To(Equal(controllerutil.OperationResultUpdated))
+			depl = getDepl()
+			Expect(depl.Spec.Template.Spec.Containers[0].Image).To(Equal("image2"))
+			Expect(*depl.Spec.Replicas).To(BeNumerically("==", 1))
+			Expect(depl.Labels["extra"]).To(Equal("label"))
+
+			// the HTTPScaledObject should control it
+			Expect(len(depl.OwnerReferences)).To(Equal(1))
+			ownerRef := depl.OwnerReferences[0]
+			Expect(ownerRef.Kind).To(Equal("HTTPScaledObject"))
+			Expect(ownerRef.Name).To(Equal(testInfra.httpso.Name))
+			Expect(ownerRef.Controller).To(Not(BeNil()))
+			Expect(*ownerRef.Controller).To(BeTrue())
+		})
+
+		It("Should leave replicas alone if it doesn't manage them", func() {
+			_, err := applyDeployment(testInfra.ctx, testInfra.cl, testInfra.logger, &testInfra.httpso, newDepl("image1"), false)
+			Expect(err).To(BeNil())
+			depl := getDepl()
+			Expect(*depl.Spec.Replicas).To(BeNumerically("==", 1))
+
+			// as if KEDA scaled it
+			depl.Spec.Replicas = k8s.Int32P(5)
+			Expect(testInfra.cl.Update(testInfra.ctx, depl)).To(BeNil())
+			res, err := applyDeployment(testInfra.ctx, testInfra.cl, testInfra.logger, &testInfra.httpso, newDepl("image1"), false)
+			Expect(err).To(BeNil())
+			Expect(res).To(Equal(controllerutil.OperationResultNone))
+			Expect(*getDepl().Spec.Replicas).To(BeNumerically("==", 5))
+		})
+	})
+
+	Context("Applying a Service", func() {
+		It("Should update the ports", func() {
+			newSvc := func(port int32) *corev1.Service {
+				return k8s.NewService(
+					testInfra.ns,
+					"testsvc",
+					[]corev1.ServicePort{k8s.NewTCPServicePort("http", port, port)},
+					corev1.ServiceTypeClusterIP,
+					k8s.Labels("testsvc"),
+				)
+			}
+			res, err := applyService(testInfra.ctx, testInfra.cl, testInfra.logger, &testInfra.httpso, newSvc(8080))
+			Expect(err).To(BeNil())
+			Expect(res).To(Equal(controllerutil.OperationResultCreated))
+
+			res, err = applyService(testInfra.ctx, testInfra.cl, testInfra.logger, &testInfra.httpso, newSvc(9090))
+			Expect(err).To(BeNil())
+			Expect(res).To(Equal(controllerutil.OperationResultUpdated))
+			svc := new(corev1.Service)
+			Expect(testInfra.cl.Get(testInfra.ctx, client.ObjectKey{
+				Namespace: testInfra.ns,
+				Name:      "testsvc",
+			}, svc)).To(BeNil())
+			Expect(len(svc.Spec.Ports)).To(Equal(1))
+			Expect(svc.Spec.Ports[0].Port).To(BeNumerically("==", 9090))
+		})
+	})
+})
@@ -3,15 +3,25 @@ package config
 import (
 	"fmt"
 
-	"github.com/kedacore/http-add-on/operator/api/v1alpha1"
+	"github.com/kedacore/http-add-on/operator/api/v1alpha2"
 )
 
-// DeploymentName is a convenience function for
-// a.HTTPScaledObject.Spec.ScaleTargetRef.Deployment
-func DeploymentName(httpso v1alpha1.HTTPScaledObject) string {
-	return httpso.Spec.ScaleTargetRef.Deployment
+// DeploymentName is a convenience function for the name of the first of
+// httpso's targets, which may not be a deployment. The objects that the
+// operator creates for httpso are named after it. Returns empty string if
+// httpso has no targets
+func DeploymentName(httpso v1alpha2.HTTPScaledObject) string {
+	if len(httpso.Spec.Targets) == 0 {
+		return ""
+	}
+	return httpso.Spec.Targets[0].Name
 }
 
+// SharedName is the name that the shared interceptor fleet, its external
+// scaler and its routing table are named after when interceptors are
+// shared (see SharedInterceptors)
+const SharedName = "keda-add-ons-http"
+
 // AppInfo contains configuration for the Interceptor and External Scaler, and holds
 // data about the name and namespace of the scale target.
 type AppInfo struct {
@@ -33,6 +43,17 @@ func (a AppInfo) ExternalScalerDeploymentName() string {
 	return fmt.Sprintf("%s-external-scaler", a.Name)
 }
 
+// ExternalScalerHostName is a convenience method to get the cluster-DNS
+// host and port of the external scaler service in Kubernetes
+func (a AppInfo) ExternalScalerHostName() string {
+	return fmt.Sprintf(
+		"%s.%s.svc.cluster.local:%d",
+		a.ExternalScalerServiceName(),
+		a.Namespace,
+		a.ExternalScalerConfig.Port,
+	)
+}
+
 // InterceptorAdminServiceName is a convenience method to get the name of the interceptor
 // service for the admin endpoints in Kubernetes
 func (a AppInfo) InterceptorAdminServiceName() string {
@@ -51,10 +72,26 @@ func (a AppInfo) InterceptorDeploymentName() string {
 	return fmt.Sprintf("%s-interceptor", a.Name)
 }
 
-func AppScaledObjectName(httpso *v1alpha1.HTTPScaledObject) string {
-	return fmt.Sprintf("%s-app", httpso.Spec.ScaleTargetRef.Deployment)
+// InterceptorScaledObjectName is the name of the KEDA ScaledObject that
+// scales the interceptor deployment
+func (a AppInfo) InterceptorScaledObjectName() string {
+	return a.InterceptorDeploymentName()
+}
+
+// RoutingTableConfigMapName is the name of the ConfigMap that holds the
+// interceptors' routing table
+func (a AppInfo) RoutingTableConfigMapName() string {
+	return fmt.Sprintf("%s-routing-table", a.Name)
+}
+
+// AppScaledObjectName is the name of the KEDA ScaledObject that scales
+// target
+func AppScaledObjectName(target v1alpha2.TargetRef) string {
+	return fmt.Sprintf("%s-app", target.Name)
 }
 
-func InterceptorScaledObjectName(httpso *v1alpha1.HTTPScaledObject) string {
-	return fmt.Sprintf("%s-interceptor", httpso.Spec.ScaleTargetRef.Deployment)
+// IngressName is the name of the Ingress or HTTPRoute that routes traffic
+// from outside the cluster to the interceptor for httpso
+func IngressName(httpso *v1alpha2.HTTPScaledObject) string {
+	return fmt.Sprintf("%s-ingress", httpso.Name)
 }
@@ -7,12 +7,33 @@ import (
 	corev1 "k8s.io/api/core/v1"
 )
 
+const (
+	// DedicatedInterceptors is the interceptor mode in which each
+	// HTTPScaledObject gets its own interceptor and external scaler
+	DedicatedInterceptors = "dedicated"
+	// SharedInterceptors is the interceptor mode in which all the
+	// HTTPScaledObjects in a namespace share a single interceptor fleet
+	// and external scaler, and each one only adds its routes to the
+	// fleet's routing table
+	SharedInterceptors = "shared"
+)
+
 // Interceptor holds static configuration info for the interceptor
 type Interceptor struct {
 	Image     string
 	ProxyPort int32
 	AdminPort int32
 	PullPolicy corev1.PullPolicy
+	// Shared is whether all the HTTPScaledObjects in a namespace share
+	// an interceptor fleet (see SharedInterceptors)
+	Shared bool
+	// MinReplicas, MaxReplicas and TargetPendingRequests are how
+	// interceptors are scaled if their HTTPScaledObject doesn't say
+	// otherwise, and how shared interceptors are always scaled.
+	// MinReplicas is at least 1, so that interceptors never scale to zero
+	MinReplicas           int32
+	MaxReplicas           int32
+	TargetPendingRequests int32
 }
 
 func ensureValidPolicy (policy string) error {
@@ -39,11 +60,33 @@ func NewInterceptorFromEnv() (*Interceptor, error) {
 		return nil, policyErr
 	}
 
+	mode := env.GetOr("KEDAHTTP_OPERATOR_INTERCEPTOR_MODE", DedicatedInterceptors)
+	if mode != DedicatedInterceptors && mode != SharedInterceptors {
+		return nil, fmt.Errorf("Mode %q is not a valid interceptor mode. Accepted values are: %s, %s", mode, DedicatedInterceptors, SharedInterceptors)
+	}
+
+	minReplicas := env.GetInt32Or("KEDAHTTP_OPERATOR_INTERCEPTOR_MIN_REPLICAS", 1)
+	maxReplicas := env.GetInt32Or("KEDAHTTP_OPERATOR_INTERCEPTOR_MAX_REPLICAS", 100)
+	targetPendingRequests := env.GetInt32Or("KEDAHTTP_OPERATOR_INTERCEPTOR_TARGET_PENDING_REQUESTS", 100)
+	if minReplicas < 1 {
+		return nil, fmt.Errorf("KEDAHTTP_OPERATOR_INTERCEPTOR_MIN_REPLICAS must be at least 1, got %d", minReplicas)
+	}
+	if maxReplicas < minReplicas {
+		return nil, fmt.Errorf("KEDAHTTP_OPERATOR_INTERCEPTOR_MAX_REPLICAS (%d) must not be less than KEDAHTTP_OPERATOR_INTERCEPTOR_MIN_REPLICAS (%d)", maxReplicas, minReplicas)
+	}
+	if targetPendingRequests < 1 {
+		return nil, fmt.Errorf("KEDAHTTP_OPERATOR_INTERCEPTOR_TARGET_PENDING_REQUESTS must be positive, got %d", targetPendingRequests)
+	}
+
 	return &Interceptor{
 		Image:     image,
 		AdminPort: adminPort,
 		ProxyPort: proxyPort,
 		PullPolicy: corev1.PullPolicy(pullPolicy),
+		Shared: mode == SharedInterceptors,
+		MinReplicas: minReplicas,
+		MaxReplicas: maxReplicas,
+		TargetPendingRequests: targetPendingRequests,
 	}, nil
 }
 
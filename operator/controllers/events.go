@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+
+	"github.com/kedacore/http-add-on/operator/api/v1alpha2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// eventClient is a client.Client that records an event on httpso for
+// every object that it creates, updates or deletes, and for every
+// failure to. Since the apply functions only update objects that
+// changed, this tells the story of what the operator did for httpso
+// without an event for every reconcile.
+//
+// Status updates aren't recorded
+type eventClient struct {
+	client.Client
+	recorder record.EventRecorder
+	httpso   *v1alpha2.HTTPScaledObject
+}
+
+// eventClient returns a client that records events on httpso for the
+// changes it makes
+func (rec *HTTPScaledObjectReconciler) eventClient(httpso *v1alpha2.HTTPScaledObject) client.Client {
+	return eventClient{
+		Client:   rec.Client,
+		recorder: rec.Recorder,
+		httpso:   httpso,
+	}
+}
+
+func (c eventClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	err := c.Client.Create(ctx, obj, opts...)
+	c.record(obj, "Create", err)
+	return err
+}
+
+func (c eventClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	err := c.Client.Update(ctx, obj, opts...)
+	c.record(obj, "Update", err)
+	return err
+}
+
+func (c eventClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	err := c.Client.Delete(ctx, obj, opts...)
+	// there's nothing to tell if it was already gone
+	if errors.IsNotFound(err) {
+		return err
+	}
+	c.record(obj, "Delete", err)
+	return err
+}
+
+// record records an event on c.httpso for doing verb (Create, Update or
+// Delete) to obj: a Normal event with the Successful<verb> reason, or a
+// Warning event with the Failed<verb> reason if err isn't nil
+func (c eventClient) record(obj client.Object, verb string, err error) {
+	kind := obj.GetObjectKind().GroupVersionKind().Kind
+	if kind == "" {
+		if gvk, gvkErr := apiutil.GVKForObject(obj, c.Scheme()); gvkErr == nil {
+			kind = gvk.Kind
+		}
+	}
+	if err != nil {
+		resourceErrorsTotal.WithLabelValues(kind, verb).Inc()
+		c.recorder.Eventf(
+			c.httpso,
+			corev1.EventTypeWarning,
+			"Failed"+verb,
+			"Failed to %s %s %s: %s",
+			strings.ToLower(verb),
+			kind,
+			obj.GetName(),
+			err,
+		)
+		return
+	}
+	c.recorder.Eventf(c.httpso, corev1.EventTypeNormal, "Successful"+verb, "%sd %s %s", verb, kind, obj.GetName())
+}
+
+// failureReason returns the reason of the condition that explains why
+// httpso isn't ready, like ErrorCreatingInterceptorAdminService, or
+// ErrorCreatingAppResources if no condition does
+func failureReason(httpso *v1alpha2.HTTPScaledObject) string {
+	for _, cond := range httpso.Status.Conditions {
+		if cond.Type != string(v1alpha2.Ready) && cond.Status == metav1.ConditionFalse {
+			return cond.Reason
+		}
+	}
+	return string(v1alpha2.ErrorCreatingAppResources)
+}
+
+// recordFailure records a Warning event on httpso for err, with the
+// reason of the condition that explains it
+func (rec *HTTPScaledObjectReconciler) recordFailure(httpso *v1alpha2.HTTPScaledObject, err error) {
+	rec.Recorder.Event(httpso, corev1.EventTypeWarning, failureReason(httpso), err.Error())
+}
@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"fmt"
+
+	"github.com/kedacore/http-add-on/operator/api/v1alpha2"
+	"github.com/kedacore/http-add-on/pkg/k8s"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Events", func() {
+	Context("Recording events for changed objects", func() {
+		var testInfra *commonTestInfra
+		var recorder *record.FakeRecorder
+		var cl client.Client
+		BeforeEach(func() {
+			testInfra = newCommonTestInfra("testns", "testapp")
+			recorder = record.NewFakeRecorder(10)
+			rec := &HTTPScaledObjectReconciler{
+				Client:   testInfra.cl,
+				Recorder: recorder,
+			}
+			cl = rec.eventClient(&testInfra.httpso)
+		})
+
+		// events returns the events recorded so far
+		events := func() []string {
+			var ret []string
+			for {
+				select {
+				case event := <-recorder.Events:
+					ret = append(ret, event)
+				default:
+					return ret
+				}
+			}
+		}
+		newDepl := func() *appsv1.Deployment {
+			return k8s.NewDeployment(
+				testInfra.ns,
+				"testdepl",
+				"testimage",
+				[]int32{8080},
+				nil,
+				map[string]string{"app": "testdepl"},
+				corev1.PullAlways,
+			)
+		}
+
+		It("Should record creations, updates and deletions", func() {
+			_, err := applyDeployment(testInfra.ctx, cl, testInfra.logger, &testInfra.httpso, newDepl(), true)
+			Expect(err).To(BeNil())
+			Expect(events()).To(Equal([]string{"Normal SuccessfulCreate Created Deployment testdepl"}))
+
+			// nothing changed, so there's nothing to tell
+			_, err = applyDeployment(testInfra.ctx, cl, testInfra.logger, &testInfra.httpso, newDepl(), true)
+			Expect(err).To(BeNil())
+			Expect(events()).To(BeEmpty())
+
+			changed := newDepl()
+			changed.Spec.Template.Spec.Containers[0].Image = "otherimage"
+			_, err = applyDeployment(testInfra.ctx, cl, testInfra.logger, &testInfra.httpso, changed, true)
+			Expect(err).To(BeNil())
+			Expect(events()).To(Equal([]string{"Normal SuccessfulUpdate Updated Deployment testdepl"}))
+
+			Expect(cl.Delete(testInfra.ctx, changed)).To(BeNil())
+			Expect(events()).To(Equal([]string{"Normal SuccessfulDelete Deleted Deployment testdepl"}))
+
+			// it was already gone
+			Expect(cl.Delete(testInfra.ctx, changed)).To(Not(BeNil()))
+			Expect(events()).To(BeEmpty())
+		})
+
+		It("Should record failures", func() {
+			depl := newDepl()
+			Expect(testInfra.cl.Create(testInfra.ctx, depl)).To(BeNil())
+			Expect(cl.Create(testInfra.ctx, newDepl())).To(Not(BeNil()))
+			recorded := events()
+			Expect(recorded).To(HaveLen(1))
+			Expect(recorded[0]).To(HavePrefix("Warning FailedCreate Failed to create Deployment testdepl: "))
+		})
+
+		It("Should explain failed reconciles with the failed condition's reason", func() {
+			rec := &HTTPScaledObjectReconciler{Recorder: recorder}
+			testInfra.httpso.SetCondition(
+				v1alpha2.InterceptorReady,
+				metav1.ConditionFalse,
+				v1alpha2.ErrorCreatingInterceptorAdminService,
+				"failed",
+			)
+			rec.recordFailure(&testInfra.httpso, fmt.Errorf("creating the admin service"))
+			Expect(events()).To(Equal([]string{
+				"Warning ErrorCreatingInterceptorAdminService creating the admin service",
+			}))
+		})
+	})
+})
@@ -6,24 +6,24 @@ import (
 	"time"
 
 	"github.com/go-logr/logr"
-	"github.com/kedacore/http-add-on/operator/api/v1alpha1"
+	"github.com/kedacore/http-add-on/operator/api/v1alpha2"
 	"github.com/kedacore/http-add-on/operator/controllers/config"
 	"github.com/kedacore/http-add-on/pkg/k8s"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-// creates the external scaler and returns the cluster-DNS hostname of it.
+// creates the external scaler, or updates it to match the operator's config if
+// it already exists, and returns the cluster-DNS hostname of it.
 // if something went wrong creating it, returns empty string and a non-nil error
 func createExternalScaler(
 	ctx context.Context,
 	appInfo config.AppInfo,
 	cl client.Client,
 	logger logr.Logger,
-	httpso *v1alpha1.HTTPScaledObject,
+	httpso *v1alpha2.HTTPScaledObject,
 ) (string, error) {
 	scalerPort := appInfo.ExternalScalerConfig.Port
 	healthCheckPort := scalerPort + 1
@@ -65,8 +65,7 @@ func createExternalScaler(
 		int(healthCheckPort),
 	); err != nil {
 		logger.Error(err, "Creating liveness check")
-		condition := v1alpha1.CreateCondition(v1alpha1.Error, metav1.ConditionFalse, v1alpha1.ErrorCreatingExternalScaler).SetMessage(err.Error())
-		httpso.AddCondition(*condition)
+		httpso.SetCondition(v1alpha2.ExternalScalerReady, metav1.ConditionFalse, v1alpha2.ErrorCreatingExternalScaler, err.Error())
 		return "", err
 	}
 
@@ -76,21 +75,13 @@ func createExternalScaler(
 		int(healthCheckPort),
 	); err != nil {
 		logger.Error(err, "Creating readiness check")
-		condition := v1alpha1.CreateCondition(v1alpha1.Error, metav1.ConditionFalse, v1alpha1.ErrorCreatingExternalScaler).SetMessage(err.Error())
-		httpso.AddCondition(*condition)
+		httpso.SetCondition(v1alpha2.ExternalScalerReady, metav1.ConditionFalse, v1alpha2.ErrorCreatingExternalScaler, err.Error())
 		return "", err
 	}
 
-	logger.Info("Creating external scaler Deployment", "Deployment", *scalerDeployment)
-	if err := cl.Create(ctx, scalerDeployment); err != nil {
-		if errors.IsAlreadyExists(err) {
-			logger.Info("External scaler deployment already exists, moving on")
-		} else {
-			logger.Error(err, "Creating scaler deployment")
-			condition := v1alpha1.CreateCondition(v1alpha1.Error, metav1.ConditionFalse, v1alpha1.ErrorCreatingExternalScaler).SetMessage(err.Error())
-			httpso.AddCondition(*condition)
-			return "", err
-		}
+	if _, err := applyDeployment(ctx, cl, logger, interceptorOwner(appInfo, httpso), scalerDeployment, true); err != nil {
+		httpso.SetCondition(v1alpha2.ExternalScalerReady, metav1.ConditionFalse, v1alpha2.ErrorCreatingExternalScaler, err.Error())
+		return "", err
 	}
 
 	// NOTE: Scaler port is fixed here because it's a fixed on the scaler main (@see ../scaler/main.go:17)
@@ -108,26 +99,12 @@ func createExternalScaler(
 		corev1.ServiceTypeClusterIP,
 		k8s.Labels(appInfo.ExternalScalerDeploymentName()),
 	)
-	logger.Info("Creating external scaler Service", "Service", *scalerService)
-	if err := cl.Create(ctx, scalerService); err != nil {
-		if errors.IsAlreadyExists(err) {
-			logger.Info("External scaler service already exists, moving on")
-		} else {
-			logger.Error(err, "Creating scaler service")
-			condition := v1alpha1.CreateCondition(v1alpha1.Error, metav1.ConditionFalse, v1alpha1.ErrorCreatingExternalScalerService).SetMessage(err.Error())
-			httpso.AddCondition(*condition)
-			return "", err
-		}
+	if _, err := applyService(ctx, cl, logger, interceptorOwner(appInfo, httpso), scalerService); err != nil {
+		httpso.SetCondition(v1alpha2.ExternalScalerReady, metav1.ConditionFalse, v1alpha2.ErrorCreatingExternalScalerService, err.Error())
+		return "", err
 	}
-	condition := v1alpha1.CreateCondition(v1alpha1.Created, metav1.ConditionTrue, v1alpha1.CreatedExternalScaler).SetMessage("External scaler object is created")
-	httpso.AddCondition(*condition)
-	externalScalerHostName := fmt.Sprintf(
-		"%s.%s.svc.cluster.local:%d",
-		appInfo.ExternalScalerServiceName(),
-		appInfo.Namespace,
-		appInfo.ExternalScalerConfig.Port,
-	)
-	return externalScalerHostName, nil
+	httpso.SetCondition(v1alpha2.ExternalScalerReady, metav1.ConditionTrue, v1alpha2.CreatedExternalScaler, "External scaler object is created")
+	return appInfo.ExternalScalerHostName(), nil
 }
 
 // waitForScaler uses the gRPC scaler client's IsActive call to determine
@@ -4,7 +4,7 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/kedacore/http-add-on/operator/api/v1alpha1"
+	"github.com/kedacore/http-add-on/operator/api/v1alpha2"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	appsv1 "k8s.io/api/apps/v1"
@@ -39,12 +39,10 @@ var _ = Describe("ExternalScaler", func() {
 			// // make sure that httpso has the right conditions on it
 			Expect(len(testInfra.httpso.Status.Conditions)).To(Equal(1))
 			cond1 := testInfra.httpso.Status.Conditions[0]
-			cond1ts, err := time.Parse(time.RFC3339, cond1.Timestamp)
-			Expect(err).To(BeNil())
-			Expect(time.Now().Sub(cond1ts) >= 0).To(BeTrue())
-			Expect(cond1.Type).To(Equal(v1alpha1.Created))
+			Expect(time.Since(cond1.LastTransitionTime.Time) >= 0).To(BeTrue())
+			Expect(cond1.Type).To(Equal(string(v1alpha2.ExternalScalerReady)))
 			Expect(cond1.Status).To(Equal(metav1.ConditionTrue))
-			Expect(cond1.Reason).To(Equal(v1alpha1.CreatedExternalScaler))
+			Expect(cond1.Reason).To(Equal(string(v1alpha2.CreatedExternalScaler)))
 
 			// check that the external scaler deployment was created
 			deployment := new(appsv1.Deployment)
@@ -4,7 +4,7 @@ import (
 	"context"
 
 	"github.com/go-logr/logr"
-	httpv1alpha1 "github.com/kedacore/http-add-on/operator/api/v1alpha1"
+	httpv1alpha2 "github.com/kedacore/http-add-on/operator/api/v1alpha2"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -12,37 +12,15 @@ const (
 	httpScaledObjectFinalizer = "httpscaledobject.http.keda.sh"
 )
 
-// ensureFinalizer check there is finalizer present on the ScaledObject, if not it adds one
-func ensureFinalizer(
-	ctx context.Context,
-	logger logr.Logger,
-	client client.Client,
-	httpso *httpv1alpha1.HTTPScaledObject,
-) error {
-	if !contains(httpso.GetFinalizers(), httpScaledObjectFinalizer) {
-		logger.Info("Adding Finalizer for the ScaledObject")
-		httpso.SetFinalizers(append(httpso.GetFinalizers(), httpScaledObjectFinalizer))
-
-		// Update CR
-		err := client.Update(ctx, httpso)
-		if err != nil {
-			logger.Error(
-				err,
-				"Failed to update HTTPScaledObject with a finalizer",
-				"finalizer",
-				httpScaledObjectFinalizer,
-			)
-			return err
-		}
-	}
-	return nil
-}
-
+// finalizeScaledObject removes the finalizer that older versions of the
+// operator added to HTTPScaledObjects, if it's there. The operator no
+// longer adds it, since the objects it creates for an HTTPScaledObject
+// are owned by it and garbage collected along with it
 func finalizeScaledObject(
 	ctx context.Context,
 	logger logr.Logger,
 	client client.Client,
-	httpso *httpv1alpha1.HTTPScaledObject) error {
+	httpso *httpv1alpha2.HTTPScaledObject) error {
 	if contains(httpso.GetFinalizers(), httpScaledObjectFinalizer) {
 
 		httpso.SetFinalizers(remove(httpso.GetFinalizers(), httpScaledObjectFinalizer))
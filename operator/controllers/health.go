@@ -0,0 +1,48 @@
+package controllers
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+)
+
+// healthCheckTimeout is how long each readiness check waits before it
+// reports that the operator isn't ready
+const healthCheckTimeout = 2 * time.Second
+
+// CacheSyncCheck returns a readiness check that fails until c has synced
+// the objects that the operator watches. Until it has, the reconciler
+// would work from an incomplete picture of the cluster
+func CacheSyncCheck(c cache.Cache) healthz.Checker {
+	return func(req *http.Request) error {
+		ctx, cancel := context.WithTimeout(req.Context(), healthCheckTimeout)
+		defer cancel()
+		if !c.WaitForCacheSync(ctx) {
+			return errors.New("informer caches haven't synced")
+		}
+		return nil
+	}
+}
+
+// WebhookCheck returns a readiness check that fails until the webhook
+// server at addr accepts TLS connections. Only the API server needs to
+// trust its certificate, so the certificate isn't verified here
+func WebhookCheck(addr string) healthz.Checker {
+	return func(req *http.Request) error {
+		dialer := &net.Dialer{Timeout: healthCheckTimeout}
+		conn, err := tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{
+			InsecureSkipVerify: true,
+		})
+		if err != nil {
+			return fmt.Errorf("webhook server isn't serving: %w", err)
+		}
+		return conn.Close()
+	}
+}
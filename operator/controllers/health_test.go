@@ -0,0 +1,48 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+)
+
+// fakeCache is a cache.Cache that's synced or not. It only implements
+// WaitForCacheSync
+type fakeCache struct {
+	cache.Cache
+	synced bool
+}
+
+func (c fakeCache) WaitForCacheSync(context.Context) bool {
+	return c.synced
+}
+
+var _ = Describe("Health checks", func() {
+	req := func() *http.Request {
+		r, err := http.NewRequest("GET", "/readyz", nil)
+		Expect(err).To(BeNil())
+		return r
+	}
+
+	Context("Checking the cache", func() {
+		It("Should only be ready once the cache has synced", func() {
+			Expect(CacheSyncCheck(fakeCache{synced: false})(req())).To(Not(BeNil()))
+			Expect(CacheSyncCheck(fakeCache{synced: true})(req())).To(BeNil())
+		})
+	})
+
+	Context("Checking the webhook server", func() {
+		It("Should only be ready while the webhook server is serving", func() {
+			srv := httptest.NewTLSServer(http.NotFoundHandler())
+			addr := srv.Listener.Addr().String()
+			Expect(WebhookCheck(addr)(req())).To(BeNil())
+
+			srv.Close()
+			Expect(WebhookCheck(addr)(req())).To(Not(BeNil()))
+		})
+	})
+})
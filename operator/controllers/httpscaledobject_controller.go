@@ -18,36 +18,61 @@ package controllers
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
-	httpv1alpha1 "github.com/kedacore/http-add-on/operator/api/v1alpha1"
+	httpv1alpha2 "github.com/kedacore/http-add-on/operator/api/v1alpha2"
 	"github.com/kedacore/http-add-on/operator/controllers/config"
 )
 
 // HTTPScaledObjectReconciler reconciles a HTTPScaledObject object
 type HTTPScaledObjectReconciler struct {
 	client.Client
-	Log                  logr.Logger
-	Scheme               *runtime.Scheme
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+	// Recorder records events on HTTPScaledObjects for the objects that
+	// the operator creates, updates and deletes for them, and for
+	// reconciles that fail
+	Recorder             record.EventRecorder
 	InterceptorConfig    config.Interceptor
 	ExternalScalerConfig config.ExternalScaler
+	// StatusRefreshInterval is how often each HTTPScaledObject's replica
+	// and pending request counts are refreshed, apart from reconciles. If
+	// it's 0, they're only refreshed when the HTTPScaledObject is
+	// reconciled
+	StatusRefreshInterval time.Duration
+	// pendingRequests gets the pending request counts for the status.
+	// SetupWithManager defaults it to asking the external scalers over
+	// connections that it keeps open
+	pendingRequests pendingRequestsFunc
 }
 
 // +kubebuilder:rbac:groups=keda.sh,resources=scaledobjects,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=http.keda.sh,resources=httpscaledobjects,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=http.keda.sh,resources=httpscaledobjects/status,verbs=get;update;patch
-// +kubebuilder:rbac:groups="",resources=pods;services;configmaps;endpoints;endpoint,verbs=get;list;watch;create;delete
-// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;delete
-// +kubebuilder:rbac:groups=networking,resources=ingresses,verbs=get;list;watch;create;delete
+// +kubebuilder:rbac:groups="",resources=pods;services;configmaps;endpoints;endpoint,verbs=get;list;watch;create;update;delete
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;delete
+// +kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses,verbs=get;list;watch;create;update;delete
+// +kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=httproutes,verbs=get;list;watch;create;update;delete
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 // +kubebuilder:rbac:groups=coordination.k8s.io,resources=leases,verbs=get;list;create;update;delete
 
 // Reconcile reconciles a newly created, deleted, or otherwise changed
@@ -57,54 +82,72 @@ func (rec *HTTPScaledObjectReconciler) Reconcile(ctx context.Context, req ctrl.R
 	logger.Info("Reconciliation start")
 
 	_ = rec.Log.WithValues("httpscaledobject", req.NamespacedName)
-	httpso := &httpv1alpha1.HTTPScaledObject{}
+	httpso := &httpv1alpha2.HTTPScaledObject{}
 
 	if err := rec.Client.Get(ctx, req.NamespacedName, httpso); err != nil {
 		if errors.IsNotFound(err) {
 			// If the HTTPScaledObject wasn't found, it might have
 			// been deleted between the reconcile and the get.
 			// It'll automatically get garbage collected, so don't
-			// schedule a requeue
+			// schedule a requeue. If interceptors are shared, its
+			// routes still need to be taken out of the routing table
 			logger.Info("HTTPScaledObject not found, assuming it was deleted and stopping early")
+			trackApp(req.NamespacedName, false)
+			if rec.InterceptorConfig.Shared {
+				if _, err := updateSharedRoutingTable(
+					ctx,
+					rec.Client,
+					logger,
+					rec.appInfo(nil, req.Namespace),
+					nil,
+				); err != nil {
+					logger.Error(err, "Removing deleted HTTPScaledObject from the routing table")
+					return ctrl.Result{}, err
+				}
+			}
 			return ctrl.Result{}, nil
 		}
 		// if we didn't get a not found error, log it and schedule a requeue
 		// with a backoff
 		logger.Error(err, "Getting the HTTP Scaled obj, requeueing")
+		countReconcile(req.NamespacedName, reconcileError)
 		return ctrl.Result{
 			RequeueAfter: 500 * time.Millisecond,
 		}, err
 	}
 
-	appInfo := config.AppInfo{
-		Name:                 httpso.Spec.ScaleTargetRef.Deployment,
-		Namespace:            req.Namespace,
-		InterceptorConfig:    rec.InterceptorConfig,
-		ExternalScalerConfig: rec.ExternalScalerConfig,
-	}
+	appInfo := rec.appInfo(httpso, req.Namespace)
 
 	if httpso.GetDeletionTimestamp() != nil {
+		// all the objects we created for httpso are owned by it, so
+		// Kubernetes garbage collects them. HTTPScaledObjects created by
+		// older versions of the operator may still have our finalizer,
+		// so remove it to let the deletion go through
 		logger.Info("Deletion timestamp found", "httpscaledobject", *httpso)
-		// if it was marked deleted, delete all the related objects
-		// and don't schedule for another reconcile. Kubernetes
-		// will finalize them
-		// TODO: move this function call into `finalizeScaledObject`
-		removeErr := rec.removeApplicationResources(ctx, logger, appInfo, httpso)
-		if removeErr != nil {
-			// if we failed to remove app resources, reschedule a reconcile so we can try
-			// again
-			logger.Error(removeErr, "Removing application objects")
-			return ctrl.Result{
-				RequeueAfter: 1000 * time.Millisecond,
-			}, removeErr
-		}
-		// after we've deleted app objects, we can finalize
-		return ctrl.Result{}, finalizeScaledObject(ctx, logger, rec.Client, httpso)
+		trackApp(req.NamespacedName, false)
+		start := time.Now()
+		err := finalizeScaledObject(ctx, logger, rec.Client, httpso)
+		finalizeDuration.Observe(time.Since(start).Seconds())
+		return ctrl.Result{}, err
 	}
+	trackApp(req.NamespacedName, true)
 
-	// ensure finalizer is set on this resource
-	if err := ensureFinalizer(ctx, logger, rec.Client, httpso); err != nil {
-		return ctrl.Result{}, err
+	// without the webhook, nothing stops an HTTPScaledObject with no
+	// targets from being created. there's nothing to do for it until
+	// it's fixed, so don't requeue
+	if len(httpso.Spec.Targets) == 0 {
+		err := fmt.Errorf("HTTPScaledObject %s has no targets", req.NamespacedName)
+		logger.Error(err, "Invalid HTTPScaledObject")
+		countReconcile(req.NamespacedName, reconcileInvalid)
+		rec.recordFailure(httpso, err)
+		httpso.Status.ObservedGeneration = httpso.Generation
+		httpso.SetCondition(
+			httpv1alpha2.Ready,
+			v1.ConditionFalse,
+			httpv1alpha2.ErrorCreatingAppResources,
+			err.Error(),
+		).SaveStatus(ctx, logger, rec.Client)
+		return ctrl.Result{}, nil
 	}
 
 	// httpso is updated now
@@ -123,29 +166,127 @@ func (rec *HTTPScaledObjectReconciler) Reconcile(ctx context.Context, req ctrl.R
 		appInfo,
 		httpso,
 	); err != nil {
-		// if we failed to create app resources, remove what we've created and exit
-		logger.Error(err, "Removing app resources")
-		if removeErr := rec.removeApplicationResources(
-			ctx,
-			logger,
-			appInfo,
-			httpso); removeErr != nil {
-			logger.Error(removeErr, "Removing previously created resources")
-		}
-
+		// if we failed to create app resources, leave what we've created
+		// in place and try again later. whatever we created is owned by
+		// httpso, so it'll be garbage collected if httpso is deleted
+		logger.Error(err, "Creating or updating app resources")
+		countReconcile(req.NamespacedName, reconcileError)
+		rec.recordFailure(httpso, err)
+		httpso.Status.ObservedGeneration = httpso.Generation
+		httpso.SetCondition(
+			httpv1alpha2.Ready,
+			v1.ConditionFalse,
+			httpv1alpha2.ErrorCreatingAppResources,
+			err.Error(),
+		).SaveStatus(ctx, logger, rec.Client)
 		return ctrl.Result{}, err
 	}
-	httpso.AddCondition(*httpv1alpha1.CreateCondition(httpv1alpha1.Ready, v1.ConditionTrue, httpv1alpha1.HTTPScaledObjectIsReady).SetMessage("Finished object creation")).
-		SaveStatus(ctx, logger, rec.Client)
+	refreshStatus(ctx, appInfo, rec.Client, logger, rec.pendingRequests, httpso)
+	httpso.Status.ObservedGeneration = httpso.Generation
+	httpso.SetCondition(
+		httpv1alpha2.Ready,
+		v1.ConditionTrue,
+		httpv1alpha2.HTTPScaledObjectIsReady,
+		"Finished object creation",
+	).SaveStatus(ctx, logger, rec.Client)
 
-	// success reconciling
+	// success reconciling. the status refresh loop keeps the status up
+	// to date from here
 	logger.Info("Reconcile success")
+	countReconcile(req.NamespacedName, reconcileSuccess)
 	return ctrl.Result{}, nil
 }
 
-// SetupWithManager starts up reconciliation with the given manager
+// appInfo returns the AppInfo for httpso in namespace. The interceptor
+// and external scaler objects are named after httpso's first target, or
+// after config.SharedName if interceptors are shared. httpso may be nil
+// if interceptors are shared
+func (rec *HTTPScaledObjectReconciler) appInfo(
+	httpso *httpv1alpha2.HTTPScaledObject,
+	namespace string,
+) config.AppInfo {
+	name := config.SharedName
+	if !rec.InterceptorConfig.Shared {
+		name = config.DeploymentName(*httpso)
+	}
+	return config.AppInfo{
+		Name:                 name,
+		Namespace:            namespace,
+		InterceptorConfig:    rec.InterceptorConfig,
+		ExternalScalerConfig: rec.ExternalScalerConfig,
+	}
+}
+
+// SetupWithManager starts up reconciliation with the given manager. Besides
+// HTTPScaledObjects, it watches the Deployments, Services, ConfigMaps,
+// Ingresses and ScaledObjects that they own, so that the objects are
+// recreated or reverted if they're deleted or changed outside of the
+// operator. HTTPRoutes aren't watched, since the Gateway API CRDs may not
+// be installed, so changes to them are only reverted the next time the
+// HTTPScaledObject is reconciled.
+//
+// If StatusRefreshInterval isn't 0, it also adds a loop to mgr that
+// refreshes the status of every HTTPScaledObject on that interval. Like
+// the controller, the loop only runs on the leader.
+//
+// Objects that HTTPScaledObjects share, like a shared interceptor fleet,
+// aren't controlled by any one of them, so changes to an object are
+// reconciled for all of its owners
 func (rec *HTTPScaledObjectReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if rec.pendingRequests == nil {
+		conns := newScalerConns()
+		rec.pendingRequests = conns.pendingRequests
+		if err := mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+			<-ctx.Done()
+			conns.close()
+			return nil
+		})); err != nil {
+			return err
+		}
+	}
+	if rec.StatusRefreshInterval > 0 {
+		if err := mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+			return rec.startStatusRefreshLoop(ctx, rec.StatusRefreshInterval)
+		})); err != nil {
+			return err
+		}
+	}
+	scaledObject := &unstructured.Unstructured{}
+	scaledObject.SetGroupVersionKind(schema.GroupVersionKind{
+		Group:   "keda.sh",
+		Kind:    "ScaledObject",
+		Version: "v1alpha1",
+	})
+	// deployments and ScaledObjects get status updates all the time, so
+	// only reconcile when their specs change. services don't have a
+	// generation, so watch all their changes
+	owners := &handler.EnqueueRequestForOwner{
+		OwnerType:    &httpv1alpha2.HTTPScaledObject{},
+		IsController: false,
+	}
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&httpv1alpha1.HTTPScaledObject{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		// the pause annotations don't change the generation, so
+		// HTTPScaledObjects are also reconciled when their annotations do
+		For(&httpv1alpha2.HTTPScaledObject{}, builder.WithPredicates(predicate.Or(
+			predicate.GenerationChangedPredicate{},
+			predicate.AnnotationChangedPredicate{},
+		))).
+		Watches(
+			&source.Kind{Type: &appsv1.Deployment{}},
+			owners,
+			builder.WithPredicates(predicate.GenerationChangedPredicate{}),
+		).
+		Watches(&source.Kind{Type: &corev1.Service{}}, owners).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, owners).
+		Watches(
+			&source.Kind{Type: &networkingv1.Ingress{}},
+			owners,
+			builder.WithPredicates(predicate.GenerationChangedPredicate{}),
+		).
+		Watches(
+			&source.Kind{Type: scaledObject},
+			owners,
+			builder.WithPredicates(predicate.GenerationChangedPredicate{}),
+		).
 		Complete(rec)
 }
@@ -5,228 +5,16 @@ import (
 	"time"
 
 	"github.com/go-logr/logr"
-	"github.com/kedacore/http-add-on/operator/api/v1alpha1"
+	"github.com/kedacore/http-add-on/operator/api/v1alpha2"
 	"github.com/kedacore/http-add-on/operator/controllers/config"
-	appsv1 "k8s.io/api/apps/v1"
-	corev1 "k8s.io/api/core/v1"
-	apierrs "k8s.io/apimachinery/pkg/api/errors"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
-func (rec *HTTPScaledObjectReconciler) removeApplicationResources(
-	ctx context.Context,
-	logger logr.Logger,
-	appInfo config.AppInfo,
-	httpso *v1alpha1.HTTPScaledObject,
-) error {
-
-	defer httpso.SaveStatus(context.Background(), logger, rec.Client)
-	// Set initial statuses
-	httpso.AddCondition(*v1alpha1.CreateCondition(
-		v1alpha1.Terminating,
-		v1.ConditionUnknown,
-		v1alpha1.TerminatingResources,
-	).SetMessage("Received termination signal"))
-
-	logger = rec.Log.WithValues(
-		"reconciler.appObjects",
-		"removeObjects",
-		"HTTPScaledObject.name",
-		appInfo.Name,
-		"HTTPScaledObject.namespace",
-		appInfo.Namespace,
-	)
-
-	// Delete interceptor deployment
-	interceptorDeployment := &appsv1.Deployment{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      appInfo.InterceptorDeploymentName(),
-			Namespace: appInfo.Namespace,
-		},
-	}
-	if err := rec.Client.Delete(ctx, interceptorDeployment); err != nil {
-		if apierrs.IsNotFound(err) {
-			logger.Info("Interceptor deployment not found, moving on")
-		} else {
-			logger.Error(err, "Deleting interceptor deployment")
-			httpso.AddCondition(*v1alpha1.CreateCondition(
-				v1alpha1.Error,
-				v1.ConditionFalse,
-				v1alpha1.InterceptorDeploymentTerminationError,
-			).SetMessage(err.Error()))
-			return err
-		}
-	}
-	httpso.AddCondition(*v1alpha1.CreateCondition(
-		v1alpha1.Terminated,
-		v1.ConditionTrue,
-		v1alpha1.InterceptorDeploymentTerminated,
-	))
-
-	// Delete externalscaler deployment
-	externalScalerDeployment := &appsv1.Deployment{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      appInfo.ExternalScalerDeploymentName(),
-			Namespace: appInfo.Namespace,
-		},
-	}
-	if err := rec.Client.Delete(ctx, externalScalerDeployment); err != nil {
-		if apierrs.IsNotFound(err) {
-			logger.Info("External scaler not found, moving on")
-		} else {
-			logger.Error(err, "Deleting external scaler deployment")
-			httpso.AddCondition(*v1alpha1.CreateCondition(
-				v1alpha1.Error,
-				v1.ConditionFalse,
-				v1alpha1.ExternalScalerDeploymentTerminationError,
-			).SetMessage(err.Error()))
-			return err
-		}
-	}
-	httpso.AddCondition(*v1alpha1.CreateCondition(
-		v1alpha1.Terminated,
-		v1.ConditionTrue,
-		v1alpha1.ExternalScalerDeploymentTerminated,
-	))
-
-	// Delete interceptor admin and proxy services
-	interceptorAdminService := &corev1.Service{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      appInfo.InterceptorAdminServiceName(),
-			Namespace: appInfo.Namespace,
-		},
-	}
-	interceptorProxyService := &corev1.Service{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      appInfo.InterceptorProxyServiceName(),
-			Namespace: appInfo.Namespace,
-		},
-	}
-	if err := rec.Client.Delete(ctx, interceptorAdminService); err != nil {
-		if apierrs.IsNotFound(err) {
-			logger.Info("Interceptor admin service not found, moving on")
-		} else {
-			logger.Error(err, "Deleting interceptor admin service")
-			httpso.AddCondition(*v1alpha1.CreateCondition(
-				v1alpha1.Error,
-				v1.ConditionFalse,
-				v1alpha1.InterceptorAdminServiceTerminationError,
-			).SetMessage(err.Error()))
-			return err
-		}
-	}
-
-	httpso.AddCondition(*v1alpha1.CreateCondition(
-		v1alpha1.Terminated,
-		v1.ConditionTrue,
-		v1alpha1.InterceptorAdminServiceTerminated,
-	))
-
-	if err := rec.Client.Delete(ctx, interceptorProxyService); err != nil {
-		if apierrs.IsNotFound(err) {
-			logger.Info("Interceptor proxy service not found, moving on")
-		} else {
-			logger.Error(err, "Deleting interceptor proxy service")
-			httpso.AddCondition(*v1alpha1.CreateCondition(
-				v1alpha1.Error,
-				v1.ConditionFalse,
-				v1alpha1.InterceptorProxyServiceTerminationError,
-			).SetMessage(err.Error()))
-			return err
-		}
-	}
-	httpso.AddCondition(*v1alpha1.CreateCondition(
-		v1alpha1.Terminated,
-		v1.ConditionTrue,
-		v1alpha1.InterceptorProxyServiceTerminated,
-	))
-
-	// Delete external scaler service
-	externalScalerService := &corev1.Service{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      appInfo.ExternalScalerServiceName(),
-			Namespace: appInfo.Namespace,
-		},
-	}
-	if err := rec.Client.Delete(ctx, externalScalerService); err != nil {
-		if apierrs.IsNotFound(err) {
-			logger.Info("External scaler service not found, moving on")
-		} else {
-			logger.Error(err, "Deleting external scaler service")
-			httpso.AddCondition(*v1alpha1.CreateCondition(
-				v1alpha1.Error,
-				v1.ConditionFalse,
-				v1alpha1.ExternalScalerServiceTerminationError,
-			).SetMessage(err.Error()))
-			return err
-		}
-	}
-	httpso.AddCondition(*v1alpha1.CreateCondition(
-		v1alpha1.Terminated,
-		v1.ConditionTrue,
-		v1alpha1.ExternalScalerServiceTerminated,
-	))
-
-	// Delete App ScaledObject
-	scaledObject := &unstructured.Unstructured{}
-	scaledObject.SetNamespace(appInfo.Namespace)
-	scaledObject.SetName(config.AppScaledObjectName(httpso))
-	scaledObject.SetGroupVersionKind(schema.GroupVersionKind{
-		Group:   "keda.sh",
-		Kind:    "ScaledObject",
-		Version: "v1alpha1",
-	})
-	if err := rec.Client.Delete(ctx, scaledObject); err != nil {
-		if apierrs.IsNotFound(err) {
-			logger.Info("App ScaledObject not found, moving on")
-		} else {
-			logger.Error(err, "Deleting scaledobject")
-			httpso.AddCondition(*v1alpha1.CreateCondition(
-				v1alpha1.Error,
-				v1.ConditionFalse,
-				v1alpha1.AppScaledObjectTerminationError,
-			).SetMessage(err.Error()))
-			return err
-		}
-	}
-	httpso.AddCondition(*v1alpha1.CreateCondition(
-		v1alpha1.Terminated,
-		v1.ConditionTrue,
-		v1alpha1.AppScaledObjectTerminated,
-	))
-
-	// delete interceptor ScaledObject
-	scaledObject.SetName(config.InterceptorScaledObjectName(httpso))
-	if err := rec.Client.Delete(ctx, scaledObject); err != nil {
-		if apierrs.IsNotFound(err) {
-			logger.Info("Interceptor ScaledObject not found, moving on")
-		} else {
-			logger.Error(err, "Deleting interceptor scaledobject")
-			httpso.AddCondition(*v1alpha1.CreateCondition(
-				v1alpha1.Error,
-				v1.ConditionFalse,
-				v1alpha1.InterceptorScaledObjectTerminationError,
-			).SetMessage(err.Error()))
-			return err
-		}
-	}
-	httpso.AddCondition(*v1alpha1.CreateCondition(
-		v1alpha1.Terminated,
-		v1.ConditionTrue,
-		v1alpha1.InterceptorScaledObjectTerminated,
-	))
-
-	return nil
-}
-
 func (rec *HTTPScaledObjectReconciler) createOrUpdateApplicationResources(
 	ctx context.Context,
 	logger logr.Logger,
 	appInfo config.AppInfo,
-	httpso *v1alpha1.HTTPScaledObject,
+	httpso *v1alpha2.HTTPScaledObject,
 ) error {
 	defer httpso.SaveStatus(context.Background(), logger, rec.Client)
 	logger = rec.Log.WithValues(
@@ -238,16 +26,24 @@ func (rec *HTTPScaledObjectReconciler) createOrUpdateApplicationResources(
 		appInfo.Namespace,
 	)
 
-	// set initial statuses
-	httpso.AddCondition(*v1alpha1.CreateCondition(
-		v1alpha1.Pending,
-		v1.ConditionUnknown,
-		v1alpha1.PendingCreation,
-	).SetMessage("Identified HTTPScaledObject creation signal"))
+	// set the initial status if this is the first time we've seen httpso.
+	// otherwise, leave the Ready condition alone until we know whether
+	// everything is still up to date
+	if httpso.GetCondition(v1alpha2.Ready) == nil {
+		httpso.SetCondition(
+			v1alpha2.Ready,
+			v1.ConditionUnknown,
+			v1alpha2.PendingCreation,
+			"Identified HTTPScaledObject creation signal",
+		)
+	}
+
+	// record an event on httpso for everything we change for it
+	cl := rec.eventClient(httpso)
 
 	// CREATING INTERNAL ADD-ON OBJECTS
 	// Creating the dedicated interceptor
-	if err := createInterceptor(ctx, appInfo, rec.Client, logger, httpso); err != nil {
+	if err := createInterceptor(ctx, appInfo, cl, logger, httpso); err != nil {
 		return err
 	}
 
@@ -255,7 +51,7 @@ func (rec *HTTPScaledObjectReconciler) createOrUpdateApplicationResources(
 	externalScalerHostName, createScalerErr := createExternalScaler(
 		ctx,
 		appInfo,
-		rec.Client,
+		cl,
 		logger,
 		httpso,
 	)
@@ -265,7 +61,7 @@ func (rec *HTTPScaledObjectReconciler) createOrUpdateApplicationResources(
 
 	if err := waitForScaler(
 		ctx,
-		rec.Client,
+		cl,
 		appInfo.Namespace,
 		appInfo.ExternalScalerDeploymentName(),
 		5,
@@ -281,7 +77,7 @@ func (rec *HTTPScaledObjectReconciler) createOrUpdateApplicationResources(
 	if err := createScaledObjects(
 		ctx,
 		appInfo,
-		rec.Client,
+		cl,
 		logger,
 		externalScalerHostName,
 		httpso,
@@ -290,5 +86,11 @@ func (rec *HTTPScaledObjectReconciler) createOrUpdateApplicationResources(
 
 	}
 
+	// route traffic from outside the cluster to the interceptor, if
+	// httpso asks for it
+	if err := createIngress(ctx, appInfo, cl, logger, httpso); err != nil {
+		return err
+	}
+
 	return nil
 }
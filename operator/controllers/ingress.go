@@ -0,0 +1,154 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"github.com/kedacore/http-add-on/operator/api/v1alpha2"
+	"github.com/kedacore/http-add-on/operator/controllers/config"
+	"github.com/kedacore/http-add-on/pkg/k8s"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// httpRouteGVK is the GroupVersionKind of Gateway API HTTPRoutes
+var httpRouteGVK = schema.GroupVersionKind{
+	Group:   "gateway.networking.k8s.io",
+	Version: "v1",
+	Kind:    "HTTPRoute",
+}
+
+// createIngress creates the Ingress or HTTPRoute that httpso's ingress
+// section asks for, pointing at the interceptor's proxy service, or
+// updates it to match httpso if it already exists. The other kind is
+// deleted if httpso controls one, and so is the one it asks for if it
+// no longer has an ingress section.
+//
+// It sets httpso's status URL to the URL that the app can be reached at
+// through the Ingress or HTTPRoute
+func createIngress(
+	ctx context.Context,
+	appInfo config.AppInfo,
+	cl client.Client,
+	logger logr.Logger,
+	httpso *v1alpha2.HTTPScaledObject,
+) error {
+	spec := httpso.Spec.Ingress
+	var kind v1alpha2.IngressKind
+	if spec != nil {
+		kind = spec.IngressKind()
+	}
+
+	objMeta := metav1.ObjectMeta{
+		Namespace: httpso.Namespace,
+		Name:      config.IngressName(httpso),
+	}
+	if kind != v1alpha2.IngressKindIngress {
+		if err := deleteIfControlled(ctx, cl, logger, httpso, &networkingv1.Ingress{ObjectMeta: objMeta}); err != nil {
+			httpso.SetCondition(v1alpha2.IngressReady, metav1.ConditionFalse, v1alpha2.ErrorCreatingIngress, err.Error())
+			return err
+		}
+	}
+	if kind != v1alpha2.IngressKindHTTPRoute {
+		route := &unstructured.Unstructured{}
+		route.SetGroupVersionKind(httpRouteGVK)
+		route.SetNamespace(objMeta.Namespace)
+		route.SetName(objMeta.Name)
+		if err := deleteIfControlled(ctx, cl, logger, httpso, route); err != nil {
+			httpso.SetCondition(v1alpha2.IngressReady, metav1.ConditionFalse, v1alpha2.ErrorCreatingIngress, err.Error())
+			return err
+		}
+	}
+	if spec == nil {
+		httpso.Status.URL = ""
+		httpso.RemoveCondition(v1alpha2.IngressReady)
+		return nil
+	}
+
+	var pathPrefixes []string
+	for _, path := range httpso.Spec.Paths {
+		pathPrefixes = append(pathPrefixes, path.Prefix)
+	}
+	labels := k8s.Labels(objMeta.Name)
+	var host string
+	if len(httpso.Spec.Hosts) > 0 {
+		host = httpso.Spec.Hosts[0]
+	}
+
+	switch kind {
+	case v1alpha2.IngressKindHTTPRoute:
+		gateway := spec.Gateway
+		if gateway == nil {
+			err := fmt.Errorf("HTTPScaledObject %s has an HTTPRoute with no gateway", httpso.Name)
+			httpso.SetCondition(v1alpha2.IngressReady, metav1.ConditionFalse, v1alpha2.ErrorCreatingIngress, err.Error())
+			return err
+		}
+		route, err := k8s.NewHTTPRoute(
+			objMeta.Namespace,
+			objMeta.Name,
+			gateway.Name,
+			gateway.Namespace,
+			gateway.SectionName,
+			httpso.Spec.Hosts,
+			pathPrefixes,
+			appInfo.InterceptorProxyServiceName(),
+			interceptorProxyServicePort,
+			labels,
+			spec.Annotations,
+		)
+		if err == nil {
+			_, err = applyUnstructured(ctx, cl, logger, httpso, route)
+		}
+		if err != nil {
+			httpso.SetCondition(v1alpha2.IngressReady, metav1.ConditionFalse, v1alpha2.ErrorCreatingIngress, err.Error())
+			return err
+		}
+	default:
+		ingress, _, err := applyIngress(ctx, cl, logger, httpso, k8s.NewIngress(
+			objMeta.Namespace,
+			objMeta.Name,
+			spec.ClassName,
+			httpso.Spec.Hosts,
+			pathPrefixes,
+			spec.TLSSecretName,
+			appInfo.InterceptorProxyServiceName(),
+			interceptorProxyServicePort,
+			labels,
+			spec.Annotations,
+		))
+		if err != nil {
+			httpso.SetCondition(v1alpha2.IngressReady, metav1.ConditionFalse, v1alpha2.ErrorCreatingIngress, err.Error())
+			return err
+		}
+		// without hosts, the app is reached at the address that the
+		// ingress controller gave the Ingress, once it has one
+		if host == "" {
+			for _, lb := range ingress.Status.LoadBalancer.Ingress {
+				if host = lb.Hostname; host == "" {
+					host = lb.IP
+				}
+				if host != "" {
+					break
+				}
+			}
+		}
+	}
+
+	httpso.Status.URL = ""
+	if host != "" {
+		scheme := "http"
+		if spec.TLSSecretName != "" {
+			scheme = "https"
+		}
+		httpso.Status.URL = fmt.Sprintf("%s://%s", scheme, host)
+		if len(pathPrefixes) > 0 {
+			httpso.Status.URL += pathPrefixes[0]
+		}
+	}
+	httpso.SetCondition(v1alpha2.IngressReady, metav1.ConditionTrue, v1alpha2.IngressCreated, fmt.Sprintf("Created %s", kind))
+	return nil
+}
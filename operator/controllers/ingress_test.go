@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"github.com/kedacore/http-add-on/operator/api/v1alpha2"
+	"github.com/kedacore/http-add-on/operator/controllers/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Ingress", func() {
+	Context("Creating an Ingress or HTTPRoute", func() {
+		var testInfra *commonTestInfra
+		BeforeEach(func() {
+			testInfra = newCommonTestInfra("testns", "testapp")
+			testInfra.httpso.Spec.Hosts = []string{"testapp.com", "www.testapp.com"}
+			testInfra.httpso.Spec.Paths = []v1alpha2.PathRule{{Prefix: "/api"}}
+			testInfra.httpso.Spec.Ingress = &v1alpha2.IngressSpec{
+				ClassName:     "nginx",
+				TLSSecretName: "testtls",
+			}
+		})
+
+		key := func() client.ObjectKey {
+			return client.ObjectKey{
+				Namespace: testInfra.ns,
+				Name:      config.IngressName(&testInfra.httpso),
+			}
+		}
+		getIngress := func() (*networkingv1.Ingress, error) {
+			ingress := &networkingv1.Ingress{}
+			err := testInfra.cl.Get(testInfra.ctx, key(), ingress)
+			return ingress, err
+		}
+		getRoute := func() (*unstructured.Unstructured, error) {
+			route := &unstructured.Unstructured{}
+			route.SetGroupVersionKind(httpRouteGVK)
+			err := testInfra.cl.Get(testInfra.ctx, key(), route)
+			return route, err
+		}
+		create := func() error {
+			return createIngress(
+				testInfra.ctx,
+				testInfra.cfg,
+				testInfra.cl,
+				testInfra.logger,
+				&testInfra.httpso,
+			)
+		}
+
+		It("Should create an Ingress for the proxy service", func() {
+			Expect(create()).To(BeNil())
+
+			ingress, err := getIngress()
+			Expect(err).To(BeNil())
+			Expect(*ingress.Spec.IngressClassName).To(Equal("nginx"))
+			Expect(ingress.Spec.TLS).To(HaveLen(1))
+			Expect(ingress.Spec.TLS[0].SecretName).To(Equal("testtls"))
+			Expect(ingress.Spec.Rules).To(HaveLen(2))
+			path := ingress.Spec.Rules[0].HTTP.Paths[0]
+			Expect(path.Path).To(Equal("/api"))
+			Expect(path.Backend.Service.Name).To(Equal(testInfra.cfg.InterceptorProxyServiceName()))
+			Expect(path.Backend.Service.Port.Number).To(Equal(interceptorProxyServicePort))
+			Expect(metav1.GetControllerOf(ingress)).To(Not(BeNil()))
+
+			Expect(testInfra.httpso.Status.URL).To(Equal("https://testapp.com/api"))
+			cond := testInfra.httpso.GetCondition(v1alpha2.IngressReady)
+			Expect(cond).To(Not(BeNil()))
+			Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+			Expect(cond.Reason).To(Equal(string(v1alpha2.IngressCreated)))
+		})
+
+		It("Should replace the Ingress with an HTTPRoute", func() {
+			Expect(create()).To(BeNil())
+			testInfra.httpso.Spec.Ingress = &v1alpha2.IngressSpec{
+				Kind:    v1alpha2.IngressKindHTTPRoute,
+				Gateway: &v1alpha2.GatewayRef{Name: "testgw", Namespace: "gwns"},
+			}
+			Expect(create()).To(BeNil())
+
+			_, err := getIngress()
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+			route, err := getRoute()
+			Expect(err).To(BeNil())
+			hostnames, _, err := unstructured.NestedStringSlice(route.Object, "spec", "hostnames")
+			Expect(err).To(BeNil())
+			Expect(hostnames).To(Equal(testInfra.httpso.Spec.Hosts))
+			parents, _, err := unstructured.NestedSlice(route.Object, "spec", "parentRefs")
+			Expect(err).To(BeNil())
+			Expect(parents).To(HaveLen(1))
+			Expect(parents[0]).To(HaveKeyWithValue("name", "testgw"))
+			Expect(parents[0]).To(HaveKeyWithValue("namespace", "gwns"))
+			Expect(testInfra.httpso.Status.URL).To(Equal("http://testapp.com/api"))
+		})
+
+		It("Should delete the Ingress when the ingress section is removed", func() {
+			Expect(create()).To(BeNil())
+			testInfra.httpso.Spec.Ingress = nil
+			Expect(create()).To(BeNil())
+
+			_, err := getIngress()
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+			Expect(testInfra.httpso.Status.URL).To(BeEmpty())
+			Expect(testInfra.httpso.GetCondition(v1alpha2.IngressReady)).To(BeNil())
+		})
+	})
+})
@@ -2,40 +2,74 @@ package controllers
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 
 	"github.com/go-logr/logr"
-	"github.com/kedacore/http-add-on/operator/api/v1alpha1"
+	"github.com/kedacore/http-add-on/operator/api/v1alpha2"
 	"github.com/kedacore/http-add-on/operator/controllers/config"
 	"github.com/kedacore/http-add-on/pkg/k8s"
+	"github.com/kedacore/http-add-on/pkg/routing"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// interceptorProxyServicePort is the port that the interceptor's proxy
+// service serves on
+const interceptorProxyServicePort int32 = 80
+
+// createInterceptor creates the interceptor deployment, its admin and
+// proxy services and its routing table ConfigMap for httpso, or updates
+// them to match httpso and the operator's interceptor config if they
+// already exist.
+//
+// If interceptors are shared, it does the same for the namespace's shared
+// interceptor fleet instead, and adds httpso's routes to the fleet's
+// routing table
 func createInterceptor(
 	ctx context.Context,
 	appInfo config.AppInfo,
 	cl client.Client,
 	logger logr.Logger,
-	httpso *v1alpha1.HTTPScaledObject,
+	httpso *v1alpha2.HTTPScaledObject,
 ) error {
+	owner := interceptorOwner(appInfo, httpso)
+	if appInfo.InterceptorConfig.Shared {
+		// the fleet serves every HTTPScaledObject in the namespace, so
+		// its routing table is built from all of them
+		conflicts, err := updateSharedRoutingTable(ctx, cl, logger, appInfo, httpso)
+		if err != nil {
+			httpso.SetCondition(v1alpha2.InterceptorReady, metav1.ConditionFalse, v1alpha2.ErrorUpdatingRoutingTable, err.Error())
+			return err
+		}
+		if err := conflicts[httpso.Name]; err != nil {
+			httpso.SetCondition(v1alpha2.InterceptorReady, metav1.ConditionFalse, v1alpha2.ErrorUpdatingRoutingTable, err.Error())
+			return err
+		}
+		if err := deleteDedicatedInterceptor(ctx, cl, logger, httpso); err != nil {
+			httpso.SetCondition(v1alpha2.InterceptorReady, metav1.ConditionFalse, v1alpha2.ErrorCreatingInterceptor, err.Error())
+			return err
+		}
+	} else {
+		// the interceptor only serves httpso, so everything it needs to
+		// know about where to route is in its routing table. there's no
+		// default target, so requests for hosts that httpso doesn't list
+		// aren't routed anywhere
+		if err := applyRoutingTable(ctx, cl, logger, appInfo, httpso, routes(httpso)); err != nil {
+			httpso.SetCondition(v1alpha2.InterceptorReady, metav1.ConditionFalse, v1alpha2.ErrorUpdatingRoutingTable, err.Error())
+			return err
+		}
+	}
 	interceptorEnvs := []corev1.EnvVar{
-		// timeouts all have reasonable defaults in the interceptor config
-
-		// config regarding the origin
-		{
-			Name:  "KEDA_HTTP_APP_SERVICE_NAME",
-			Value: appInfo.Name,
-		},
-		{
-			Name:  "KEDA_HTTP_APP_SERVICE_PORT",
-			Value: fmt.Sprintf("%d", httpso.Spec.ScaleTargetRef.Port),
-		},
+		// timeouts all have reasonable defaults in the interceptor config.
+		// the interceptors read the routing table from a ConfigMap, so
+		// that they pick up changes to it, like pausing, without
+		// restarting
 		{
-			Name:  "KEDA_HTTP_TARGET_DEPLOYMENT_NAME",
-			Value: httpso.Spec.ScaleTargetRef.Deployment,
+			Name:  "KEDA_HTTP_ROUTING_TABLE_CONFIG_MAP",
+			Value: appInfo.RoutingTableConfigMapName(),
 		},
 		{
 			Name:  "KEDA_HTTP_NAMESPACE",
@@ -50,6 +84,20 @@ func createInterceptor(
 			Name:  "KEDA_HTTP_ADMIN_PORT",
 			Value: fmt.Sprintf("%d", appInfo.InterceptorConfig.AdminPort),
 		},
+		// config about how the interceptor should push its counts to the
+		// external scaler. the scaler identifies interceptors by pod IP
+		{
+			Name:  "KEDA_HTTP_SCALER_ADDRESS",
+			Value: appInfo.ExternalScalerHostName(),
+		},
+		{
+			Name: "KEDA_HTTP_INTERCEPTOR_POD_IP",
+			ValueFrom: &corev1.EnvVarSource{
+				FieldRef: &corev1.ObjectFieldSelector{
+					FieldPath: "status.podIP",
+				},
+			},
+		},
 	}
 
 	deployment := k8s.NewDeployment(
@@ -64,15 +112,16 @@ func createInterceptor(
 		k8s.Labels(appInfo.InterceptorDeploymentName()),
 		appInfo.InterceptorConfig.PullPolicy,
 	)
-	logger.Info("Creating interceptor Deployment", "Deployment", *deployment)
-	if err := cl.Create(ctx, deployment); err != nil {
-		if errors.IsAlreadyExists(err) {
-			logger.Info("Interceptor deployment already exists, moving on")
-		} else {
-			logger.Error(err, "Creating interceptor deployment")
-			httpso.AddCondition(*v1alpha1.CreateCondition(v1alpha1.Error, metav1.ConditionFalse, v1alpha1.ErrorCreatingInterceptor).SetMessage(err.Error()))
-			return err
-		}
+	// the interceptor isn't ready while its deployment cache is stale
+	k8s.AddHTTPReadinessProbe(
+		deployment,
+		"/healthz",
+		int(appInfo.InterceptorConfig.AdminPort),
+	)
+	// KEDA scales the interceptor, so leave its replica count alone
+	if _, err := applyDeployment(ctx, cl, logger, owner, deployment, false); err != nil {
+		httpso.SetCondition(v1alpha2.InterceptorReady, metav1.ConditionFalse, v1alpha2.ErrorCreatingInterceptor, err.Error())
+		return err
 	}
 
 	// create two services for the interceptor:
@@ -81,8 +130,7 @@ func createInterceptor(
 	publicPorts := []corev1.ServicePort{
 		k8s.NewTCPServicePort(
 			"proxy",
-			// TODO: make this the public port - probably 80
-			80,
+			interceptorProxyServicePort,
 			appInfo.InterceptorConfig.ProxyPort,
 		),
 	}
@@ -107,27 +155,129 @@ func createInterceptor(
 		corev1.ServiceTypeClusterIP,
 		k8s.Labels(appInfo.InterceptorDeploymentName()),
 	)
-	adminErr := cl.Create(ctx, adminService)
-	proxyErr := cl.Create(ctx, publicProxyService)
-	if adminErr != nil {
-		if errors.IsAlreadyExists(adminErr) {
-			logger.Info("interceptor admin service already exists, moving on")
-		} else {
-			logger.Error(adminErr, "Creating interceptor admin service")
-			httpso.AddCondition(*v1alpha1.CreateCondition(v1alpha1.Error, metav1.ConditionFalse, v1alpha1.ErrorCreatingInterceptorAdminService).SetMessage(adminErr.Error()))
-			return adminErr
-		}
+	if _, err := applyService(ctx, cl, logger, owner, adminService); err != nil {
+		httpso.SetCondition(v1alpha2.InterceptorReady, metav1.ConditionFalse, v1alpha2.ErrorCreatingInterceptorAdminService, err.Error())
+		return err
+	}
+	if _, err := applyService(ctx, cl, logger, owner, publicProxyService); err != nil {
+		httpso.SetCondition(v1alpha2.InterceptorReady, metav1.ConditionFalse, v1alpha2.ErrorCreatingInterceptorProxyService, err.Error())
+		return err
+	}
+
+	httpso.SetCondition(v1alpha2.InterceptorReady, metav1.ConditionTrue, v1alpha2.InterceptorCreated, "Created interceptor")
+	return nil
+}
+
+// interceptorOwner returns the owner to give the interceptor and external
+// scaler objects for httpso. If interceptors are shared, every
+// HTTPScaledObject in the namespace owns them, and none of them controls
+// them
+func interceptorOwner(appInfo config.AppInfo, httpso *v1alpha2.HTTPScaledObject) metav1.Object {
+	if appInfo.InterceptorConfig.Shared {
+		return sharedOwner{httpso}
+	}
+	return httpso
+}
+
+// updateSharedRoutingTable rebuilds the routing table ConfigMap for the
+// shared interceptor fleet in appInfo's namespace from all the
+// HTTPScaledObjects there, and returns the conflicts that sharedRoutes
+// found. httpso is used instead of the copy of it in the cluster, since
+// it may be newer.
+//
+// If httpso is nil, the ConfigMap is only updated if there are still
+// HTTPScaledObjects in the namespace. Otherwise, Kubernetes garbage
+// collects it along with the rest of the fleet
+func updateSharedRoutingTable(
+	ctx context.Context,
+	cl client.Client,
+	logger logr.Logger,
+	appInfo config.AppInfo,
+	httpso *v1alpha2.HTTPScaledObject,
+) (map[string]error, error) {
+	list := &v1alpha2.HTTPScaledObjectList{}
+	if err := cl.List(ctx, list, client.InNamespace(appInfo.Namespace)); err != nil {
+		return nil, err
 	}
-	if proxyErr != nil {
-		if errors.IsAlreadyExists(adminErr) {
-			logger.Info("interceptor proxy service already exists, moving on")
-		} else {
-			logger.Error(proxyErr, "Creating interceptor proxy service")
-			httpso.AddCondition(*v1alpha1.CreateCondition(v1alpha1.Error, metav1.ConditionFalse, v1alpha1.ErrorCreatingInterceptorProxyService).SetMessage(proxyErr.Error()))
-			return proxyErr
+	httpsos := list.Items[:0]
+	for _, other := range list.Items {
+		if httpso == nil || other.Name != httpso.Name {
+			httpsos = append(httpsos, other)
 		}
 	}
+	if httpso != nil {
+		httpsos = append(httpsos, *httpso)
+	}
+	if len(httpsos) == 0 {
+		return nil, nil
+	}
+
+	table, conflicts := sharedRoutes(httpsos)
+	owner := httpso
+	if owner == nil {
+		owner = &httpsos[0]
+	}
+	if err := applyRoutingTable(ctx, cl, logger, appInfo, sharedOwner{owner}, table); err != nil {
+		return nil, err
+	}
+	return conflicts, nil
+}
+
+// applyRoutingTable creates or updates the ConfigMap that holds the
+// routing table for appInfo's interceptors, owned by owner
+func applyRoutingTable(
+	ctx context.Context,
+	cl client.Client,
+	logger logr.Logger,
+	appInfo config.AppInfo,
+	owner metav1.Object,
+	table map[string][]routing.Target,
+) error {
+	tableJSON, err := json.Marshal(table)
+	if err != nil {
+		return err
+	}
+	cm := k8s.NewConfigMap(
+		appInfo.Namespace,
+		appInfo.RoutingTableConfigMapName(),
+		k8s.Labels(appInfo.InterceptorDeploymentName()),
+		map[string]string{
+			routing.ConfigMapRoutingTableKey: string(tableJSON),
+		},
+	)
+	_, err = applyConfigMap(ctx, cl, logger, owner, cm)
+	return err
+}
 
-	httpso.AddCondition(*v1alpha1.CreateCondition(v1alpha1.Created, metav1.ConditionTrue, v1alpha1.InterceptorCreated).SetMessage("Created interceptor"))
+// deleteDedicatedInterceptor deletes the dedicated interceptor and
+// external scaler that httpso had before interceptors were shared, if
+// they're still around. Objects that httpso doesn't control are left
+// alone
+func deleteDedicatedInterceptor(
+	ctx context.Context,
+	cl client.Client,
+	logger logr.Logger,
+	httpso *v1alpha2.HTTPScaledObject,
+) error {
+	dedicated := config.AppInfo{
+		Name:      config.DeploymentName(*httpso),
+		Namespace: httpso.Namespace,
+	}
+	meta := func(name string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Namespace: httpso.Namespace, Name: name}
+	}
+	objs := []client.Object{
+		&appsv1.Deployment{ObjectMeta: meta(dedicated.InterceptorDeploymentName())},
+		&corev1.Service{ObjectMeta: meta(dedicated.InterceptorAdminServiceName())},
+		&corev1.Service{ObjectMeta: meta(dedicated.InterceptorProxyServiceName())},
+		&corev1.ConfigMap{ObjectMeta: meta(dedicated.RoutingTableConfigMapName())},
+		&appsv1.Deployment{ObjectMeta: meta(dedicated.ExternalScalerDeploymentName())},
+		&corev1.Service{ObjectMeta: meta(dedicated.ExternalScalerServiceName())},
+	}
+	for _, obj := range objs {
+		if err := deleteIfControlled(ctx, cl, logger, httpso, obj); err != nil {
+			return err
+		}
+	}
 	return nil
 }
@@ -0,0 +1,224 @@
+package controllers
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/kedacore/http-add-on/operator/api/v1alpha2"
+	"github.com/kedacore/http-add-on/operator/controllers/config"
+	"github.com/kedacore/http-add-on/pkg/k8s"
+	"github.com/kedacore/http-add-on/pkg/routing"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Interceptor", func() {
+	Context("Creating a shared interceptor fleet", func() {
+		var testInfra *commonTestInfra
+		var sharedCfg config.AppInfo
+		BeforeEach(func() {
+			testInfra = newCommonTestInfra("testns", "testapp")
+			testInfra.httpso.Spec.Hosts = []string{"testapp.com"}
+			sharedCfg = testInfra.cfg
+			sharedCfg.Name = config.SharedName
+			sharedCfg.InterceptorConfig.Shared = true
+		})
+
+		getDepl := func(name string) (*appsv1.Deployment, error) {
+			depl := &appsv1.Deployment{}
+			err := testInfra.cl.Get(testInfra.ctx, client.ObjectKey{
+				Namespace: testInfra.ns,
+				Name:      name,
+			}, depl)
+			return depl, err
+		}
+		getTable := func() map[string][]routing.Target {
+			cm := &corev1.ConfigMap{}
+			Expect(testInfra.cl.Get(testInfra.ctx, client.ObjectKey{
+				Namespace: testInfra.ns,
+				Name:      sharedCfg.RoutingTableConfigMapName(),
+			}, cm)).To(BeNil())
+			table := map[string][]routing.Target{}
+			Expect(json.Unmarshal([]byte(cm.Data[routing.ConfigMapRoutingTableKey]), &table)).To(BeNil())
+			return table
+		}
+
+		It("Should route every HTTPScaledObject through one fleet", func() {
+			other := testInfra.httpso.DeepCopy()
+			other.Name = "otherapp"
+			other.Spec.Hosts = []string{"otherapp.com"}
+			other.Spec.Targets[0].Name = "otherapp"
+			Expect(testInfra.cl.Create(testInfra.ctx, other)).To(BeNil())
+			Expect(testInfra.cl.Create(testInfra.ctx, &testInfra.httpso)).To(BeNil())
+
+			Expect(createInterceptor(
+				testInfra.ctx,
+				sharedCfg,
+				testInfra.cl,
+				testInfra.logger,
+				&testInfra.httpso,
+			)).To(BeNil())
+			Expect(createInterceptor(
+				testInfra.ctx,
+				sharedCfg,
+				testInfra.cl,
+				testInfra.logger,
+				other,
+			)).To(BeNil())
+
+			table := getTable()
+			Expect(table).To(HaveLen(2))
+			Expect(table["testapp.com"][0].Service).To(Equal(testInfra.appName))
+			Expect(table["otherapp.com"][0].ScaleTarget().Name).To(Equal("otherapp"))
+
+			// the fleet reads the table from the ConfigMap, and both
+			// HTTPScaledObjects own it without either controlling it
+			depl, err := getDepl(sharedCfg.InterceptorDeploymentName())
+			Expect(err).To(BeNil())
+			env := depl.Spec.Template.Spec.Containers[0].Env
+			Expect(env).To(ContainElement(corev1.EnvVar{
+				Name:  "KEDA_HTTP_ROUTING_TABLE_CONFIG_MAP",
+				Value: sharedCfg.RoutingTableConfigMapName(),
+			}))
+			Expect(depl.OwnerReferences).To(HaveLen(2))
+			Expect(metav1.GetControllerOf(depl)).To(BeNil())
+
+			// deleting one takes its routes out of the table
+			Expect(testInfra.cl.Delete(testInfra.ctx, other)).To(BeNil())
+			_, err = updateSharedRoutingTable(
+				testInfra.ctx,
+				testInfra.cl,
+				testInfra.logger,
+				sharedCfg,
+				nil,
+			)
+			Expect(err).To(BeNil())
+			Expect(getTable()).To(HaveLen(1))
+			Expect(getTable()).To(HaveKey("testapp.com"))
+		})
+
+		It("Should reject a host that's already routed", func() {
+			// the older HTTPScaledObject keeps the host, even though the
+			// newer one comes first by name
+			testInfra.httpso.CreationTimestamp = metav1.NewTime(time.Now().Add(-time.Hour))
+			Expect(testInfra.cl.Create(testInfra.ctx, &testInfra.httpso)).To(BeNil())
+			other := testInfra.httpso.DeepCopy()
+			other.ResourceVersion = ""
+			other.CreationTimestamp = metav1.NewTime(time.Now())
+			other.Name = "otherapp"
+			other.Spec.Targets[0].Name = "otherapp"
+			Expect(testInfra.cl.Create(testInfra.ctx, other)).To(BeNil())
+
+			err := createInterceptor(
+				testInfra.ctx,
+				sharedCfg,
+				testInfra.cl,
+				testInfra.logger,
+				other,
+			)
+			Expect(err).To(Not(BeNil()))
+			cond := other.GetCondition(v1alpha2.InterceptorReady)
+			Expect(cond).To(Not(BeNil()))
+			Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+			Expect(cond.Reason).To(Equal(string(v1alpha2.ErrorUpdatingRoutingTable)))
+			Expect(getTable()["testapp.com"][0].Service).To(Equal(testInfra.appName))
+		})
+
+		It("Should delete the dedicated interceptor", func() {
+			Expect(createInterceptor(
+				testInfra.ctx,
+				testInfra.cfg,
+				testInfra.cl,
+				testInfra.logger,
+				&testInfra.httpso,
+			)).To(BeNil())
+			_, err := getDepl(testInfra.cfg.InterceptorDeploymentName())
+			Expect(err).To(BeNil())
+			// something the HTTPScaledObject doesn't control is kept
+			unowned := k8s.NewService(
+				testInfra.ns,
+				testInfra.cfg.ExternalScalerServiceName(),
+				nil,
+				corev1.ServiceTypeClusterIP,
+				nil,
+			)
+			Expect(testInfra.cl.Create(testInfra.ctx, unowned)).To(BeNil())
+
+			Expect(createInterceptor(
+				testInfra.ctx,
+				sharedCfg,
+				testInfra.cl,
+				testInfra.logger,
+				&testInfra.httpso,
+			)).To(BeNil())
+			_, err = getDepl(testInfra.cfg.InterceptorDeploymentName())
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+			err = testInfra.cl.Get(testInfra.ctx, client.ObjectKey{
+				Namespace: testInfra.ns,
+				Name:      testInfra.cfg.RoutingTableConfigMapName(),
+			}, &corev1.ConfigMap{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+			Expect(testInfra.cl.Get(
+				testInfra.ctx,
+				client.ObjectKeyFromObject(unowned),
+				&corev1.Service{},
+			)).To(BeNil())
+		})
+	})
+	Context("Creating a dedicated interceptor", func() {
+		var testInfra *commonTestInfra
+		BeforeEach(func() {
+			testInfra = newCommonTestInfra("testns", "testapp")
+		})
+
+		It("Should update the routing table without rolling the interceptor", func() {
+			create := func() (*appsv1.Deployment, map[string][]routing.Target) {
+				Expect(createInterceptor(
+					testInfra.ctx,
+					testInfra.cfg,
+					testInfra.cl,
+					testInfra.logger,
+					&testInfra.httpso,
+				)).To(BeNil())
+				depl := &appsv1.Deployment{}
+				Expect(testInfra.cl.Get(testInfra.ctx, client.ObjectKey{
+					Namespace: testInfra.ns,
+					Name:      testInfra.cfg.InterceptorDeploymentName(),
+				}, depl)).To(BeNil())
+				cm := &corev1.ConfigMap{}
+				Expect(testInfra.cl.Get(testInfra.ctx, client.ObjectKey{
+					Namespace: testInfra.ns,
+					Name:      testInfra.cfg.RoutingTableConfigMapName(),
+				}, cm)).To(BeNil())
+				Expect(metav1.IsControlledBy(cm, &testInfra.httpso)).To(BeTrue())
+				table := map[string][]routing.Target{}
+				Expect(json.Unmarshal([]byte(cm.Data[routing.ConfigMapRoutingTableKey]), &table)).To(BeNil())
+				return depl, table
+			}
+
+			depl, table := create()
+			Expect(depl.Spec.Template.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{
+				Name:  "KEDA_HTTP_ROUTING_TABLE_CONFIG_MAP",
+				Value: testInfra.cfg.RoutingTableConfigMapName(),
+			}))
+			Expect(table[routing.WildcardHost][0].Paused).To(BeFalse())
+			// the interceptor isn't ready while its deployment cache is stale
+			probe := depl.Spec.Template.Spec.Containers[0].ReadinessProbe
+			Expect(probe).To(Not(BeNil()))
+			Expect(probe.HTTPGet.Path).To(Equal("/healthz"))
+			Expect(probe.HTTPGet.Port.IntValue()).To(Equal(int(testInfra.cfg.InterceptorConfig.AdminPort)))
+
+			// pausing only changes the ConfigMap, so the interceptor's pods
+			// aren't replaced
+			testInfra.httpso.Annotations = map[string]string{v1alpha2.PausedAnnotation: "true"}
+			pausedDepl, table := create()
+			Expect(table[routing.WildcardHost][0].Paused).To(BeTrue())
+			Expect(pausedDepl.Spec.Template).To(Equal(depl.Spec.Template))
+		})
+	})
+})
@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// metricsNamespace is the prefix of the names of all the operator's own
+// metrics. controller-runtime's metrics are served next to them
+const metricsNamespace = "operator"
+
+// the results that reconciles are counted under
+const (
+	reconcileSuccess = "success"
+	reconcileError   = "error"
+	// reconcileInvalid is for HTTPScaledObjects that can't be reconciled
+	// until they're fixed, like ones with no targets
+	reconcileInvalid = "invalid"
+)
+
+var (
+	reconcilesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "reconciles_total",
+		Help:      "Reconciles of each HTTPScaledObject, by result",
+	}, []string{"namespace", "name", "result"})
+	resourceErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "resource_errors_total",
+		Help:      "Failures to create, update or delete the objects generated for HTTPScaledObjects, by kind and verb",
+	}, []string{"kind", "verb"})
+	finalizeDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "finalize_duration_seconds",
+		Help:      "How long finalizing deleted HTTPScaledObjects took",
+		Buckets:   prometheus.DefBuckets,
+	})
+	managedApps = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "managed_apps",
+		Help:      "HTTPScaledObjects that the operator manages",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		reconcilesTotal,
+		resourceErrorsTotal,
+		finalizeDuration,
+		managedApps,
+	)
+}
+
+// managed is the set of HTTPScaledObjects that managedApps counts
+var managed = struct {
+	sync.Mutex
+	apps map[types.NamespacedName]struct{}
+}{apps: map[types.NamespacedName]struct{}{}}
+
+// countReconcile counts a reconcile of the HTTPScaledObject called name
+// that had the given result
+func countReconcile(name types.NamespacedName, result string) {
+	reconcilesTotal.WithLabelValues(name.Namespace, name.Name, result).Inc()
+}
+
+// trackApp records whether the HTTPScaledObject called name is managed by
+// the operator. Once it isn't, its reconcile counts are forgotten, so
+// that deleted HTTPScaledObjects don't leave series behind
+func trackApp(name types.NamespacedName, isManaged bool) {
+	managed.Lock()
+	defer managed.Unlock()
+	if isManaged {
+		managed.apps[name] = struct{}{}
+	} else {
+		delete(managed.apps, name)
+		for _, result := range []string{reconcileSuccess, reconcileError, reconcileInvalid} {
+			reconcilesTotal.DeleteLabelValues(name.Namespace, name.Name, result)
+		}
+	}
+	managedApps.Set(float64(len(managed.apps)))
+}
@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"github.com/kedacore/http-add-on/pkg/k8s"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+)
+
+var _ = Describe("Metrics", func() {
+	Context("Tracking managed apps", func() {
+		It("Should count apps once and forget deleted ones", func() {
+			name := types.NamespacedName{Namespace: "metricsns", Name: "metricsapp"}
+			before := testutil.ToFloat64(managedApps)
+
+			trackApp(name, true)
+			trackApp(name, true)
+			countReconcile(name, reconcileSuccess)
+			countReconcile(name, reconcileSuccess)
+			countReconcile(name, reconcileError)
+			Expect(testutil.ToFloat64(managedApps)).To(Equal(before + 1))
+			Expect(testutil.ToFloat64(
+				reconcilesTotal.WithLabelValues(name.Namespace, name.Name, reconcileSuccess),
+			)).To(Equal(2.0))
+			Expect(testutil.ToFloat64(
+				reconcilesTotal.WithLabelValues(name.Namespace, name.Name, reconcileError),
+			)).To(Equal(1.0))
+
+			trackApp(name, false)
+			Expect(testutil.ToFloat64(managedApps)).To(Equal(before))
+			// the deleted app's series are gone, so they start over
+			Expect(testutil.ToFloat64(
+				reconcilesTotal.WithLabelValues(name.Namespace, name.Name, reconcileSuccess),
+			)).To(Equal(0.0))
+		})
+	})
+
+	Context("Counting resource errors", func() {
+		It("Should count failures by kind and verb", func() {
+			testInfra := newCommonTestInfra("testns", "testapp")
+			rec := &HTTPScaledObjectReconciler{
+				Client:   testInfra.cl,
+				Recorder: record.NewFakeRecorder(10),
+			}
+			cl := rec.eventClient(&testInfra.httpso)
+			failures := resourceErrorsTotal.WithLabelValues("ConfigMap", "Create")
+			before := testutil.ToFloat64(failures)
+
+			cm := k8s.NewConfigMap(testInfra.ns, "testcm", nil, nil)
+			Expect(cl.Create(testInfra.ctx, cm)).To(BeNil())
+			Expect(testutil.ToFloat64(failures)).To(Equal(before))
+
+			// it already exists
+			Expect(cl.Create(testInfra.ctx, k8s.NewConfigMap(testInfra.ns, "testcm", nil, nil))).To(Not(BeNil()))
+			Expect(testutil.ToFloat64(failures)).To(Equal(before + 1))
+		})
+	})
+})
@@ -0,0 +1,151 @@
+package controllers
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/kedacore/http-add-on/operator/api/v1alpha2"
+	"github.com/kedacore/http-add-on/pkg/routing"
+)
+
+// routes returns the routing table entries for httpso, keyed by host, in
+// the format that routing.Table's UnmarshalJSON accepts.
+//
+// Requests for each of httpso's hosts (or for any host, if it has none)
+// under each of its paths (or under any path, if it has none) are split
+// between its targets according to their weights. Targets with weight 0
+// aren't routed to at all. If httpso's scaling is paused, so are its
+// targets, so that the interceptor doesn't wait on replicas that won't
+// come
+func routes(httpso *v1alpha2.HTTPScaledObject) map[string][]routing.Target {
+	// invalid pause annotations are reported when the ScaledObjects are
+	// created, so they're treated as unpaused here
+	paused, _, _ := httpso.Paused()
+	hosts := httpso.Spec.Hosts
+	if len(hosts) == 0 {
+		hosts = []string{routing.WildcardHost}
+	}
+	paths := httpso.Spec.Paths
+	if len(paths) == 0 {
+		paths = []v1alpha2.PathRule{{}}
+	}
+	var targets []routing.Target
+	for _, path := range paths {
+		for _, target := range httpso.Spec.Targets {
+			weight := target.TargetWeight()
+			if weight <= 0 {
+				continue
+			}
+			targets = append(targets, routing.Target{
+				Service: target.Service,
+				Port:    int(target.Port),
+				ScaleTargetRef: &routing.ScaleTargetRef{
+					APIVersion: target.TargetAPIVersion(),
+					Kind:       target.TargetKind(),
+					Name:       target.Name,
+				},
+				PathPrefix:  path.Prefix,
+				StripPrefix: path.StripPrefix,
+				Weight:      int(weight),
+				Paused:      paused,
+			})
+		}
+	}
+	ret := make(map[string][]routing.Target, len(hosts))
+	for _, host := range hosts {
+		ret[host] = targets
+	}
+	return ret
+}
+
+// routeKeys returns the queue keys that interceptors count httpso's
+// requests under: each of its hosts followed by each of its path
+// prefixes. Requests for an HTTPScaledObject with no hosts are counted
+// under whatever host they were sent to, so its keys have the "*" host,
+// which the external scaler matches to any host. The keys are sorted, so
+// that they don't change from one reconcile to the next
+func routeKeys(httpso *v1alpha2.HTTPScaledObject) []string {
+	hosts := httpso.Spec.Hosts
+	if len(hosts) == 0 {
+		hosts = []string{routing.WildcardHost}
+	}
+	paths := httpso.Spec.Paths
+	if len(paths) == 0 {
+		paths = []v1alpha2.PathRule{{}}
+	}
+	seen := map[string]bool{}
+	var ret []string
+	for _, host := range hosts {
+		for _, path := range paths {
+			target := routing.Target{PathPrefix: path.Prefix}
+			key := target.QueueKey(host)
+			if !seen[key] {
+				seen[key] = true
+				ret = append(ret, key)
+			}
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// sharedRoutes returns the routing table for a shared interceptor fleet
+// that serves all of httpsos, in the same format as routes.
+//
+// Every HTTPScaledObject must list its hosts, since the fleet routes
+// by host, and each host can only be routed to one HTTPScaledObject.
+// Hosts are compared the way the routing table compares them, so ones
+// that differ only in case or port are the same host.
+// HTTPScaledObjects are considered oldest first, and if one has no hosts
+// or lists a host that an older one already has, none of its routes are
+// added. The reason is returned in the second return value, keyed by the
+// HTTPScaledObject's name. HTTPScaledObjects that are being deleted are
+// left out
+func sharedRoutes(
+	httpsos []v1alpha2.HTTPScaledObject,
+) (map[string][]routing.Target, map[string]error) {
+	sorted := make([]*v1alpha2.HTTPScaledObject, 0, len(httpsos))
+	for i := range httpsos {
+		if httpsos[i].GetDeletionTimestamp() == nil {
+			sorted = append(sorted, &httpsos[i])
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		ti, tj := sorted[i].CreationTimestamp, sorted[j].CreationTimestamp
+		if !ti.Equal(&tj) {
+			return ti.Before(&tj)
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	ret := map[string][]routing.Target{}
+	owners := map[string]string{}
+	conflicts := map[string]error{}
+	for _, httpso := range sorted {
+		if len(httpso.Spec.Hosts) == 0 {
+			conflicts[httpso.Name] = fmt.Errorf(
+				"HTTPScaledObject %s has no hosts, which it needs when interceptors are shared",
+				httpso.Name,
+			)
+			continue
+		}
+		for _, host := range httpso.Spec.Hosts {
+			if owner, ok := owners[routing.NormalizeHost(host)]; ok {
+				conflicts[httpso.Name] = fmt.Errorf(
+					"host %s is already routed to HTTPScaledObject %s",
+					host,
+					owner,
+				)
+				break
+			}
+		}
+		if conflicts[httpso.Name] != nil {
+			continue
+		}
+		for host, targets := range routes(httpso) {
+			ret[host] = targets
+			owners[routing.NormalizeHost(host)] = httpso.Name
+		}
+	}
+	return ret, conflicts
+}
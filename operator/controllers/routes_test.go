@@ -0,0 +1,116 @@
+package controllers
+
+import (
+	"time"
+
+	"github.com/kedacore/http-add-on/operator/api/v1alpha2"
+	"github.com/kedacore/http-add-on/pkg/routing"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Routes", func() {
+	var testInfra *commonTestInfra
+	BeforeEach(func() {
+		testInfra = newCommonTestInfra("testns", "testapp")
+	})
+	It("Should route every host to the single target", func() {
+		ret := routes(&testInfra.httpso)
+		Expect(ret).To(HaveLen(1))
+		Expect(ret).To(HaveKey(routing.WildcardHost))
+		targets := ret[routing.WildcardHost]
+		Expect(targets).To(HaveLen(1))
+		Expect(targets[0].Service).To(Equal(testInfra.appName))
+		Expect(targets[0].Port).To(Equal(8081))
+		Expect(targets[0].PathPrefix).To(Equal(""))
+		Expect(targets[0].Weight).To(Equal(1))
+		Expect(targets[0].Paused).To(BeFalse())
+		Expect(targets[0].ScaleTarget()).To(Equal(routing.NewDeploymentScaleTargetRef(testInfra.appName)))
+	})
+	It("Should pause the targets while scaling is paused", func() {
+		testInfra.httpso.Annotations = map[string]string{v1alpha2.PausedAnnotation: "true"}
+		targets := routes(&testInfra.httpso)[routing.WildcardHost]
+		Expect(targets).To(HaveLen(1))
+		Expect(targets[0].Paused).To(BeTrue())
+	})
+	It("Should route each host and path to the weighted targets", func() {
+		weight := int32(0)
+		httpso := &testInfra.httpso
+		httpso.Spec.Hosts = []string{"a.com", "b.com"}
+		httpso.Spec.Paths = []v1alpha2.PathRule{
+			{Prefix: "/api", StripPrefix: true},
+			{Prefix: "/static"},
+		}
+		httpso.Spec.Targets = append(
+			httpso.Spec.Targets,
+			v1alpha2.TargetRef{
+				Kind:    "StatefulSet",
+				Name:    "canary",
+				Service: "canarysvc",
+				Port:    8080,
+			},
+			v1alpha2.TargetRef{
+				Name:    "off",
+				Service: "offsvc",
+				Port:    8080,
+				Weight:  &weight,
+			},
+		)
+		ret := routes(httpso)
+		Expect(ret).To(HaveLen(2))
+		for _, host := range httpso.Spec.Hosts {
+			targets := ret[host]
+			// the target with weight 0 shouldn't be routed to at all
+			Expect(targets).To(HaveLen(4))
+			Expect(targets[0].PathPrefix).To(Equal("/api"))
+			Expect(targets[0].StripPrefix).To(BeTrue())
+			Expect(targets[1].PathPrefix).To(Equal("/api"))
+			Expect(targets[1].Service).To(Equal("canarysvc"))
+			Expect(targets[1].ScaleTarget().Kind).To(Equal("StatefulSet"))
+			Expect(targets[2].PathPrefix).To(Equal("/static"))
+			Expect(targets[2].StripPrefix).To(BeFalse())
+		}
+	})
+	It("Should key each host and path separately for scaling", func() {
+		Expect(routeKeys(&testInfra.httpso)).To(Equal([]string{"*"}))
+
+		testInfra.httpso.Spec.Paths = []v1alpha2.PathRule{{Prefix: "/static"}, {Prefix: "/api"}}
+		Expect(routeKeys(&testInfra.httpso)).To(Equal([]string{"*/api", "*/static"}))
+
+		// the interceptor counts hosts in lowercase, without their ports
+		testInfra.httpso.Spec.Hosts = []string{"B.com", "a.com:8080"}
+		Expect(routeKeys(&testInfra.httpso)).To(Equal([]string{
+			"a.com/api",
+			"a.com/static",
+			"b.com/api",
+			"b.com/static",
+		}))
+	})
+	It("Should route each host to the oldest HTTPScaledObject that lists it", func() {
+		older := testInfra.httpso.DeepCopy()
+		older.Name = "older"
+		older.CreationTimestamp = metav1.NewTime(time.Now().Add(-time.Minute))
+		older.Spec.Hosts = []string{"a.com"}
+		newer := testInfra.httpso.DeepCopy()
+		newer.Name = "newer"
+		newer.CreationTimestamp = metav1.NewTime(time.Now())
+		newer.Spec.Hosts = []string{"b.com"}
+
+		table, conflicts := sharedRoutes([]v1alpha2.HTTPScaledObject{*newer, *older})
+		Expect(conflicts).To(BeEmpty())
+		Expect(table).To(HaveLen(2))
+		Expect(table).To(HaveKey("a.com"))
+		Expect(table).To(HaveKey("b.com"))
+
+		// a host that only differs in case or port is the same host to
+		// the routing table, so it conflicts too
+		newer.Spec.Hosts = []string{"b.com", "A.com:8080"}
+		table, conflicts = sharedRoutes([]v1alpha2.HTTPScaledObject{*newer, *older})
+		Expect(conflicts).To(HaveLen(1))
+		Expect(conflicts).To(HaveKey("newer"))
+		Expect(conflicts["newer"].Error()).To(ContainSubstring("already routed to HTTPScaledObject older"))
+		Expect(table).To(HaveLen(1))
+		Expect(table).To(HaveKey("a.com"))
+	})
+})
@@ -2,94 +2,371 @@ package controllers
 
 import (
 	"context"
+	"math"
+	"strconv"
 
 	"github.com/go-logr/logr"
-	"github.com/kedacore/http-add-on/operator/api/v1alpha1"
+	"github.com/kedacore/http-add-on/operator/api/v1alpha2"
 	"github.com/kedacore/http-add-on/operator/controllers/config"
 	"github.com/kedacore/http-add-on/pkg/k8s"
 	"k8s.io/apimachinery/pkg/api/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-// create ScaledObjects for the app and interceptor
+// defaultTargetPendingRequests is the number of pending requests per
+// replica that apps are scaled to handle if the HTTPScaledObject doesn't
+// specify one
+const defaultTargetPendingRequests int32 = 100
+
+// targetPendingRequests returns the number of pending requests per
+// replica that the app for httpso should be scaled to handle
+func targetPendingRequests(httpso *v1alpha2.HTTPScaledObject) int32 {
+	if httpso.Spec.ScalingPolicy.TargetPendingRequests > 0 {
+		return httpso.Spec.ScalingPolicy.TargetPendingRequests
+	}
+	return defaultTargetPendingRequests
+}
+
+// targetPendingRequestsForTarget returns the number of pending requests
+// per replica that target should be scaled to handle. Every target sees
+// all of the app's pending requests, since the interceptor counts them by
+// host and path, not by target, but each target only serves its weight's
+// share of them. So the app's target is scaled up by the total weight of
+// httpso's targets over target's weight, and a weight-1 canary next to a
+// weight-3 stable target gets a quarter of the replicas that it would get
+// on its own.
+//
+// A target with a weight of 0 gets no requests, so it's given the
+// largest target there is, which holds it at its minimum replicas (or
+// one replica while the app is active, if that's 0)
+func targetPendingRequestsForTarget(
+	httpso *v1alpha2.HTTPScaledObject,
+	target v1alpha2.TargetRef,
+) int32 {
+	weight := int64(target.TargetWeight())
+	if weight <= 0 {
+		return math.MaxInt32
+	}
+	var totalWeight int64
+	for _, t := range httpso.Spec.Targets {
+		if w := t.TargetWeight(); w > 0 {
+			totalWeight += int64(w)
+		}
+	}
+	// round up, so that no target is scaled for more requests than it
+	// gets
+	scaled := (int64(targetPendingRequests(httpso))*totalWeight + weight - 1) / weight
+	if scaled > math.MaxInt32 {
+		return math.MaxInt32
+	}
+	return int32(scaled)
+}
+
+// cronTriggers returns the KEDA cron triggers that keep a ScaledObject's
+// target warm during windows
+func cronTriggers(windows []v1alpha2.PrewarmWindow) []k8s.CronTrigger {
+	ret := make([]k8s.CronTrigger, 0, len(windows))
+	for _, window := range windows {
+		ret = append(ret, k8s.CronTrigger{
+			Timezone:        window.Timezone,
+			Start:           window.Start,
+			End:             window.End,
+			DesiredReplicas: window.MinReplicas,
+		})
+	}
+	return ret
+}
+
+// interceptorScalingPolicy returns the scaling policy for the interceptor
+// that serves httpso. It's httpso's interceptor scaling policy if it has
+// one and interceptors are dedicated, with the replica counts and target
+// it leaves out filled in from the operator's config, and just the
+// operator's config otherwise. The minimum is always at least 1, so that
+// there's an interceptor to receive the request that wakes the app up
+// from zero
+func interceptorScalingPolicy(
+	appInfo config.AppInfo,
+	httpso *v1alpha2.HTTPScaledObject,
+) v1alpha2.ScalingPolicy {
+	var ret v1alpha2.ScalingPolicy
+	cfg := appInfo.InterceptorConfig
+	if policy := httpso.Spec.InterceptorScalingPolicy; policy != nil && !cfg.Shared {
+		policy.DeepCopyInto(&ret)
+	}
+	if ret.Replicas.Min <= 0 {
+		ret.Replicas.Min = cfg.MinReplicas
+	}
+	if ret.Replicas.Max <= 0 {
+		ret.Replicas.Max = cfg.MaxReplicas
+	}
+	if ret.TargetPendingRequests <= 0 {
+		ret.TargetPendingRequests = cfg.TargetPendingRequests
+	}
+	if ret.Replicas.Min < 1 {
+		ret.Replicas.Min = 1
+	}
+	if ret.Replicas.Max < ret.Replicas.Min {
+		ret.Replicas.Max = ret.Replicas.Min
+	}
+	if ret.TargetPendingRequests < 1 {
+		ret.TargetPendingRequests = defaultTargetPendingRequests
+	}
+	return ret
+}
+
+// pausedReplicas returns the replica count to pause httpso's target at.
+// That's replicas if it isn't nil. Otherwise, it's the count recorded in
+// httpso's status the first time it was reconciled while paused, or
+// target's current replica count if there isn't one yet, which is then
+// recorded. The live count isn't used after that, since it may have been
+// changed by something else while scaling was paused
+func pausedReplicas(
+	ctx context.Context,
+	cl client.Client,
+	httpso *v1alpha2.HTTPScaledObject,
+	target v1alpha2.TargetRef,
+	replicas *int32,
+) (int32, error) {
+	if replicas != nil {
+		return *replicas, nil
+	}
+	if count, ok := httpso.Status.PausedReplicas[target.Name]; ok {
+		return count, nil
+	}
+	count, err := currentReplicas(ctx, cl, httpso.Namespace, target)
+	if err != nil {
+		return 0, err
+	}
+	if httpso.Status.PausedReplicas == nil {
+		httpso.Status.PausedReplicas = map[string]int32{}
+	}
+	httpso.Status.PausedReplicas[target.Name] = count
+	return count, nil
+}
+
+// currentReplicas returns the replica count in target's spec
+func currentReplicas(
+	ctx context.Context,
+	cl client.Client,
+	namespace string,
+	target v1alpha2.TargetRef,
+) (int32, error) {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(target.TargetAPIVersion())
+	obj.SetKind(target.TargetKind())
+	if err := cl.Get(ctx, client.ObjectKey{Namespace: namespace, Name: target.Name}, obj); err != nil {
+		return 0, err
+	}
+	current, found, err := unstructured.NestedInt64(obj.Object, "spec", "replicas")
+	if err != nil {
+		return 0, err
+	}
+	if !found {
+		// Kubernetes defaults the replicas of workloads to 1
+		return 1, nil
+	}
+	return int32(current), nil
+}
+
+// create a ScaledObject for each of httpso's targets and one for the
+// interceptor, or update them to match httpso if they already exist.
+// ScaledObjects for targets that have been removed from httpso are deleted.
+//
+// If httpso's scaling is paused, the targets' ScaledObjects are annotated
+// so that KEDA holds them at their paused replica counts, and httpso gets
+// the Paused condition. The interceptor's ScaledObject is never paused,
+// since paused targets still get requests
 func createScaledObjects(
 	ctx context.Context,
 	appInfo config.AppInfo,
 	cl client.Client,
 	logger logr.Logger,
 	externalScalerHostName string,
-	httpso *v1alpha1.HTTPScaledObject,
+	httpso *v1alpha2.HTTPScaledObject,
 ) error {
 
 	logger.Info("Creating scaled objects", "external scaler host name", externalScalerHostName)
 
-	appScaledObject, appErr := k8s.NewScaledObject(
-		appInfo.Namespace,
-		config.AppScaledObjectName(httpso),
-		appInfo.Name,
-		externalScalerHostName,
-		httpso.Spec.Replicas.Min,
-		httpso.Spec.Replicas.Max,
-	)
-	if appErr != nil {
-		return appErr
+	policy := httpso.Spec.ScalingPolicy
+	// the scaler may count requests for other apps, and for other routes
+	// on the same hosts, so it needs to know which routes are this app's
+	routes := routeKeys(httpso)
+	paused, replicas, err := httpso.Paused()
+	if err != nil {
+		httpso.SetCondition(
+			v1alpha2.AppScaledObjectReady,
+			v1.ConditionFalse,
+			v1alpha2.ErrorCreatingAppScaledObject,
+			err.Error(),
+		)
+		return err
+	}
+	if !paused || replicas != nil {
+		// the recorded counts are only for pausing at the current count,
+		// and the next pause should record new ones
+		httpso.Status.PausedReplicas = nil
+	}
+	appScaledObjectNames := map[string]bool{}
+	for _, target := range httpso.Spec.Targets {
+		appScaledObject, err := k8s.NewScaledObject(
+			appInfo.Namespace,
+			config.AppScaledObjectName(target),
+			target.TargetAPIVersion(),
+			target.TargetKind(),
+			target.Name,
+			externalScalerHostName,
+			k8s.ScaledObjectOptions{
+				MinReplicas:           policy.Replicas.Min,
+				MaxReplicas:           policy.Replicas.Max,
+				TargetPendingRequests: targetPendingRequestsForTarget(httpso, target),
+				Routes:                routes,
+				PollingInterval:       policy.PollingInterval,
+				CooldownPeriod:        policy.CooldownPeriod,
+				Behavior:              policy.Behavior,
+				CronTriggers:          cronTriggers(policy.PrewarmWindows),
+			},
+		)
+		if err != nil {
+			return err
+		}
+		if paused {
+			count, err := pausedReplicas(ctx, cl, httpso, target, replicas)
+			if err != nil {
+				httpso.SetCondition(
+					v1alpha2.AppScaledObjectReady,
+					v1.ConditionFalse,
+					v1alpha2.ErrorCreatingAppScaledObject,
+					err.Error(),
+				)
+				return err
+			}
+			appScaledObject.SetAnnotations(map[string]string{
+				k8s.PausedReplicasAnnotation: strconv.Itoa(int(count)),
+			})
+		}
+		if _, err := applyUnstructured(ctx, cl, logger, httpso, appScaledObject); err != nil {
+			httpso.SetCondition(
+				v1alpha2.AppScaledObjectReady,
+				v1.ConditionFalse,
+				v1alpha2.ErrorCreatingAppScaledObject,
+				err.Error(),
+			)
+			return err
+		}
+		appScaledObjectNames[appScaledObject.GetName()] = true
 	}
 
+	interceptorPolicy := interceptorScalingPolicy(appInfo, httpso)
 	interceptorScaledObject, interceptorErr := k8s.NewScaledObject(
 		appInfo.Namespace,
-		config.InterceptorScaledObjectName(httpso),
+		appInfo.InterceptorScaledObjectName(),
+		"apps/v1",
+		"Deployment",
 		appInfo.InterceptorDeploymentName(),
 		externalScalerHostName,
-		httpso.Spec.Replicas.Min,
-		httpso.Spec.Replicas.Max,
+		k8s.ScaledObjectOptions{
+			MinReplicas:           interceptorPolicy.Replicas.Min,
+			MaxReplicas:           interceptorPolicy.Replicas.Max,
+			TargetPendingRequests: interceptorPolicy.TargetPendingRequests,
+			PollingInterval:       interceptorPolicy.PollingInterval,
+			CooldownPeriod:        interceptorPolicy.CooldownPeriod,
+			Behavior:              interceptorPolicy.Behavior,
+			CronTriggers:          cronTriggers(interceptorPolicy.PrewarmWindows),
+		},
 	)
 	if interceptorErr != nil {
 		return interceptorErr
 	}
 
-	logger.Info("Creating App ScaledObject", "ScaledObject", *appScaledObject)
-	if err := cl.Create(ctx, appScaledObject); err != nil {
-		if errors.IsAlreadyExists(err) {
-			logger.Info("User app scaled object already exists, moving on")
-		} else {
-			logger.Error(err, "Creating ScaledObject")
-			httpso.AddCondition(*v1alpha1.CreateCondition(
-				v1alpha1.Error,
-				v1.ConditionFalse,
-				v1alpha1.ErrorCreatingAppScaledObject,
-			).SetMessage(err.Error()))
-			return err
-		}
+	if err := deleteStaleScaledObjects(
+		ctx,
+		cl,
+		logger,
+		httpso,
+		appScaledObjectNames,
+		interceptorScaledObject.GetName(),
+	); err != nil {
+		httpso.SetCondition(
+			v1alpha2.AppScaledObjectReady,
+			v1.ConditionFalse,
+			v1alpha2.ErrorCreatingAppScaledObject,
+			err.Error(),
+		)
+		return err
 	}
 
-	httpso.AddCondition(*v1alpha1.CreateCondition(
-		v1alpha1.Created,
+	httpso.SetCondition(
+		v1alpha2.AppScaledObjectReady,
 		v1.ConditionTrue,
-		v1alpha1.AppScaledObjectCreated,
-	).SetMessage("App ScaledObject created"))
+		v1alpha2.AppScaledObjectCreated,
+		"App ScaledObject created",
+	)
+	if paused {
+		httpso.SetCondition(
+			v1alpha2.Paused,
+			v1.ConditionTrue,
+			v1alpha2.ScalingPaused,
+			"Scaling is paused by the HTTPScaledObject's annotations",
+		)
+	} else {
+		httpso.RemoveCondition(v1alpha2.Paused)
+	}
 
 	// Interceptor ScaledObject
-	logger.Info("Creating Interceptor ScaledObject", "ScaledObject", *interceptorScaledObject)
-	if err := cl.Create(ctx, interceptorScaledObject); err != nil {
-		if errors.IsAlreadyExists(err) {
-			logger.Info("Interceptor ScaledObject already exists, moving on")
-		} else {
-			logger.Error(err, "Creating Interceptor ScaledObject")
-			httpso.AddCondition(*v1alpha1.CreateCondition(
-				v1alpha1.Error,
-				v1.ConditionFalse,
-				v1alpha1.ErrorCreatingInterceptorScaledObject,
-			).SetMessage(err.Error()))
-			return err
-		}
+	if _, err := applyUnstructured(ctx, cl, logger, interceptorOwner(appInfo, httpso), interceptorScaledObject); err != nil {
+		httpso.SetCondition(
+			v1alpha2.InterceptorScaledObjectReady,
+			v1.ConditionFalse,
+			v1alpha2.ErrorCreatingInterceptorScaledObject,
+			err.Error(),
+		)
+		return err
 	}
 
-	httpso.AddCondition(*v1alpha1.CreateCondition(
-		v1alpha1.Created,
+	httpso.SetCondition(
+		v1alpha2.InterceptorScaledObjectReady,
 		v1.ConditionTrue,
-		v1alpha1.InterceptorScaledObjectCreated,
-	).SetMessage("Interceptor Scaled object created"))
+		v1alpha2.InterceptorScaledObjectCreated,
+		"Interceptor ScaledObject created",
+	)
 
 	return nil
 }
+
+// deleteStaleScaledObjects deletes the ScaledObjects that httpso controls
+// other than the ones for its targets, which are named in appNames, and
+// the one for its interceptor, so that targets removed from httpso are
+// no longer scaled
+func deleteStaleScaledObjects(
+	ctx context.Context,
+	cl client.Client,
+	logger logr.Logger,
+	httpso *v1alpha2.HTTPScaledObject,
+	appNames map[string]bool,
+	interceptorName string,
+) error {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(schema.GroupVersionKind{
+		Group:   "keda.sh",
+		Version: "v1alpha1",
+		Kind:    "ScaledObjectList",
+	})
+	if err := cl.List(ctx, list, client.InNamespace(httpso.Namespace)); err != nil {
+		return err
+	}
+	for i := range list.Items {
+		scaledObject := &list.Items[i]
+		name := scaledObject.GetName()
+		if appNames[name] || name == interceptorName || !v1.IsControlledBy(scaledObject, httpso) {
+			continue
+		}
+		logger.Info("Deleting ScaledObject for removed target", "name", name)
+		if err := cl.Delete(ctx, scaledObject); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -2,12 +2,18 @@ package controllers
 
 import (
 	"fmt"
+	"math"
+	"strconv"
 	"time"
 
-	"github.com/kedacore/http-add-on/operator/api/v1alpha1"
+	"github.com/kedacore/http-add-on/operator/api/v1alpha2"
 	"github.com/kedacore/http-add-on/operator/controllers/config"
+	"github.com/kedacore/http-add-on/pkg/k8s"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -40,20 +46,16 @@ var _ = Describe("UserApp", func() {
 			Expect(len(testInfra.httpso.Status.Conditions)).To(Equal(2))
 
 			cond1 := testInfra.httpso.Status.Conditions[0]
-			cond1ts, err := time.Parse(time.RFC3339, cond1.Timestamp)
-			Expect(err).To(BeNil())
-			Expect(time.Since(cond1ts) >= 0).To(BeTrue())
-			Expect(cond1.Type).To(Equal(v1alpha1.Created))
+			Expect(time.Since(cond1.LastTransitionTime.Time) >= 0).To(BeTrue())
+			Expect(cond1.Type).To(Equal(string(v1alpha2.AppScaledObjectReady)))
 			Expect(cond1.Status).To(Equal(metav1.ConditionTrue))
-			Expect(cond1.Reason).To(Equal(v1alpha1.AppScaledObjectCreated))
+			Expect(cond1.Reason).To(Equal(string(v1alpha2.AppScaledObjectCreated)))
 
 			cond2 := testInfra.httpso.Status.Conditions[1]
-			cond2ts, err := time.Parse(time.RFC3339, cond2.Timestamp)
-			Expect(err).To(BeNil())
-			Expect(time.Since(cond2ts) >= 0).To(BeTrue())
-			Expect(cond2.Type).To(Equal(v1alpha1.Created))
+			Expect(time.Since(cond2.LastTransitionTime.Time) >= 0).To(BeTrue())
+			Expect(cond2.Type).To(Equal(string(v1alpha2.InterceptorScaledObjectReady)))
 			Expect(cond2.Status).To(Equal(metav1.ConditionTrue))
-			Expect(cond2.Reason).To(Equal(v1alpha1.InterceptorScaledObjectCreated))
+			Expect(cond2.Reason).To(Equal(string(v1alpha2.InterceptorScaledObjectCreated)))
 
 			// check that the app ScaledObject was created
 			u := &unstructured.Unstructured{}
@@ -64,7 +66,7 @@ var _ = Describe("UserApp", func() {
 			})
 			objectKey := client.ObjectKey{
 				Namespace: testInfra.cfg.Namespace,
-				Name:      config.AppScaledObjectName(&testInfra.httpso),
+				Name:      config.AppScaledObjectName(testInfra.httpso.Spec.Targets[0]),
 			}
 			err = testInfra.cl.Get(testInfra.ctx, objectKey, u)
 			Expect(err).To(BeNil())
@@ -72,28 +74,544 @@ var _ = Describe("UserApp", func() {
 			metadata, err := getKeyAsMap(u.Object, "metadata")
 			Expect(err).To(BeNil())
 			Expect(metadata["namespace"]).To(Equal(testInfra.ns))
-			Expect(metadata["name"]).To(Equal(config.AppScaledObjectName(&testInfra.httpso)))
+			Expect(metadata["name"]).To(Equal(config.AppScaledObjectName(testInfra.httpso.Spec.Targets[0])))
 
 			spec, err := getKeyAsMap(u.Object, "spec")
 			Expect(err).To(BeNil())
-			Expect(spec["minReplicaCount"]).To(BeNumerically("==", testInfra.httpso.Spec.Replicas.Min))
-			Expect(spec["maxReplicaCount"]).To(BeNumerically("==", testInfra.httpso.Spec.Replicas.Max))
+			Expect(spec["minReplicaCount"]).To(BeNumerically("==", testInfra.httpso.Spec.ScalingPolicy.Replicas.Min))
+			Expect(spec["maxReplicaCount"]).To(BeNumerically("==", testInfra.httpso.Spec.ScalingPolicy.Replicas.Max))
+
+			// the HTTPScaledObject only names a deployment, so that's what
+			// should be scaled
+			scaleTargetRef, err := getKeyAsMap(spec, "scaleTargetRef")
+			Expect(err).To(BeNil())
+			Expect(scaleTargetRef["apiVersion"]).To(Equal("apps/v1"))
+			Expect(scaleTargetRef["kind"]).To(Equal("Deployment"))
+			Expect(scaleTargetRef["name"]).To(Equal(testInfra.httpso.Spec.Targets[0].Name))
+
+			// the HTTPScaledObject doesn't specify a target, so the default
+			// should be in the trigger metadata
+			triggers, ok := spec["triggers"].([]interface{})
+			Expect(ok).To(BeTrue())
+			Expect(len(triggers)).To(Equal(1))
+			trigger, ok := triggers[0].(map[string]interface{})
+			Expect(ok).To(BeTrue())
+			triggerMeta, err := getKeyAsMap(trigger, "metadata")
+			Expect(err).To(BeNil())
+			Expect(triggerMeta["scalerAddress"]).To(Equal(externalScalerHostName))
+			Expect(triggerMeta["targetPendingRequests"]).To(Equal(
+				fmt.Sprintf("%d", defaultTargetPendingRequests),
+			))
 
 			// check that the interceptor ScaledObject was created
 
-			objectKey.Name = config.InterceptorScaledObjectName(&testInfra.httpso)
+			objectKey.Name = testInfra.cfg.InterceptorScaledObjectName()
 			err = testInfra.cl.Get(testInfra.ctx, objectKey, u)
 			Expect(err).To(BeNil())
 
 			metadata, err = getKeyAsMap(u.Object, "metadata")
 			Expect(err).To(BeNil())
 			Expect(metadata["namespace"]).To(Equal(testInfra.ns))
-			Expect(metadata["name"]).To(Equal(config.InterceptorScaledObjectName(&testInfra.httpso)))
+			Expect(metadata["name"]).To(Equal(testInfra.cfg.InterceptorScaledObjectName()))
+
+			// the interceptor doesn't scale to zero with the app, so that
+			// there's always one to wake the app up
+			spec, err = getKeyAsMap(u.Object, "spec")
+			Expect(err).To(BeNil())
+			Expect(spec["minReplicaCount"]).To(BeNumerically("==", testInfra.cfg.InterceptorConfig.MinReplicas))
+			Expect(spec["maxReplicaCount"]).To(BeNumerically("==", testInfra.cfg.InterceptorConfig.MaxReplicas))
+		})
+		It("Should template the polling interval, cooldown period and behavior", func() {
+			getSpec := func() map[string]interface{} {
+				u := &unstructured.Unstructured{}
+				u.SetGroupVersionKind(schema.GroupVersionKind{
+					Group:   "keda.sh",
+					Kind:    "ScaledObject",
+					Version: "v1alpha1",
+				})
+				Expect(testInfra.cl.Get(testInfra.ctx, client.ObjectKey{
+					Namespace: testInfra.ns,
+					Name:      config.AppScaledObjectName(testInfra.httpso.Spec.Targets[0]),
+				}, u)).To(BeNil())
+				spec, err := getKeyAsMap(u.Object, "spec")
+				Expect(err).To(BeNil())
+				return spec
+			}
+			create := func() {
+				Expect(createScaledObjects(
+					testInfra.ctx,
+					testInfra.cfg,
+					testInfra.cl,
+					testInfra.logger,
+					externalScalerHostName,
+					&testInfra.httpso,
+				)).To(BeNil())
+			}
+
+			// without them, KEDA's defaults apply, except for the polling
+			// interval
+			create()
+			spec := getSpec()
+			Expect(spec["pollingInterval"]).To(BeNumerically("==", k8s.DefaultPollingInterval))
+			Expect(spec).To(Not(HaveKey("cooldownPeriod")))
+			Expect(spec).To(Not(HaveKey("advanced")))
+
+			pollingInterval, cooldownPeriod, window := int32(15), int32(0), int32(600)
+			testInfra.httpso.Spec.ScalingPolicy.PollingInterval = &pollingInterval
+			testInfra.httpso.Spec.ScalingPolicy.CooldownPeriod = &cooldownPeriod
+			testInfra.httpso.Spec.ScalingPolicy.Behavior = &autoscalingv2beta2.HorizontalPodAutoscalerBehavior{
+				ScaleDown: &autoscalingv2beta2.HPAScalingRules{
+					StabilizationWindowSeconds: &window,
+					Policies: []autoscalingv2beta2.HPAScalingPolicy{
+						{Type: autoscalingv2beta2.PodsScalingPolicy, Value: 1, PeriodSeconds: 60},
+					},
+				},
+			}
+			create()
+			spec = getSpec()
+			Expect(spec["pollingInterval"]).To(BeNumerically("==", 15))
+			// a cooldown period of 0 is set, not left to KEDA's default
+			Expect(spec["cooldownPeriod"]).To(BeNumerically("==", 0))
+			scaleDown, found, err := unstructured.NestedMap(
+				spec,
+				"advanced",
+				"horizontalPodAutoscalerConfig",
+				"behavior",
+				"scaleDown",
+			)
+			Expect(err).To(BeNil())
+			Expect(found).To(BeTrue())
+			Expect(scaleDown["stabilizationWindowSeconds"]).To(BeNumerically("==", 600))
+			Expect(scaleDown["policies"]).To(HaveLen(1))
+		})
+		It("Should keep the app warm during its pre-warming windows", func() {
+			testInfra.httpso.Spec.ScalingPolicy.PrewarmWindows = []v1alpha2.PrewarmWindow{
+				{Timezone: "Europe/Berlin", Start: "45 8 * * 1-5", End: "0 10 * * 1-5", MinReplicas: 5},
+			}
+			Expect(createScaledObjects(
+				testInfra.ctx,
+				testInfra.cfg,
+				testInfra.cl,
+				testInfra.logger,
+				externalScalerHostName,
+				&testInfra.httpso,
+			)).To(BeNil())
+
+			u := &unstructured.Unstructured{}
+			u.SetGroupVersionKind(schema.GroupVersionKind{
+				Group:   "keda.sh",
+				Kind:    "ScaledObject",
+				Version: "v1alpha1",
+			})
+			Expect(testInfra.cl.Get(testInfra.ctx, client.ObjectKey{
+				Namespace: testInfra.ns,
+				Name:      config.AppScaledObjectName(testInfra.httpso.Spec.Targets[0]),
+			}, u)).To(BeNil())
+			triggers, found, err := unstructured.NestedSlice(u.Object, "spec", "triggers")
+			Expect(err).To(BeNil())
+			Expect(found).To(BeTrue())
+			// the cron trigger comes after the external scaler's
+			Expect(triggers).To(HaveLen(2))
+			cron := triggers[1].(map[string]interface{})
+			Expect(cron["type"]).To(Equal("cron"))
+			Expect(cron["metadata"]).To(Equal(map[string]interface{}{
+				"timezone":        "Europe/Berlin",
+				"start":           "45 8 * * 1-5",
+				"end":             "0 10 * * 1-5",
+				"desiredReplicas": "5",
+			}))
+
+			// the interceptor only gets the windows in its own policy
+			Expect(testInfra.cl.Get(testInfra.ctx, client.ObjectKey{
+				Namespace: testInfra.ns,
+				Name:      testInfra.cfg.InterceptorScaledObjectName(),
+			}, u)).To(BeNil())
+			triggers, _, err = unstructured.NestedSlice(u.Object, "spec", "triggers")
+			Expect(err).To(BeNil())
+			Expect(triggers).To(HaveLen(1))
+		})
+		It("Should scale the interceptor according to its own scaling policy", func() {
+			testInfra.httpso.Spec.InterceptorScalingPolicy = &v1alpha2.ScalingPolicy{
+				Replicas:              v1alpha2.ReplicaStruct{Min: 2},
+				TargetPendingRequests: 50,
+			}
+			Expect(createScaledObjects(
+				testInfra.ctx,
+				testInfra.cfg,
+				testInfra.cl,
+				testInfra.logger,
+				externalScalerHostName,
+				&testInfra.httpso,
+			)).To(BeNil())
+
+			u := &unstructured.Unstructured{}
+			u.SetGroupVersionKind(schema.GroupVersionKind{
+				Group:   "keda.sh",
+				Kind:    "ScaledObject",
+				Version: "v1alpha1",
+			})
+			Expect(testInfra.cl.Get(testInfra.ctx, client.ObjectKey{
+				Namespace: testInfra.ns,
+				Name:      testInfra.cfg.InterceptorScaledObjectName(),
+			}, u)).To(BeNil())
+			spec, err := getKeyAsMap(u.Object, "spec")
+			Expect(err).To(BeNil())
+			Expect(spec["minReplicaCount"]).To(BeNumerically("==", 2))
+			// the max wasn't set, so it comes from the operator's config
+			Expect(spec["maxReplicaCount"]).To(BeNumerically("==", testInfra.cfg.InterceptorConfig.MaxReplicas))
+			triggers, _, err := unstructured.NestedSlice(u.Object, "spec", "triggers")
+			Expect(err).To(BeNil())
+			metadata := triggers[0].(map[string]interface{})["metadata"].(map[string]interface{})
+			Expect(metadata["targetPendingRequests"]).To(Equal("50"))
+		})
+		It("Should update the ScaledObjects when the HTTPScaledObject changes", func() {
+			err := createScaledObjects(
+				testInfra.ctx,
+				testInfra.cfg,
+				testInfra.cl,
+				testInfra.logger,
+				externalScalerHostName,
+				&testInfra.httpso,
+			)
+			Expect(err).To(BeNil())
+
+			testInfra.httpso.Spec.ScalingPolicy.Replicas.Min = 2
+			testInfra.httpso.Spec.ScalingPolicy.Replicas.Max = 30
+			testInfra.httpso.Spec.ScalingPolicy.TargetPendingRequests = 10
+			err = createScaledObjects(
+				testInfra.ctx,
+				testInfra.cfg,
+				testInfra.cl,
+				testInfra.logger,
+				externalScalerHostName,
+				&testInfra.httpso,
+			)
+			Expect(err).To(BeNil())
+
+			u := &unstructured.Unstructured{}
+			u.SetGroupVersionKind(schema.GroupVersionKind{
+				Group:   "keda.sh",
+				Kind:    "ScaledObject",
+				Version: "v1alpha1",
+			})
+			objectKey := client.ObjectKey{
+				Namespace: testInfra.cfg.Namespace,
+				Name:      config.AppScaledObjectName(testInfra.httpso.Spec.Targets[0]),
+			}
+			err = testInfra.cl.Get(testInfra.ctx, objectKey, u)
+			Expect(err).To(BeNil())
+			spec, err := getKeyAsMap(u.Object, "spec")
+			Expect(err).To(BeNil())
+			Expect(spec["minReplicaCount"]).To(BeNumerically("==", 2))
+			Expect(spec["maxReplicaCount"]).To(BeNumerically("==", 30))
+			triggers, ok := spec["triggers"].([]interface{})
+			Expect(ok).To(BeTrue())
+			Expect(len(triggers)).To(Equal(1))
+			trigger, ok := triggers[0].(map[string]interface{})
+			Expect(ok).To(BeTrue())
+			triggerMeta, err := getKeyAsMap(trigger, "metadata")
+			Expect(err).To(BeNil())
+			Expect(triggerMeta["targetPendingRequests"]).To(Equal("10"))
+
+			// updating shouldn't add more conditions
+			Expect(len(testInfra.httpso.Status.Conditions)).To(Equal(2))
+		})
+		It("Should scale each target for its weight's share of the requests", func() {
+			weight := func(w int32) *int32 { return &w }
+			testInfra.httpso.Spec.ScalingPolicy.TargetPendingRequests = 100
+			testInfra.httpso.Spec.Targets = []v1alpha2.TargetRef{
+				{Name: "stable", Service: "stable", Port: 8080, Weight: weight(3)},
+				{Name: "canary", Service: "canary", Port: 8080, Weight: weight(1)},
+				{Name: "dark", Service: "dark", Port: 8080, Weight: weight(0)},
+			}
+			Expect(createScaledObjects(
+				testInfra.ctx,
+				testInfra.cfg,
+				testInfra.cl,
+				testInfra.logger,
+				externalScalerHostName,
+				&testInfra.httpso,
+			)).To(BeNil())
+
+			targetPending := func(target v1alpha2.TargetRef) interface{} {
+				u := &unstructured.Unstructured{}
+				u.SetGroupVersionKind(schema.GroupVersionKind{
+					Group:   "keda.sh",
+					Kind:    "ScaledObject",
+					Version: "v1alpha1",
+				})
+				Expect(testInfra.cl.Get(testInfra.ctx, client.ObjectKey{
+					Namespace: testInfra.ns,
+					Name:      config.AppScaledObjectName(target),
+				}, u)).To(BeNil())
+				triggers, _, err := unstructured.NestedSlice(u.Object, "spec", "triggers")
+				Expect(err).To(BeNil())
+				metadata := triggers[0].(map[string]interface{})["metadata"].(map[string]interface{})
+				return metadata["targetPendingRequests"]
+			}
+			// every target sees all the requests, but the stable target
+			// only serves 3/4 of them and the canary 1/4
+			Expect(targetPending(testInfra.httpso.Spec.Targets[0])).To(Equal("134"))
+			Expect(targetPending(testInfra.httpso.Spec.Targets[1])).To(Equal("400"))
+			// and a target with no weight serves none of them
+			Expect(targetPending(testInfra.httpso.Spec.Targets[2])).To(Equal(strconv.Itoa(math.MaxInt32)))
+		})
+		It("Should scale the resource named in the scaleTargetRef", func() {
+			testInfra.httpso.Spec.Targets = []v1alpha2.TargetRef{
+				{
+					APIVersion: "argoproj.io/v1alpha1",
+					Kind:       "Rollout",
+					Name:       "testrollout",
+					Service:    "testsvc",
+					Port:       8081,
+				},
+			}
+			err := createScaledObjects(
+				testInfra.ctx,
+				testInfra.cfg,
+				testInfra.cl,
+				testInfra.logger,
+				externalScalerHostName,
+				&testInfra.httpso,
+			)
+			Expect(err).To(BeNil())
+
+			u := &unstructured.Unstructured{}
+			u.SetGroupVersionKind(schema.GroupVersionKind{
+				Group:   "keda.sh",
+				Kind:    "ScaledObject",
+				Version: "v1alpha1",
+			})
+			objectKey := client.ObjectKey{
+				Namespace: testInfra.cfg.Namespace,
+				Name:      config.AppScaledObjectName(testInfra.httpso.Spec.Targets[0]),
+			}
+			Expect(objectKey.Name).To(Equal("testrollout-app"))
+			err = testInfra.cl.Get(testInfra.ctx, objectKey, u)
+			Expect(err).To(BeNil())
+
+			spec, err := getKeyAsMap(u.Object, "spec")
+			Expect(err).To(BeNil())
+			scaleTargetRef, err := getKeyAsMap(spec, "scaleTargetRef")
+			Expect(err).To(BeNil())
+			Expect(scaleTargetRef["apiVersion"]).To(Equal("argoproj.io/v1alpha1"))
+			Expect(scaleTargetRef["kind"]).To(Equal("Rollout"))
+			Expect(scaleTargetRef["name"]).To(Equal("testrollout"))
 
+			// the interceptor is always a deployment
+			objectKey.Name = testInfra.cfg.InterceptorScaledObjectName()
+			err = testInfra.cl.Get(testInfra.ctx, objectKey, u)
+			Expect(err).To(BeNil())
 			spec, err = getKeyAsMap(u.Object, "spec")
 			Expect(err).To(BeNil())
-			Expect(spec["minReplicaCount"]).To(BeNumerically("==", testInfra.httpso.Spec.Replicas.Min))
-			Expect(spec["maxReplicaCount"]).To(BeNumerically("==", testInfra.httpso.Spec.Replicas.Max))
+			scaleTargetRef, err = getKeyAsMap(spec, "scaleTargetRef")
+			Expect(err).To(BeNil())
+			Expect(scaleTargetRef["kind"]).To(Equal("Deployment"))
+			Expect(scaleTargetRef["name"]).To(Equal(testInfra.cfg.InterceptorDeploymentName()))
+		})
+		It("Should scale every target and stop scaling removed ones", func() {
+			testInfra.httpso.Spec.Targets = append(
+				testInfra.httpso.Spec.Targets,
+				v1alpha2.TargetRef{
+					Kind:    "StatefulSet",
+					Name:    "canary",
+					Service: "canarysvc",
+					Port:    8081,
+				},
+			)
+			err := createScaledObjects(
+				testInfra.ctx,
+				testInfra.cfg,
+				testInfra.cl,
+				testInfra.logger,
+				externalScalerHostName,
+				&testInfra.httpso,
+			)
+			Expect(err).To(BeNil())
+
+			u := &unstructured.Unstructured{}
+			u.SetGroupVersionKind(schema.GroupVersionKind{
+				Group:   "keda.sh",
+				Kind:    "ScaledObject",
+				Version: "v1alpha1",
+			})
+			canaryKey := client.ObjectKey{
+				Namespace: testInfra.cfg.Namespace,
+				Name:      "canary-app",
+			}
+			Expect(testInfra.cl.Get(testInfra.ctx, canaryKey, u)).To(BeNil())
+			spec, err := getKeyAsMap(u.Object, "spec")
+			Expect(err).To(BeNil())
+			scaleTargetRef, err := getKeyAsMap(spec, "scaleTargetRef")
+			Expect(err).To(BeNil())
+			Expect(scaleTargetRef["kind"]).To(Equal("StatefulSet"))
+			Expect(scaleTargetRef["name"]).To(Equal("canary"))
+
+			// removing the canary should delete its ScaledObject, but
+			// leave the others alone
+			testInfra.httpso.Spec.Targets = testInfra.httpso.Spec.Targets[:1]
+			err = createScaledObjects(
+				testInfra.ctx,
+				testInfra.cfg,
+				testInfra.cl,
+				testInfra.logger,
+				externalScalerHostName,
+				&testInfra.httpso,
+			)
+			Expect(err).To(BeNil())
+			Expect(errors.IsNotFound(testInfra.cl.Get(testInfra.ctx, canaryKey, u))).To(BeTrue())
+			Expect(testInfra.cl.Get(testInfra.ctx, client.ObjectKey{
+				Namespace: testInfra.cfg.Namespace,
+				Name:      config.AppScaledObjectName(testInfra.httpso.Spec.Targets[0]),
+			}, u)).To(BeNil())
+			Expect(testInfra.cl.Get(testInfra.ctx, client.ObjectKey{
+				Namespace: testInfra.cfg.Namespace,
+				Name:      testInfra.cfg.InterceptorScaledObjectName(),
+			}, u)).To(BeNil())
+		})
+		It("Should scale apps by host with a shared interceptor fleet", func() {
+			sharedCfg := testInfra.cfg
+			sharedCfg.Name = config.SharedName
+			sharedCfg.InterceptorConfig.Shared = true
+			testInfra.httpso.Spec.Hosts = []string{"a.com", "b.com"}
+			err := createScaledObjects(
+				testInfra.ctx,
+				sharedCfg,
+				testInfra.cl,
+				testInfra.logger,
+				externalScalerHostName,
+				&testInfra.httpso,
+			)
+			Expect(err).To(BeNil())
+
+			u := &unstructured.Unstructured{}
+			u.SetGroupVersionKind(schema.GroupVersionKind{
+				Group:   "keda.sh",
+				Kind:    "ScaledObject",
+				Version: "v1alpha1",
+			})
+			Expect(testInfra.cl.Get(testInfra.ctx, client.ObjectKey{
+				Namespace: testInfra.ns,
+				Name:      config.AppScaledObjectName(testInfra.httpso.Spec.Targets[0]),
+			}, u)).To(BeNil())
+			triggers, found, err := unstructured.NestedSlice(u.Object, "spec", "triggers")
+			Expect(err).To(BeNil())
+			Expect(found).To(BeTrue())
+			metadata := triggers[0].(map[string]interface{})["metadata"].(map[string]interface{})
+			Expect(metadata["routes"]).To(Equal("a.com,b.com"))
+
+			// the fleet's ScaledObject keeps a replica around for all the
+			// apps, and isn't controlled by any one of them
+			Expect(testInfra.cl.Get(testInfra.ctx, client.ObjectKey{
+				Namespace: testInfra.ns,
+				Name:      sharedCfg.InterceptorScaledObjectName(),
+			}, u)).To(BeNil())
+			minReplicas, _, err := unstructured.NestedInt64(u.Object, "spec", "minReplicaCount")
+			Expect(err).To(BeNil())
+			Expect(minReplicas).To(BeNumerically("==", 1))
+			Expect(metav1.GetControllerOf(u)).To(BeNil())
+			Expect(u.GetOwnerReferences()).To(HaveLen(1))
+		})
+		It("Should pause and resume the app's scaling", func() {
+			replicas := int32(3)
+			Expect(testInfra.cl.Create(testInfra.ctx, &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testInfra.ns, Name: testInfra.appName},
+				Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+			})).To(BeNil())
+			create := func() {
+				Expect(createScaledObjects(
+					testInfra.ctx,
+					testInfra.cfg,
+					testInfra.cl,
+					testInfra.logger,
+					externalScalerHostName,
+					&testInfra.httpso,
+				)).To(BeNil())
+			}
+			// pausedAnnotation returns the KEDA pause annotation of the
+			// ScaledObject called name, and whether it has one
+			pausedAnnotation := func(name string) (string, bool) {
+				u := &unstructured.Unstructured{}
+				u.SetGroupVersionKind(schema.GroupVersionKind{
+					Group:   "keda.sh",
+					Kind:    "ScaledObject",
+					Version: "v1alpha1",
+				})
+				Expect(testInfra.cl.Get(testInfra.ctx, client.ObjectKey{
+					Namespace: testInfra.ns,
+					Name:      name,
+				}, u)).To(BeNil())
+				val, ok := u.GetAnnotations()[k8s.PausedReplicasAnnotation]
+				return val, ok
+			}
+			appName := config.AppScaledObjectName(testInfra.httpso.Spec.Targets[0])
+
+			// paused at the current replica count
+			testInfra.httpso.Annotations = map[string]string{v1alpha2.PausedAnnotation: "true"}
+			create()
+			val, ok := pausedAnnotation(appName)
+			Expect(ok).To(BeTrue())
+			Expect(val).To(Equal("3"))
+			cond := testInfra.httpso.GetCondition(v1alpha2.Paused)
+			Expect(cond).To(Not(BeNil()))
+			Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+			Expect(cond.Reason).To(Equal(string(v1alpha2.ScalingPaused)))
+			// the interceptor keeps scaling, since it still gets requests
+			_, ok = pausedAnnotation(testInfra.cfg.InterceptorScaledObjectName())
+			Expect(ok).To(BeFalse())
+			Expect(testInfra.httpso.Status.PausedReplicas).To(Equal(map[string]int32{testInfra.appName: 3}))
+
+			// the count is recorded when scaling is first paused, so later
+			// changes to the app's replicas don't move it
+			setReplicas := func(count int32) {
+				depl := &appsv1.Deployment{}
+				Expect(testInfra.cl.Get(testInfra.ctx, client.ObjectKey{
+					Namespace: testInfra.ns,
+					Name:      testInfra.appName,
+				}, depl)).To(BeNil())
+				depl.Spec.Replicas = &count
+				Expect(testInfra.cl.Update(testInfra.ctx, depl)).To(BeNil())
+			}
+			setReplicas(5)
+			create()
+			val, _ = pausedAnnotation(appName)
+			Expect(val).To(Equal("3"))
+
+			// paused at a fixed replica count
+			testInfra.httpso.Annotations[v1alpha2.PausedReplicasAnnotation] = "0"
+			create()
+			val, ok = pausedAnnotation(appName)
+			Expect(ok).To(BeTrue())
+			Expect(val).To(Equal("0"))
+			Expect(testInfra.httpso.Status.PausedReplicas).To(BeNil())
+
+			// resumed
+			testInfra.httpso.Annotations = nil
+			create()
+			_, ok = pausedAnnotation(appName)
+			Expect(ok).To(BeFalse())
+			Expect(testInfra.httpso.GetCondition(v1alpha2.Paused)).To(BeNil())
+			Expect(testInfra.httpso.Status.PausedReplicas).To(BeNil())
+
+			// pausing again records the count from then
+			testInfra.httpso.Annotations = map[string]string{v1alpha2.PausedAnnotation: "true"}
+			create()
+			val, _ = pausedAnnotation(appName)
+			Expect(val).To(Equal("5"))
+		})
+		It("Should refuse invalid pause annotations", func() {
+			testInfra.httpso.Annotations = map[string]string{v1alpha2.PausedReplicasAnnotation: "lots"}
+			Expect(createScaledObjects(
+				testInfra.ctx,
+				testInfra.cfg,
+				testInfra.cl,
+				testInfra.logger,
+				externalScalerHostName,
+				&testInfra.httpso,
+			)).To(Not(BeNil()))
+			cond := testInfra.httpso.GetCondition(v1alpha2.AppScaledObjectReady)
+			Expect(cond).To(Not(BeNil()))
+			Expect(cond.Status).To(Equal(metav1.ConditionFalse))
 		})
 	})
 })
@@ -0,0 +1,269 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/kedacore/http-add-on/operator/api/v1alpha2"
+	"github.com/kedacore/http-add-on/operator/controllers/config"
+	externalscaler "github.com/kedacore/http-add-on/proto"
+	"google.golang.org/grpc"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// pendingRequestsFunc returns the number of pending requests for routes
+// that the external scaler at scalerAddress reports. routes are queue
+// keys, like the ones that routeKeys returns
+type pendingRequestsFunc func(
+	ctx context.Context,
+	scalerAddress string,
+	routes []string,
+) (int64, error)
+
+// scalerConns keeps a gRPC connection to each external scaler that
+// pending request counts are read from, so that status refreshes don't
+// dial the scalers every time
+type scalerConns struct {
+	mut   *sync.Mutex
+	conns map[string]*grpc.ClientConn
+}
+
+func newScalerConns() *scalerConns {
+	return &scalerConns{
+		mut:   new(sync.Mutex),
+		conns: map[string]*grpc.ClientConn{},
+	}
+}
+
+// conn returns the connection to the scaler at scalerAddress, creating
+// it if there isn't one yet. Connections are created without blocking,
+// so a scaler that's down fails requests rather than stalling them
+func (s *scalerConns) conn(scalerAddress string) (*grpc.ClientConn, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if conn, ok := s.conns[scalerAddress]; ok {
+		return conn, nil
+	}
+	conn, err := grpc.Dial(scalerAddress, grpc.WithInsecure())
+	if err != nil {
+		return nil, err
+	}
+	s.conns[scalerAddress] = conn
+	return conn, nil
+}
+
+// close closes all the connections
+func (s *scalerConns) close() {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	for addr, conn := range s.conns {
+		conn.Close()
+		delete(s.conns, addr)
+	}
+}
+
+// pendingRequests is the pendingRequestsFunc that asks the external
+// scaler for its metrics, the same way KEDA does
+func (s *scalerConns) pendingRequests(
+	ctx context.Context,
+	scalerAddress string,
+	routes []string,
+) (int64, error) {
+	conn, err := s.conn(scalerAddress)
+	if err != nil {
+		return 0, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	metadata := map[string]string{}
+	if len(routes) > 0 {
+		metadata["routes"] = strings.Join(routes, ",")
+	}
+	res, err := externalscaler.NewExternalScalerClient(conn).GetMetrics(
+		ctx,
+		&externalscaler.GetMetricsRequest{
+			ScaledObjectRef: &externalscaler.ScaledObjectRef{ScalerMetadata: metadata},
+		},
+	)
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, val := range res.MetricValues {
+		total += val.MetricValue
+	}
+	return total, nil
+}
+
+// refreshStatus fills in the parts of httpso's status that change without
+// httpso changing: the replica counts of its targets and interceptor, its
+// pending requests, and the names of the objects created for it. It
+// doesn't save the status.
+//
+// Every count is best-effort. If one can't be read, the error is logged
+// and the count is left as it was
+func refreshStatus(
+	ctx context.Context,
+	appInfo config.AppInfo,
+	cl client.Client,
+	logger logr.Logger,
+	pendingRequests pendingRequestsFunc,
+	httpso *v1alpha2.HTTPScaledObject,
+) {
+	now := metav1.Now()
+	// the targets haven't been counted before if there are no
+	// resources in the status yet, so a count above zero doesn't mean
+	// they just scaled up
+	refreshed := httpso.Status.Resources != nil
+
+	var appReplicas int32
+	appErr := false
+	for _, target := range httpso.Spec.Targets {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion(target.TargetAPIVersion())
+		obj.SetKind(target.TargetKind())
+		if err := cl.Get(ctx, client.ObjectKey{
+			Namespace: httpso.Namespace,
+			Name:      target.Name,
+		}, obj); err != nil {
+			logger.Error(err, "Getting target for status", "target", target.Name)
+			appErr = true
+			continue
+		}
+		replicas, _, err := unstructured.NestedInt64(obj.Object, "status", "replicas")
+		if err != nil {
+			logger.Error(err, "Reading target replicas for status", "target", target.Name)
+			appErr = true
+			continue
+		}
+		appReplicas += int32(replicas)
+	}
+	if !appErr {
+		if refreshed && httpso.Status.AppReplicas == 0 && appReplicas > 0 {
+			httpso.Status.LastScaledFromZeroTime = &now
+		}
+		httpso.Status.AppReplicas = appReplicas
+	}
+
+	interceptor := &appsv1.Deployment{}
+	if err := cl.Get(ctx, client.ObjectKey{
+		Namespace: appInfo.Namespace,
+		Name:      appInfo.InterceptorDeploymentName(),
+	}, interceptor); err != nil {
+		logger.Error(err, "Getting interceptor deployment for status")
+	} else {
+		httpso.Status.InterceptorReplicas = interceptor.Status.Replicas
+	}
+
+	// only ask for this HTTPScaledObject's routes, the same way its
+	// ScaledObjects do
+	if pending, err := pendingRequests(ctx, appInfo.ExternalScalerHostName(), routeKeys(httpso)); err != nil {
+		logger.Error(err, "Getting pending requests for status")
+	} else {
+		httpso.Status.PendingRequests = pending
+	}
+
+	httpso.Status.LastRefreshTime = &now
+	httpso.Status.ProxyURL = fmt.Sprintf(
+		"http://%s.%s.svc.cluster.local:%d",
+		appInfo.InterceptorProxyServiceName(),
+		appInfo.Namespace,
+		interceptorProxyServicePort,
+	)
+	httpso.Status.Resources = generatedResources(appInfo, httpso)
+}
+
+// refreshStatuses refreshes the status of every HTTPScaledObject with
+// refreshStatus and saves it, without reconciling the HTTPScaledObjects.
+// HTTPScaledObjects that are being deleted or have no targets are
+// skipped, since there's nothing to count for them
+func (rec *HTTPScaledObjectReconciler) refreshStatuses(ctx context.Context) {
+	logger := rec.Log.WithName("status")
+	list := &v1alpha2.HTTPScaledObjectList{}
+	if err := rec.Client.List(ctx, list); err != nil {
+		logger.Error(err, "Listing HTTPScaledObjects to refresh their status")
+		return
+	}
+	for i := range list.Items {
+		httpso := &list.Items[i]
+		if httpso.GetDeletionTimestamp() != nil || len(httpso.Spec.Targets) == 0 {
+			continue
+		}
+		httpsoLogger := logger.WithValues(
+			"HTTPScaledObject.Namespace",
+			httpso.Namespace,
+			"HTTPScaledObject.Name",
+			httpso.Name,
+		)
+		refreshStatus(
+			ctx,
+			rec.appInfo(httpso, httpso.Namespace),
+			rec.Client,
+			httpsoLogger,
+			rec.pendingRequests,
+			httpso,
+		)
+		// a reconcile that updated httpso since it was listed has
+		// refreshed its status already
+		if err := rec.Client.Status().Update(ctx, httpso); err != nil && !errors.IsConflict(err) {
+			httpsoLogger.Error(err, "Saving refreshed status")
+		}
+	}
+}
+
+// startStatusRefreshLoop calls refreshStatuses every interval, until ctx
+// is done. It runs apart from reconciles, so refreshing the status
+// doesn't hold up the reconcile worker or reapply every object that the
+// operator generates.
+//
+// This function blocks until ctx is done, so you'll usually want to call
+// it in a goroutine
+func (rec *HTTPScaledObjectReconciler) startStatusRefreshLoop(
+	ctx context.Context,
+	interval time.Duration,
+) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			rec.refreshStatuses(ctx)
+		}
+	}
+}
+
+// generatedResources returns the names of the objects that the operator
+// creates for httpso
+func generatedResources(
+	appInfo config.AppInfo,
+	httpso *v1alpha2.HTTPScaledObject,
+) *v1alpha2.GeneratedResources {
+	ret := &v1alpha2.GeneratedResources{
+		InterceptorDeployment: appInfo.InterceptorDeploymentName(),
+		InterceptorServices: []string{
+			appInfo.InterceptorAdminServiceName(),
+			appInfo.InterceptorProxyServiceName(),
+		},
+		ExternalScalerDeployment: appInfo.ExternalScalerDeploymentName(),
+		ExternalScalerService:    appInfo.ExternalScalerServiceName(),
+		RoutingTableConfigMap:    appInfo.RoutingTableConfigMapName(),
+	}
+	for _, target := range httpso.Spec.Targets {
+		ret.ScaledObjects = append(ret.ScaledObjects, config.AppScaledObjectName(target))
+	}
+	ret.ScaledObjects = append(ret.ScaledObjects, appInfo.InterceptorScaledObjectName())
+	if httpso.Spec.Ingress != nil {
+		ret.Ingress = config.IngressName(httpso)
+	}
+	return ret
+}
@@ -0,0 +1,146 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kedacore/http-add-on/operator/api/v1alpha2"
+	"github.com/kedacore/http-add-on/operator/controllers/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Status", func() {
+	Context("Refreshing the status", func() {
+		var testInfra *commonTestInfra
+		var pendingRoutes []string
+		pending := func(_ context.Context, _ string, routes []string) (int64, error) {
+			pendingRoutes = routes
+			return 12, nil
+		}
+		createDepl := func(name string, replicas int32) *appsv1.Deployment {
+			depl := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testInfra.ns, Name: name},
+				Status:     appsv1.DeploymentStatus{Replicas: replicas},
+			}
+			Expect(testInfra.cl.Create(testInfra.ctx, depl)).To(BeNil())
+			return depl
+		}
+		refresh := func(pendingRequests pendingRequestsFunc) {
+			refreshStatus(
+				testInfra.ctx,
+				testInfra.cfg,
+				testInfra.cl,
+				testInfra.logger,
+				pendingRequests,
+				&testInfra.httpso,
+			)
+		}
+		BeforeEach(func() {
+			testInfra = newCommonTestInfra("testns", "testapp")
+			pendingRoutes = nil
+		})
+
+		It("Should fill in the counts and resource names", func() {
+			createDepl(testInfra.appName, 3)
+			createDepl(testInfra.cfg.InterceptorDeploymentName(), 2)
+			refresh(pending)
+
+			status := testInfra.httpso.Status
+			Expect(status.AppReplicas).To(Equal(int32(3)))
+			Expect(status.InterceptorReplicas).To(Equal(int32(2)))
+			Expect(status.PendingRequests).To(Equal(int64(12)))
+			// the HTTPScaledObject has no hosts or paths, so its one
+			// route is any path on any host
+			Expect(pendingRoutes).To(Equal([]string{"*"}))
+			Expect(status.LastRefreshTime).To(Not(BeNil()))
+			// the targets weren't counted before, so they didn't just
+			// scale up from zero
+			Expect(status.LastScaledFromZeroTime).To(BeNil())
+			Expect(status.ProxyURL).To(Equal(fmt.Sprintf(
+				"http://%s.testns.svc.cluster.local:80",
+				testInfra.cfg.InterceptorProxyServiceName(),
+			)))
+			Expect(status.Resources).To(Equal(&v1alpha2.GeneratedResources{
+				InterceptorDeployment: testInfra.cfg.InterceptorDeploymentName(),
+				InterceptorServices: []string{
+					testInfra.cfg.InterceptorAdminServiceName(),
+					testInfra.cfg.InterceptorProxyServiceName(),
+				},
+				ExternalScalerDeployment: testInfra.cfg.ExternalScalerDeploymentName(),
+				ExternalScalerService:    testInfra.cfg.ExternalScalerServiceName(),
+				RoutingTableConfigMap:    testInfra.cfg.RoutingTableConfigMapName(),
+				ScaledObjects: []string{
+					config.AppScaledObjectName(testInfra.httpso.Spec.Targets[0]),
+					testInfra.cfg.InterceptorScaledObjectName(),
+				},
+			}))
+		})
+
+		It("Should record scaling up from zero", func() {
+			depl := createDepl(testInfra.appName, 0)
+			refresh(pending)
+			Expect(testInfra.httpso.Status.AppReplicas).To(Equal(int32(0)))
+			Expect(testInfra.httpso.Status.LastScaledFromZeroTime).To(BeNil())
+
+			depl.Status.Replicas = 1
+			Expect(testInfra.cl.Update(testInfra.ctx, depl)).To(BeNil())
+			refresh(pending)
+			Expect(testInfra.httpso.Status.AppReplicas).To(Equal(int32(1)))
+			Expect(testInfra.httpso.Status.LastScaledFromZeroTime).To(Not(BeNil()))
+		})
+
+		It("Should keep the old counts when they can't be read", func() {
+			testInfra.httpso.Status.AppReplicas = 4
+			testInfra.httpso.Status.PendingRequests = 7
+			refresh(func(context.Context, string, []string) (int64, error) {
+				return 0, fmt.Errorf("scaler unavailable")
+			})
+			Expect(testInfra.httpso.Status.AppReplicas).To(Equal(int32(4)))
+			Expect(testInfra.httpso.Status.PendingRequests).To(Equal(int64(7)))
+		})
+
+		It("Should only ask for the HTTPScaledObject's routes", func() {
+			testInfra.httpso.Spec.Hosts = []string{"testapp.com"}
+			testInfra.httpso.Spec.Paths = []v1alpha2.PathRule{{Prefix: "/api"}}
+			testInfra.cfg.Name = config.SharedName
+			testInfra.cfg.InterceptorConfig.Shared = true
+			refresh(pending)
+			Expect(pendingRoutes).To(Equal([]string{"testapp.com/api"}))
+			Expect(testInfra.httpso.Status.Resources.RoutingTableConfigMap).
+				To(Equal(testInfra.cfg.RoutingTableConfigMapName()))
+		})
+
+		It("Should refresh and save every HTTPScaledObject's status without reconciling", func() {
+			Expect(testInfra.cl.Create(testInfra.ctx, &testInfra.httpso)).To(BeNil())
+			noTargets := testInfra.httpso.DeepCopy()
+			noTargets.ObjectMeta = metav1.ObjectMeta{Namespace: testInfra.ns, Name: "notargets"}
+			noTargets.Spec.Targets = nil
+			Expect(testInfra.cl.Create(testInfra.ctx, noTargets)).To(BeNil())
+			createDepl(testInfra.appName, 3)
+
+			rec := &HTTPScaledObjectReconciler{
+				Client:          testInfra.cl,
+				Log:             testInfra.logger,
+				pendingRequests: pending,
+			}
+			rec.refreshStatuses(testInfra.ctx)
+
+			saved := &v1alpha2.HTTPScaledObject{}
+			Expect(testInfra.cl.Get(testInfra.ctx, client.ObjectKeyFromObject(&testInfra.httpso), saved)).To(BeNil())
+			Expect(saved.Status.AppReplicas).To(Equal(int32(3)))
+			Expect(saved.Status.PendingRequests).To(Equal(int64(12)))
+			Expect(saved.Status.LastRefreshTime).To(Not(BeNil()))
+			// refreshing doesn't apply anything
+			Expect(saved.Status.Conditions).To(BeEmpty())
+
+			// there's nothing to count for an HTTPScaledObject with no targets
+			saved = &v1alpha2.HTTPScaledObject{}
+			Expect(testInfra.cl.Get(testInfra.ctx, client.ObjectKeyFromObject(noTargets), saved)).To(BeNil())
+			Expect(saved.Status.LastRefreshTime).To(BeNil())
+		})
+	})
+})
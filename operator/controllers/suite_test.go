@@ -24,6 +24,8 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/kubernetes/scheme"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
@@ -31,8 +33,7 @@ import (
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
-	"github.com/kedacore/http-add-on/operator/api/v1alpha1"
-	httpv1alpha1 "github.com/kedacore/http-add-on/operator/api/v1alpha1"
+	"github.com/kedacore/http-add-on/operator/api/v1alpha2"
 	"github.com/kedacore/http-add-on/operator/controllers/config"
 	// +kubebuilder:scaffold:imports
 )
@@ -59,7 +60,7 @@ type commonTestInfra struct {
 	cl      client.Client
 	cfg     config.AppInfo
 	logger  logr.Logger
-	httpso  v1alpha1.HTTPScaledObject
+	httpso  v1alpha2.HTTPScaledObject
 }
 
 func newCommonTestInfra(namespace, appName string) *commonTestInfra {
@@ -68,22 +69,31 @@ func newCommonTestInfra(namespace, appName string) *commonTestInfra {
 	cfg := config.AppInfo{
 		Name:      appName,
 		Namespace: namespace,
+		InterceptorConfig: config.Interceptor{
+			MinReplicas:           1,
+			MaxReplicas:           100,
+			TargetPendingRequests: 100,
+		},
 	}
 	logger := logrtest.NullLogger{}
-	httpso := v1alpha1.HTTPScaledObject{
+	httpso := v1alpha2.HTTPScaledObject{
 		ObjectMeta: metav1.ObjectMeta{
 			Namespace: namespace,
 			Name:      appName,
 		},
-		Spec: v1alpha1.HTTPScaledObjectSpec{
-			ScaleTargetRef: &v1alpha1.ScaleTargetRef{
-				Deployment: appName,
-				Service:    appName,
-				Port:       8081,
+		Spec: v1alpha2.HTTPScaledObjectSpec{
+			Targets: []v1alpha2.TargetRef{
+				{
+					Name:    appName,
+					Service: appName,
+					Port:    8081,
+				},
 			},
-			Replicas: httpv1alpha1.ReplicaStruct{
-				Min: 0,
-				Max: 20,
+			ScalingPolicy: v1alpha2.ScalingPolicy{
+				Replicas: v1alpha2.ReplicaStruct{
+					Min: 0,
+					Max: 20,
+				},
 			},
 		},
 	}
@@ -119,9 +129,18 @@ var _ = BeforeSuite(func(done Done) {
 	// Expect(err).ToNot(HaveOccurred())
 	// Expect(cfg).ToNot(BeNil())
 
-	err = httpv1alpha1.AddToScheme(scheme.Scheme)
+	err = v1alpha2.AddToScheme(scheme.Scheme)
 	Expect(err).NotTo(HaveOccurred())
 
+	// the fake client can only list KEDA ScaledObjects if it knows about
+	// them. a real client doesn't need this to list unstructured objects
+	kedaGV := schema.GroupVersion{Group: "keda.sh", Version: "v1alpha1"}
+	scheme.Scheme.AddKnownTypeWithName(kedaGV.WithKind("ScaledObject"), &unstructured.Unstructured{})
+	scheme.Scheme.AddKnownTypeWithName(kedaGV.WithKind("ScaledObjectList"), &unstructured.UnstructuredList{})
+	// and the same goes for Gateway API HTTPRoutes
+	scheme.Scheme.AddKnownTypeWithName(httpRouteGVK, &unstructured.Unstructured{})
+	scheme.Scheme.AddKnownTypeWithName(httpRouteGVK.GroupVersion().WithKind("HTTPRouteList"), &unstructured.UnstructuredList{})
+
 	// +kubebuilder:scaffold:scheme
 
 	// k8sClient, err = client.New(cfg, client.Options{Scheme: scheme.Scheme})
@@ -18,15 +18,23 @@ package main
 
 import (
 	"flag"
+	"net"
 	"os"
+	"strconv"
+	"time"
+	// the webhook checks pre-warming windows' time zones, and the
+	// distroless image has no time zone database
+	_ "time/tzdata"
 
 	"k8s.io/apimachinery/pkg/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	httpv1alpha1 "github.com/kedacore/http-add-on/operator/api/v1alpha1"
+	httpv1alpha2 "github.com/kedacore/http-add-on/operator/api/v1alpha2"
 	"github.com/kedacore/http-add-on/operator/controllers"
 	"github.com/kedacore/http-add-on/operator/controllers/config"
 	// +kubebuilder:scaffold:imports
@@ -41,26 +49,39 @@ func init() {
 	_ = clientgoscheme.AddToScheme(scheme)
 
 	_ = httpv1alpha1.AddToScheme(scheme)
+	_ = httpv1alpha2.AddToScheme(scheme)
 	// +kubebuilder:scaffold:scheme
 }
 
 func main() {
 	var metricsAddr string
+	var probeAddr string
 	var enableLeaderElection bool
+	var enableWebhooks bool
+	var statusRefreshInterval time.Duration
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
+	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081",
+		"The address the /healthz and /readyz probe endpoints bind to.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.BoolVar(&enableWebhooks, "enable-webhooks", false,
+		"Serve the defaulting, validating and conversion webhooks for HTTPScaledObjects. "+
+			"Enabling this requires a serving certificate in /tmp/k8s-webhook-server/serving-certs.")
+	flag.DurationVar(&statusRefreshInterval, "status-refresh-interval", 30*time.Second,
+		"How often the replica and pending request counts in each HTTPScaledObject's status are refreshed. "+
+			"0 disables periodic refreshes.")
 	flag.Parse()
 
 	ctrl.SetLogger(zap.New(zap.UseDevMode(true)))
 
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme:             scheme,
-		MetricsBindAddress: metricsAddr,
-		Port:               9443,
-		LeaderElection:     enableLeaderElection,
-		LeaderElectionID:   "f8508ff1.keda.sh",
+		Scheme:                 scheme,
+		MetricsBindAddress:     metricsAddr,
+		HealthProbeBindAddress: probeAddr,
+		Port:                   9443,
+		LeaderElection:         enableLeaderElection,
+		LeaderElectionID:       "f8508ff1.keda.sh",
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
@@ -78,17 +99,42 @@ func main() {
 		os.Exit(1)
 	}
 	if err = (&controllers.HTTPScaledObjectReconciler{
-		Client:               mgr.GetClient(),
-		Log:                  ctrl.Log.WithName("controllers").WithName("HTTPScaledObject"),
-		Scheme:               mgr.GetScheme(),
-		InterceptorConfig:    *interceptorCfg,
-		ExternalScalerConfig: *externalScalerCfg,
+		Client:                mgr.GetClient(),
+		Log:                   ctrl.Log.WithName("controllers").WithName("HTTPScaledObject"),
+		Scheme:                mgr.GetScheme(),
+		Recorder:              mgr.GetEventRecorderFor("keda-http-add-on"),
+		InterceptorConfig:     *interceptorCfg,
+		ExternalScalerConfig:  *externalScalerCfg,
+		StatusRefreshInterval: statusRefreshInterval,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "HTTPScaledObject")
 		os.Exit(1)
 	}
+	if enableWebhooks {
+		if err = httpv1alpha2.SetupWebhookWithManager(mgr, interceptorCfg.Shared); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "HTTPScaledObject")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
+	if err := mgr.AddHealthzCheck("ping", healthz.Ping); err != nil {
+		setupLog.Error(err, "unable to set up health check")
+		os.Exit(1)
+	}
+	if err := mgr.AddReadyzCheck("cache-sync", controllers.CacheSyncCheck(mgr.GetCache())); err != nil {
+		setupLog.Error(err, "unable to set up ready check", "check", "cache-sync")
+		os.Exit(1)
+	}
+	if enableWebhooks {
+		srv := mgr.GetWebhookServer()
+		webhookAddr := net.JoinHostPort(srv.Host, strconv.Itoa(srv.Port))
+		if err := mgr.AddReadyzCheck("webhook", controllers.WebhookCheck(webhookAddr)); err != nil {
+			setupLog.Error(err, "unable to set up ready check", "check", "webhook")
+			os.Exit(1)
+		}
+	}
+
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
 		setupLog.Error(err, "problem running manager")
@@ -1,14 +1,25 @@
 package http
 
-import "sync"
+import (
+	"strings"
+	"sync"
+)
 
 // QueueCountReader represents the size of a virtual HTTP queue, possibly
 // distributed across multiple HTTP server processes. It only can access
 // the current size of the queue, not any other information about requests.
 //
+// The queue is split into separate counts, each identified by a key. The
+// interceptor keys counts by the host and route that requests were sent to.
+//
 // It is concurrency safe.
 type QueueCountReader interface {
-	Current() (int, error)
+	// Current returns the current size of the queue for key
+	Current(key string) (int, error)
+	// Snapshot returns the current size of the queue for every key that
+	// has a non-zero count. The returned map is a copy, so callers are
+	// free to modify it
+	Snapshot() (map[string]int, error)
 }
 
 // QueueCounter represents a virtual HTTP queue, possibly distributed across
@@ -20,39 +31,103 @@ type QueueCountReader interface {
 // the read functionality is point-in-time only
 type QueueCounter interface {
 	QueueCountReader
-	Resize(int) error
+	// Resize changes the size of the queue for key by delta
+	Resize(key string, delta int) error
+}
+
+// Total returns the sum of all the counts in counts
+func Total(counts map[string]int) int {
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+	return total
+}
+
+// KeyIsForHost returns whether key is host, or is a route under host
+// (i.e. host followed by a path)
+func KeyIsForHost(key, host string) bool {
+	return key == host || strings.HasPrefix(key, host+"/")
+}
+
+// TotalForHost returns the sum of all the counts in counts whose key is
+// for host. See KeyIsForHost for how keys are matched to hosts
+func TotalForHost(counts map[string]int, host string) int {
+	total := 0
+	for key, count := range counts {
+		if KeyIsForHost(key, host) {
+			total += count
+		}
+	}
+	return total
+}
+
+// KeyIsForRoute returns whether key is for route. A route is a host
+// followed by a path prefix, in the same form as the keys themselves, and
+// only matches keys for exactly that host and path prefix, not keys for
+// longer path prefixes under it. Its host can be "*", to match keys for
+// the path prefix under any host
+func KeyIsForRoute(key, route string) bool {
+	if key == route {
+		return true
+	}
+	if !strings.HasPrefix(route, "*") {
+		return false
+	}
+	path := ""
+	if i := strings.Index(key, "/"); i >= 0 {
+		path = key[i:]
+	}
+	return path == route[1:]
 }
 
 // MemoryQueue is a reference QueueCounter implementation that holds the
 // HTTP queue in memory only. Always use NewMemoryQueue to create one
 // of these.
 type MemoryQueue struct {
-	count   int
-	mut *sync.RWMutex
+	counts map[string]int
+	mut    *sync.RWMutex
 }
 
 // NewMemoryQueue creates a new empty memory queue
 func NewMemoryQueue() *MemoryQueue {
 	lock := new(sync.RWMutex)
 	return &MemoryQueue{
-		count: 0,
-		mut: lock,
+		counts: make(map[string]int),
+		mut:    lock,
 	}
 }
 
-// Resize changes the size of the queue. Further calls to Current() return
-// the newly calculated size if no other Resize() calls were made in the
-// interim.
-func (r *MemoryQueue) Resize(delta int) error {
+// Resize changes the size of the queue for key. Further calls to Current(key)
+// return the newly calculated size if no other Resize(key, ...) calls were
+// made in the interim.
+//
+// Keys whose count drops to zero are removed, so the queue only holds keys
+// that have requests in flight.
+func (r *MemoryQueue) Resize(key string, delta int) error {
 	r.mut.Lock()
 	defer r.mut.Unlock()
-	r.count += delta
+	r.counts[key] += delta
+	if r.counts[key] == 0 {
+		delete(r.counts, key)
+	}
 	return nil
 }
 
-// Current returns the current size of the queue.
-func (r *MemoryQueue) Current() (int, error) {
+// Current returns the current size of the queue for key.
+func (r *MemoryQueue) Current(key string) (int, error) {
 	r.mut.RLock()
 	defer r.mut.RUnlock()
-	return r.count, nil
+	return r.counts[key], nil
+}
+
+// Snapshot returns a copy of the current size of the queue for every key
+func (r *MemoryQueue) Snapshot() (map[string]int, error) {
+	r.mut.RLock()
+	defer r.mut.RUnlock()
+	ret := make(map[string]int, len(r.counts))
+	for key, count := range r.counts {
+		ret[key] = count
+	}
+	return ret, nil
 }
@@ -0,0 +1,62 @@
+package http
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryQueue(t *testing.T) {
+	r := require.New(t)
+	q := NewMemoryQueue()
+
+	r.NoError(q.Resize("host1.com", 2))
+	r.NoError(q.Resize("host2.com/api", 3))
+	r.NoError(q.Resize("host1.com", -1))
+
+	cur, err := q.Current("host1.com")
+	r.NoError(err)
+	r.Equal(1, cur)
+	cur, err = q.Current("nohost.com")
+	r.NoError(err)
+	r.Equal(0, cur)
+
+	snapshot, err := q.Snapshot()
+	r.NoError(err)
+	r.Equal(map[string]int{"host1.com": 1, "host2.com/api": 3}, snapshot)
+
+	// keys that drop to zero shouldn't show up in snapshots
+	r.NoError(q.Resize("host1.com", -1))
+	snapshot, err = q.Snapshot()
+	r.NoError(err)
+	r.Equal(map[string]int{"host2.com/api": 3}, snapshot)
+}
+
+func TestTotals(t *testing.T) {
+	r := require.New(t)
+	counts := map[string]int{
+		"host1.com":       1,
+		"host1.com/api":   2,
+		"host1.community": 4,
+		"host2.com":       8,
+	}
+	r.Equal(15, Total(counts))
+	r.Equal(3, TotalForHost(counts, "host1.com"))
+	r.Equal(8, TotalForHost(counts, "host2.com"))
+	r.Equal(0, TotalForHost(counts, "host3.com"))
+}
+
+func TestKeyIsForRoute(t *testing.T) {
+	r := require.New(t)
+	r.True(KeyIsForRoute("host1.com", "host1.com"))
+	r.True(KeyIsForRoute("host1.com/api", "host1.com/api"))
+	// routes only match their own path prefix, not the ones under it
+	r.False(KeyIsForRoute("host1.com/api", "host1.com"))
+	r.False(KeyIsForRoute("host1.com/api/v2", "host1.com/api"))
+	r.False(KeyIsForRoute("host2.com/api", "host1.com/api"))
+	// and a * host matches any host
+	r.True(KeyIsForRoute("host1.com/api", "*/api"))
+	r.True(KeyIsForRoute("host2.com", "*"))
+	r.False(KeyIsForRoute("host1.com/api", "*"))
+	r.False(KeyIsForRoute("host1.com/admin", "*/api"))
+}
@@ -0,0 +1,28 @@
+package k8s
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NewConfigMap creates a new ConfigMap object in memory with the given
+// labels and data. This function operates in memory only and doesn't do
+// any I/O whatsoever.
+func NewConfigMap(
+	namespace,
+	name string,
+	labels map[string]string,
+	data map[string]string,
+) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "ConfigMap",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+			Labels:    labels,
+		},
+		Data: data,
+	}
+}
@@ -4,80 +4,155 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
 	typedappsv1 "k8s.io/client-go/kubernetes/typed/apps/v1"
+	"k8s.io/client-go/tools/cache"
 )
 
+// DeploymentCache is a cache of the deployments in a namespace, which
+// can be watched for changes to a single deployment
 type DeploymentCache interface {
 	Get(name string) (*appsv1.Deployment, error)
 	Watch(name string) watch.Interface
 }
 
+// deploymentCacheResyncPeriod is how often K8sDeploymentCache re-sends
+// events for every deployment it holds to its watchers, so that watchers
+// that missed an event eventually catch up
+const deploymentCacheResyncPeriod = 5 * time.Minute
+
+// deploymentNameIndex is the name of the informer index that holds
+// deployments by name
+const deploymentNameIndex = "name"
+
+// K8sDeploymentCache is a DeploymentCache backed by a shared informer, so
+// it re-establishes its watch from the last resourceVersion it saw when
+// the API server closes it, and re-lists when that resourceVersion is too
+// old. Use NewK8sDeploymentCache to create one of these.
 type K8sDeploymentCache struct {
-	latestEvts  map[string]watch.Event
-	rwm         *sync.RWMutex
+	informer    cache.SharedIndexInformer
 	broadcaster *watch.Broadcaster
+	rwm         *sync.RWMutex
+	// lastContact is the last time that a list or watch event (including
+	// bookmarks) came back from the API server
+	lastContact time.Time
+	// lastWatchErr is the last time that the watch failed
+	lastWatchErr time.Time
 }
 
+// NewK8sDeploymentCache creates a new K8sDeploymentCache for the
+// deployments that cl can access, and starts keeping it up to date.
+// It waits until the cache has the current state of all the deployments,
+// and returns an error if ctx is done before that happens.
+//
+// The cache stops updating when ctx is done
 func NewK8sDeploymentCache(
 	ctx context.Context,
 	cl typedappsv1.DeploymentInterface,
 ) (*K8sDeploymentCache, error) {
-	deployList, err := cl.List(ctx, metav1.ListOptions{})
-	if err != nil {
-		return nil, err
+	ret := &K8sDeploymentCache{
+		broadcaster: watch.NewBroadcaster(5, watch.DropIfChannelFull),
+		rwm:         new(sync.RWMutex),
 	}
-
-	latestEvts := map[string]watch.Event{}
-	for _, depl := range deployList.Items {
-		latestEvts[depl.ObjectMeta.Name] = watch.Event{
-			Type:   watch.Added,
-			Object: &depl,
-		}
+	lw := &cache.ListWatch{
+		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+			list, err := cl.List(ctx, opts)
+			if err == nil {
+				ret.markContact()
+			}
+			return list, err
+		},
+		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+			// the informer always asks for bookmarks in opts, so it can
+			// resume from a recent resourceVersion even if no deployments
+			// change for a long time. we count every event, including
+			// bookmarks, as contact with the API server
+			watcher, err := cl.Watch(ctx, opts)
+			if err != nil {
+				return nil, err
+			}
+			return watch.Filter(watcher, func(evt watch.Event) (watch.Event, bool) {
+				ret.markContact()
+				return evt, true
+			}), nil
+		},
 	}
-	bcaster := watch.NewBroadcaster(5, watch.DropIfChannelFull)
-	watcher, err := cl.Watch(ctx, metav1.ListOptions{})
-	if err != nil {
+	ret.informer = cache.NewSharedIndexInformer(
+		lw,
+		&appsv1.Deployment{},
+		deploymentCacheResyncPeriod,
+		cache.Indexers{
+			deploymentNameIndex: func(obj interface{}) ([]string, error) {
+				depl, ok := obj.(*appsv1.Deployment)
+				if !ok {
+					return nil, fmt.Errorf("expected a deployment, got a %T", obj)
+				}
+				return []string{depl.ObjectMeta.Name}, nil
+			},
+		},
+	)
+	if err := ret.informer.SetWatchErrorHandler(func(r *cache.Reflector, err error) {
+		ret.rwm.Lock()
+		ret.lastWatchErr = time.Now()
+		ret.rwm.Unlock()
+		cache.DefaultWatchErrorHandler(r, err)
+	}); err != nil {
 		return nil, err
 	}
-
-	ret := &K8sDeploymentCache{
-		latestEvts:  latestEvts,
-		rwm:         new(sync.RWMutex),
-		broadcaster: bcaster,
-	}
-	go func() {
-		defer watcher.Stop()
-		ch := watcher.ResultChan()
-		for {
-			// TODO: add a timeout
-			evt := <-ch
-			ret.broadcaster.Action(evt.Type, evt.Object)
-			ret.rwm.Lock()
-			depl, ok := evt.Object.(*appsv1.Deployment)
-			// if we didn't get back a deployment in the event,
-			// something is wrong that we can't fix, so just continue
-			if !ok {
-				continue
+	ret.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			ret.broadcast(watch.Added, obj)
+		},
+		UpdateFunc: func(_, newObj interface{}) {
+			ret.broadcast(watch.Modified, newObj)
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
 			}
-			ret.latestEvts[depl.GetObjectMeta().GetName()] = evt
-			ret.rwm.Unlock()
-		}
-	}()
+			ret.broadcast(watch.Deleted, obj)
+		},
+	})
+	go ret.informer.Run(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), ret.informer.HasSynced) {
+		return nil, fmt.Errorf("deployment cache didn't sync (%s)", ctx.Err())
+	}
 	return ret, nil
 }
 
-func (k *K8sDeploymentCache) Get(name string) (*appsv1.Deployment, error) {
-	k.rwm.RLock()
-	defer k.rwm.RUnlock()
-	evt, ok := k.latestEvts[name]
+// markContact records that the API server just responded to the cache
+func (k *K8sDeploymentCache) markContact() {
+	k.rwm.Lock()
+	defer k.rwm.Unlock()
+	k.lastContact = time.Now()
+}
+
+// broadcast sends an event of type evtType for obj to all watchers, if
+// obj is a deployment
+func (k *K8sDeploymentCache) broadcast(evtType watch.EventType, obj interface{}) {
+	depl, ok := obj.(*appsv1.Deployment)
+	// if we didn't get back a deployment in the event,
+	// something is wrong that we can't fix, so just skip it
 	if !ok {
+		return
+	}
+	k.broadcaster.Action(evtType, depl)
+}
+
+func (k *K8sDeploymentCache) Get(name string) (*appsv1.Deployment, error) {
+	objs, err := k.informer.GetIndexer().ByIndex(deploymentNameIndex, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(objs) == 0 {
 		return nil, fmt.Errorf("no deployment %s found", name)
 	}
-	return evt.Object.(*appsv1.Deployment), nil
+	return objs[0].(*appsv1.Deployment), nil
 }
 
 func (k *K8sDeploymentCache) Watch(name string) watch.Interface {
@@ -94,6 +169,30 @@ func (k *K8sDeploymentCache) Watch(name string) watch.Interface {
 	})
 }
 
+// LastContact returns the last time that the cache heard from the API
+// server, either in response to a list or as a watch event or bookmark
+func (k *K8sDeploymentCache) LastContact() time.Time {
+	k.rwm.RLock()
+	defer k.rwm.RUnlock()
+	return k.lastContact
+}
+
+// Stale returns true if the cache may not reflect the current state of
+// the deployments. That's the case if it hasn't synced, if its watch
+// failed and hasn't been re-established since, or if it hasn't heard
+// from the API server in maxAge
+func (k *K8sDeploymentCache) Stale(maxAge time.Duration) bool {
+	if !k.informer.HasSynced() {
+		return true
+	}
+	k.rwm.RLock()
+	defer k.rwm.RUnlock()
+	if k.lastWatchErr.After(k.lastContact) {
+		return true
+	}
+	return time.Since(k.lastContact) > maxAge
+}
+
 // MemoryDeploymentCache is a purely in-memory DeploymentCache implementation.
 //
 // To ensure this is concurrency-safe, be sure to use RWM properly to protect
@@ -10,7 +10,9 @@ import (
 	core "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
 	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 func TestK8DeploymentCacheGet(t *testing.T) {
@@ -112,3 +114,67 @@ func gvrForDeployment(depl *appsv1.Deployment) schema.GroupVersionResource {
 		Resource: "Deployment",
 	}
 }
+
+// Test to make sure the cache re-establishes its watch when the API
+// server closes it, and keeps delivering events afterward
+func TestK8sDeploymentCacheRewatch(t *testing.T) {
+	r := require.New(t)
+	ctx, done := context.WithCancel(context.Background())
+	defer done()
+
+	const ns = "testns"
+	const name = "testdepl"
+	fakeClientset := k8sfake.NewSimpleClientset()
+	watchersCh := make(chan *watch.FakeWatcher, 10)
+	fakeClientset.PrependWatchReactor(
+		"deployments",
+		func(k8stesting.Action) (bool, watch.Interface, error) {
+			watcher := watch.NewFake()
+			watchersCh <- watcher
+			return true, watcher, nil
+		},
+	)
+
+	cache, err := NewK8sDeploymentCache(ctx, fakeClientset.AppsV1().Deployments(ns))
+	r.NoError(err)
+	r.False(cache.Stale(time.Minute))
+
+	nextWatcher := func() *watch.FakeWatcher {
+		select {
+		case watcher := <-watchersCh:
+			return watcher
+		case <-time.After(5 * time.Second):
+			r.FailNow("the cache didn't open a watch")
+			return nil
+		}
+	}
+
+	// the API server closes the first watch
+	nextWatcher().Stop()
+
+	// the cache should open a new one and get events from it
+	deplWatcher := cache.Watch(name)
+	defer deplWatcher.Stop()
+	depl := NewDeployment(
+		ns,
+		name,
+		"testimg",
+		nil,
+		nil,
+		make(map[string]string),
+		core.PullAlways,
+	)
+	nextWatcher().Add(depl)
+	select {
+	case evt := <-deplWatcher.ResultChan():
+		r.Equal(watch.Added, evt.Type)
+		r.Equal(name, evt.Object.(*appsv1.Deployment).ObjectMeta.Name)
+	case <-time.After(time.Second):
+		r.Fail("didn't get a watch event from the new watch")
+	}
+	got, err := cache.Get(name)
+	r.NoError(err)
+	r.Equal(name, got.ObjectMeta.Name)
+	r.False(cache.Stale(time.Minute))
+	r.True(cache.Stale(0))
+}
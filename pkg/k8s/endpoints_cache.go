@@ -0,0 +1,191 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// EndpointsCache is a cache of the endpoints in a namespace, which can be
+// watched for changes to the endpoints of a single service
+type EndpointsCache interface {
+	Get(name string) (*corev1.Endpoints, error)
+	Watch(name string) watch.Interface
+}
+
+// HasReadyAddresses returns whether endpoints has at least one address
+// that's ready to serve requests. Addresses that aren't ready are in
+// NotReadyAddresses instead, so they don't count
+func HasReadyAddresses(endpoints *corev1.Endpoints) bool {
+	for _, subset := range endpoints.Subsets {
+		if len(subset.Addresses) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// endpointsNameIndex is the name of the informer index that holds
+// endpoints by name
+const endpointsNameIndex = "name"
+
+// K8sEndpointsCache is an EndpointsCache backed by a shared informer.
+// Use NewK8sEndpointsCache to create one of these
+type K8sEndpointsCache struct {
+	informer    cache.SharedIndexInformer
+	broadcaster *watch.Broadcaster
+}
+
+// NewK8sEndpointsCache creates a new K8sEndpointsCache for the endpoints
+// that cl can access, and starts keeping it up to date. It waits until
+// the cache has the current state of all the endpoints, and returns an
+// error if ctx is done before that happens.
+//
+// The cache stops updating when ctx is done
+func NewK8sEndpointsCache(
+	ctx context.Context,
+	cl typedcorev1.EndpointsInterface,
+) (*K8sEndpointsCache, error) {
+	ret := &K8sEndpointsCache{
+		broadcaster: watch.NewBroadcaster(5, watch.DropIfChannelFull),
+	}
+	lw := &cache.ListWatch{
+		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+			return cl.List(ctx, opts)
+		},
+		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+			return cl.Watch(ctx, opts)
+		},
+	}
+	ret.informer = cache.NewSharedIndexInformer(
+		lw,
+		&corev1.Endpoints{},
+		deploymentCacheResyncPeriod,
+		cache.Indexers{
+			endpointsNameIndex: func(obj interface{}) ([]string, error) {
+				endpoints, ok := obj.(*corev1.Endpoints)
+				if !ok {
+					return nil, fmt.Errorf("expected endpoints, got a %T", obj)
+				}
+				return []string{endpoints.ObjectMeta.Name}, nil
+			},
+		},
+	)
+	ret.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			ret.broadcast(watch.Added, obj)
+		},
+		UpdateFunc: func(_, newObj interface{}) {
+			ret.broadcast(watch.Modified, newObj)
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			ret.broadcast(watch.Deleted, obj)
+		},
+	})
+	go ret.informer.Run(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), ret.informer.HasSynced) {
+		return nil, fmt.Errorf("endpoints cache didn't sync (%s)", ctx.Err())
+	}
+	return ret, nil
+}
+
+// broadcast sends an event of type evtType for obj to all watchers, if
+// obj is an Endpoints
+func (k *K8sEndpointsCache) broadcast(evtType watch.EventType, obj interface{}) {
+	endpoints, ok := obj.(*corev1.Endpoints)
+	if !ok {
+		return
+	}
+	k.broadcaster.Action(evtType, endpoints)
+}
+
+func (k *K8sEndpointsCache) Get(name string) (*corev1.Endpoints, error) {
+	objs, err := k.informer.GetIndexer().ByIndex(endpointsNameIndex, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(objs) == 0 {
+		return nil, fmt.Errorf("no endpoints %s found", name)
+	}
+	return objs[0].(*corev1.Endpoints), nil
+}
+
+func (k *K8sEndpointsCache) Watch(name string) watch.Interface {
+	watcher := k.broadcaster.Watch()
+	return watch.Filter(watcher, func(evt watch.Event) (watch.Event, bool) {
+		endpoints, ok := evt.Object.(*corev1.Endpoints)
+		if !ok {
+			return evt, false
+		}
+		return evt, endpoints.ObjectMeta.Name == name
+	})
+}
+
+// MemoryEndpointsCache is a purely in-memory EndpointsCache
+// implementation.
+//
+// To ensure this is concurrency-safe, be sure to use RWM properly to
+// protect all accesses to either map in this struct
+type MemoryEndpointsCache struct {
+	// RWM protects all accesses to either of Watchers or Endpoints
+	RWM *sync.RWMutex
+
+	// Watchers holds the watchers to be returned by calls to Watch. If
+	// Watch is called with a name that has no key in this map, it panics
+	Watchers map[string]*watch.RaceFreeFakeWatcher
+
+	// Endpoints holds the endpoints to be returned by calls to Get. If
+	// Get is called with a name that has no key in this map, it returns
+	// an error
+	Endpoints map[string]*corev1.Endpoints
+}
+
+// NewMemoryEndpointsCache creates a new MemoryEndpointsCache with the
+// Endpoints map set to initialEndpoints, and a new FakeWatcher in the
+// Watchers map for each key in initialEndpoints
+func NewMemoryEndpointsCache(
+	initialEndpoints map[string]*corev1.Endpoints,
+) *MemoryEndpointsCache {
+	ret := &MemoryEndpointsCache{
+		RWM:       new(sync.RWMutex),
+		Watchers:  make(map[string]*watch.RaceFreeFakeWatcher),
+		Endpoints: initialEndpoints,
+	}
+	for name := range initialEndpoints {
+		ret.Watchers[name] = watch.NewRaceFreeFake()
+	}
+	return ret
+}
+
+func (m *MemoryEndpointsCache) Get(name string) (*corev1.Endpoints, error) {
+	m.RWM.RLock()
+	defer m.RWM.RUnlock()
+	val, ok := m.Endpoints[name]
+	if !ok {
+		return nil, fmt.Errorf("Endpoints %s not found", name)
+	}
+	return val, nil
+}
+
+func (m *MemoryEndpointsCache) Watch(name string) watch.Interface {
+	m.RWM.RLock()
+	defer m.RWM.RUnlock()
+	val, ok := m.Watchers[name]
+	if !ok {
+		panic(fmt.Sprintf(
+			"(github.com/kedacore/http-add-on/pkg/k8s).MemoryEndpointsCache.Watch(%s) called, but that name doesn't exist in watchers map",
+			name,
+		))
+	}
+	return val
+}
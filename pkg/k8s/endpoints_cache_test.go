@@ -0,0 +1,54 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestK8sEndpointsCacheGetAndWatch(t *testing.T) {
+	r := require.New(t)
+	ctx, done := context.WithCancel(context.Background())
+	defer done()
+
+	const ns = "testns"
+	const name = "testsvc"
+	endpoints := &core.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name},
+		Subsets: []core.EndpointSubset{{
+			NotReadyAddresses: []core.EndpointAddress{{IP: "1.2.3.4"}},
+		}},
+	}
+	fakeEndpoints := k8sfake.NewSimpleClientset(endpoints).CoreV1().Endpoints(ns)
+
+	cache, err := NewK8sEndpointsCache(ctx, fakeEndpoints)
+	r.NoError(err)
+
+	got, err := cache.Get(name)
+	r.NoError(err)
+	// an address that isn't ready doesn't count
+	r.False(HasReadyAddresses(got))
+	_, err = cache.Get(name + "noexist")
+	r.Error(err)
+
+	watcher := cache.Watch(name)
+	defer watcher.Stop()
+	ready := endpoints.DeepCopy()
+	ready.Subsets[0].Addresses = []core.EndpointAddress{{IP: "1.2.3.5"}}
+	_, err = fakeEndpoints.Update(ctx, ready, metav1.UpdateOptions{})
+	r.NoError(err)
+
+	select {
+	case evt := <-watcher.ResultChan():
+		updated, ok := evt.Object.(*core.Endpoints)
+		r.True(ok, "expected endpoints but got a %#V", evt)
+		r.True(HasReadyAddresses(updated))
+	case <-time.After(500 * time.Millisecond):
+		r.Fail("didn't get a watch event after 500 ms")
+	}
+}
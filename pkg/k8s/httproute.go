@@ -0,0 +1,61 @@
+package k8s
+
+import (
+	"bytes"
+	"text/template"
+
+	unstructured "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// NewHTTPRoute creates a new Gateway API gateway.networking.k8s.io/v1
+// HTTPRoute in memory. It attaches to the Gateway called gatewayName in
+// gatewayNamespace (or in namespace, if gatewayNamespace is empty), on
+// the listener called gatewaySectionName if it isn't empty, and routes
+// requests for each of hosts under each of pathPrefixes to port on the
+// service called svcName. If hosts is empty, requests for any host are
+// routed, and if pathPrefixes is empty, all paths are
+func NewHTTPRoute(
+	namespace,
+	name,
+	gatewayName,
+	gatewayNamespace,
+	gatewaySectionName string,
+	hosts,
+	pathPrefixes []string,
+	svcName string,
+	port int32,
+	labels,
+	annotations map[string]string,
+) (*unstructured.Unstructured, error) {
+	if len(pathPrefixes) == 0 {
+		pathPrefixes = []string{"/"}
+	}
+	tpl, err := template.ParseFS(templatesFS, "templates/httproute.yaml")
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, map[string]interface{}{
+		"Name":               name,
+		"Namespace":          namespace,
+		"Labels":             labels,
+		"Annotations":        annotations,
+		"GatewayName":        gatewayName,
+		"GatewayNamespace":   gatewayNamespace,
+		"GatewaySectionName": gatewaySectionName,
+		"Hosts":              hosts,
+		"PathPrefixes":       pathPrefixes,
+		"ServiceName":        svcName,
+		"Port":               port,
+	}); err != nil {
+		return nil, err
+	}
+
+	var decoded map[string]interface{}
+	if err := yaml.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		return nil, err
+	}
+	return &unstructured.Unstructured{Object: decoded}, nil
+}
@@ -0,0 +1,81 @@
+package k8s
+
+import (
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NewIngress creates a new networking.k8s.io/v1 Ingress in memory that
+// routes requests for each of hosts under each of pathPrefixes to port on
+// the service called svcName. If hosts is empty, requests for any host
+// are routed, and if pathPrefixes is empty, all paths are. If
+// tlsSecretName isn't empty, TLS for hosts is terminated with the
+// certificate in that secret. This function operates in memory only and
+// doesn't do any I/O whatsoever.
+func NewIngress(
+	namespace,
+	name,
+	className string,
+	hosts,
+	pathPrefixes []string,
+	tlsSecretName,
+	svcName string,
+	port int32,
+	labels,
+	annotations map[string]string,
+) *networkingv1.Ingress {
+	if len(pathPrefixes) == 0 {
+		pathPrefixes = []string{"/"}
+	}
+	pathType := networkingv1.PathTypePrefix
+	var paths []networkingv1.HTTPIngressPath
+	for _, prefix := range pathPrefixes {
+		paths = append(paths, networkingv1.HTTPIngressPath{
+			Path:     prefix,
+			PathType: &pathType,
+			Backend: networkingv1.IngressBackend{
+				Service: &networkingv1.IngressServiceBackend{
+					Name: svcName,
+					Port: networkingv1.ServiceBackendPort{Number: port},
+				},
+			},
+		})
+	}
+	ruleValue := networkingv1.IngressRuleValue{
+		HTTP: &networkingv1.HTTPIngressRuleValue{Paths: paths},
+	}
+	var rules []networkingv1.IngressRule
+	if len(hosts) == 0 {
+		rules = []networkingv1.IngressRule{{IngressRuleValue: ruleValue}}
+	}
+	for _, host := range hosts {
+		rules = append(rules, networkingv1.IngressRule{
+			Host:             host,
+			IngressRuleValue: ruleValue,
+		})
+	}
+
+	ingress := &networkingv1.Ingress{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "Ingress",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   namespace,
+			Name:        name,
+			Labels:      labels,
+			Annotations: annotations,
+		},
+		Spec: networkingv1.IngressSpec{
+			Rules: rules,
+		},
+	}
+	if className != "" {
+		ingress.Spec.IngressClassName = &className
+	}
+	if tlsSecretName != "" {
+		ingress.Spec.TLS = []networkingv1.IngressTLS{
+			{Hosts: hosts, SecretName: tlsSecretName},
+		}
+	}
+	return ingress
+}
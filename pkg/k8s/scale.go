@@ -0,0 +1,133 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/pkg/errors"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/scale"
+)
+
+// ScaleGetter gets the /scale subresource of any resource that has one,
+// like a Deployment, StatefulSet or Argo Rollout
+type ScaleGetter interface {
+	GetScale(
+		ctx context.Context,
+		apiVersion,
+		kind,
+		name string,
+	) (*autoscalingv1.Scale, error)
+}
+
+// K8sScaleGetter is a ScaleGetter that gets /scale subresources from the
+// Kubernetes API server. Use NewK8sScaleGetter to create one of these
+type K8sScaleGetter struct {
+	ns     string
+	mapper meta.RESTMapper
+	scales scale.ScalesGetter
+}
+
+// NewK8sScaleGetter creates a new K8sScaleGetter that gets /scale
+// subresources for resources in namespace ns. It uses API discovery to
+// find the resource for each kind, so it works with custom resources too
+func NewK8sScaleGetter(cfg *rest.Config, ns string) (*K8sScaleGetter, error) {
+	discoCl, err := discovery.NewDiscoveryClientForConfig(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "Creating discovery client")
+	}
+	cachedDiscoCl := memory.NewMemCacheClient(discoCl)
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(cachedDiscoCl)
+	scales, err := scale.NewForConfig(
+		cfg,
+		mapper,
+		dynamic.LegacyAPIPathResolverFunc,
+		scale.NewDiscoveryScaleKindResolver(cachedDiscoCl),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "Creating scale client")
+	}
+	return &K8sScaleGetter{
+		ns:     ns,
+		mapper: mapper,
+		scales: scales,
+	}, nil
+}
+
+func (k *K8sScaleGetter) GetScale(
+	ctx context.Context,
+	apiVersion,
+	kind,
+	name string,
+) (*autoscalingv1.Scale, error) {
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return nil, err
+	}
+	mapping, err := k.mapper.RESTMapping(
+		schema.GroupKind{Group: gv.Group, Kind: kind},
+		gv.Version,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return k.scales.Scales(k.ns).Get(
+		ctx,
+		mapping.Resource.GroupResource(),
+		name,
+		metav1.GetOptions{},
+	)
+}
+
+// MemoryScaleGetter is a purely in-memory ScaleGetter implementation.
+//
+// To ensure this is concurrency-safe, be sure to use RWM properly to protect
+// all accesses to the Scales map
+type MemoryScaleGetter struct {
+	// RWM protects all accesses to Scales
+	RWM *sync.RWMutex
+
+	// Scales holds the scales to be returned by calls to GetScale, keyed
+	// by MemoryScaleKey. If GetScale is called for a resource that has no
+	// key in this map, it returns an error
+	Scales map[string]*autoscalingv1.Scale
+}
+
+// NewMemoryScaleGetter creates a new MemoryScaleGetter with the Scales map
+// set to initialScales
+func NewMemoryScaleGetter(initialScales map[string]*autoscalingv1.Scale) *MemoryScaleGetter {
+	return &MemoryScaleGetter{
+		RWM:    new(sync.RWMutex),
+		Scales: initialScales,
+	}
+}
+
+// MemoryScaleKey returns the key in MemoryScaleGetter.Scales for the
+// resource with the given API version, kind and name
+func MemoryScaleKey(apiVersion, kind, name string) string {
+	return fmt.Sprintf("%s/%s/%s", apiVersion, kind, name)
+}
+
+func (m *MemoryScaleGetter) GetScale(
+	_ context.Context,
+	apiVersion,
+	kind,
+	name string,
+) (*autoscalingv1.Scale, error) {
+	m.RWM.RLock()
+	defer m.RWM.RUnlock()
+	val, ok := m.Scales[MemoryScaleKey(apiVersion, kind, name)]
+	if !ok {
+		return nil, fmt.Errorf("Scale for %s %s not found", kind, name)
+	}
+	return val, nil
+}
@@ -4,8 +4,11 @@ import (
 	"bytes"
 	"context"
 	"embed"
+	"strings"
 	"text/template"
 
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
+	"k8s.io/apimachinery/pkg/runtime"
 	unstructured "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/yaml"
@@ -13,7 +16,27 @@ import (
 )
 
 //go:embed templates
-var scaledObjectTemplateFS embed.FS
+var templatesFS embed.FS
+
+// DefaultPollingInterval is how often, in seconds, KEDA checks whether to
+// scale a ScaledObject's target from or to zero if ScaledObjectOptions
+// doesn't have a PollingInterval
+const DefaultPollingInterval int32 = 250
+
+// PausedReplicasAnnotation is the annotation that pauses KEDA's scaling
+// of a ScaledObject's target and scales the target to the replica count
+// it's set to. It needs KEDA v2.7 or later
+const PausedReplicasAnnotation = "autoscaling.keda.sh/paused-replicas"
+
+// CronTrigger is a KEDA cron trigger, which keeps a ScaledObject's target
+// at DesiredReplicas or more between Start and End. Start and End are
+// cron schedules in Timezone, an IANA time zone
+type CronTrigger struct {
+	Timezone        string
+	Start           string
+	End             string
+	DesiredReplicas int32
+}
 
 func kedaGVR() schema.GroupVersionResource {
 	return schema.GroupVersionResource{
@@ -40,14 +63,46 @@ func DeleteScaledObject(ctx context.Context, name string, namespace string, cl c
 	return nil
 }
 
-// NewScaledObject creates a new ScaledObject in memory
+// ScaledObjectOptions holds the settings for a ScaledObject that
+// NewScaledObject creates.
+//
+// If Routes isn't empty, the scaler only counts pending requests for
+// those routes, which are queue keys: a host (or "*", for any host)
+// followed by a path prefix. Otherwise, it counts pending requests for
+// all hosts.
+//
+// PollingInterval, CooldownPeriod and Behavior are optional. If
+// PollingInterval is nil, KEDA polls every DefaultPollingInterval
+// seconds. If CooldownPeriod or Behavior is nil, KEDA's and the
+// HorizontalPodAutoscaler's defaults apply.
+//
+// Each of CronTriggers is added as a trigger next to the external
+// scaler's, so the target is scaled to the highest replica count that
+// any of them asks for
+type ScaledObjectOptions struct {
+	MinReplicas           int32
+	MaxReplicas           int32
+	TargetPendingRequests int32
+	Routes                []string
+	PollingInterval       *int32
+	CooldownPeriod        *int32
+	Behavior              *autoscalingv2beta2.HorizontalPodAutoscalerBehavior
+	CronTriggers          []CronTrigger
+}
+
+// NewScaledObject creates a new ScaledObject in memory. The external
+// scaler at scalerAddress will scale the resource with the given
+// scaleTargetAPIVersion, scaleTargetKind and scaleTargetName so that each
+// replica has opts.TargetPendingRequests pending requests. The resource
+// can be of any kind that has a /scale subresource
 func NewScaledObject(
 	namespace,
 	name,
-	deploymentName,
+	scaleTargetAPIVersion,
+	scaleTargetKind,
+	scaleTargetName,
 	scalerAddress string,
-	minReplicas int32,
-	maxReplicas int32,
+	opts ScaledObjectOptions,
 ) (*unstructured.Unstructured, error) {
 	// https://keda.sh/docs/1.5/faq/
 	// https://github.com/kedacore/keda/blob/aa0ea79450a1c7549133aab46f5b916efa2364ab/api/v1alpha1/scaledobject_types.go
@@ -61,7 +116,13 @@ func NewScaledObject(
 		labels[k] = vIface
 	}
 
-	tpl, err := template.ParseFS(scaledObjectTemplateFS, "templates/scaledobject.yaml")
+	pollingInterval := opts.PollingInterval
+	if pollingInterval == nil {
+		defaultInterval := DefaultPollingInterval
+		pollingInterval = &defaultInterval
+	}
+
+	tpl, err := template.ParseFS(templatesFS, "templates/scaledobject.yaml")
 	if err != nil {
 		return nil, err
 	}
@@ -71,10 +132,17 @@ func NewScaledObject(
 		"Name": name,
 		"Namespace": namespace,
 		"Labels": labels,
-		"MinReplicas": minReplicas,
-		"MaxReplicas": maxReplicas,
-		"DeploymentName": deploymentName,
+		"MinReplicas": opts.MinReplicas,
+		"MaxReplicas": opts.MaxReplicas,
+		"ScaleTargetAPIVersion": scaleTargetAPIVersion,
+		"ScaleTargetKind": scaleTargetKind,
+		"ScaleTargetName": scaleTargetName,
 		"ScalerAddress": scalerAddress,
+		"TargetPendingRequests": opts.TargetPendingRequests,
+		"Routes": strings.Join(opts.Routes, ","),
+		"PollingInterval": *pollingInterval,
+		"CooldownPeriod": opts.CooldownPeriod,
+		"CronTriggers": opts.CronTriggers,
 	}); tplErr != nil {
 		return nil, tplErr
 	}
@@ -85,6 +153,25 @@ func NewScaledObject(
 		return nil, decodeErr
 	}
 
+	// the behavior is nested too deep to template nicely, so it's
+	// converted and added to the decoded object instead
+	if opts.Behavior != nil {
+		behaviorMap, err := runtime.DefaultUnstructuredConverter.ToUnstructured(opts.Behavior)
+		if err != nil {
+			return nil, err
+		}
+		if err := unstructured.SetNestedMap(
+			decodedYaml,
+			behaviorMap,
+			"spec",
+			"advanced",
+			"horizontalPodAutoscalerConfig",
+			"behavior",
+		); err != nil {
+			return nil, err
+		}
+	}
+
 	return &unstructured.Unstructured{
 		Object: decodedYaml,
 	}, nil
@@ -25,9 +25,16 @@ type DialContextFunc func(ctx context.Context, network, addr string) (stdnet.Con
 // in the time slice between detecting >=1 replicas and the network send, the connection
 // will be retried a few times.
 //
+// If onRetry isn't nil, it's called with the error of every failed dial
+// that's going to be retried, so callers can count retries.
+//
 // Thanks to KNative for inspiring this code. See GitHub link below
 // https://github.com/knative/serving/blob/20815258c92d0f26100031c71a91d0bef930a475/vendor/knative.dev/pkg/network/transports.go#L70
-func DialContextWithRetry(coreDialer *net.Dialer, backoff wait.Backoff) DialContextFunc {
+func DialContextWithRetry(
+	coreDialer *net.Dialer,
+	backoff wait.Backoff,
+	onRetry func(err error),
+) DialContextFunc {
 	numDialTries := backoff.Steps
 	return func(ctx context.Context, network, addr string) (stdnet.Conn, error) {
 		// note that we could test for backoff.Steps >= 0 here, but every call to backoff.Step()
@@ -42,6 +49,9 @@ func DialContextWithRetry(coreDialer *net.Dialer, backoff wait.Backoff) DialCont
 				return conn, nil
 			}
 			lastError = err
+			if onRetry != nil && i+1 < numDialTries {
+				onRetry(err)
+			}
 			sleepDur := backoff.Step()
 			t := time.NewTimer(sleepDur)
 			select {
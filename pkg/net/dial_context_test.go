@@ -27,7 +27,10 @@ func TestDialContextWithRetry(t *testing.T) {
 
 	ctx := context.Background()
 	dialer := NewNetDialer(connTimeout, keepAlive)
-	dRetry := DialContextWithRetry(dialer, backoff)
+	retries := 0
+	dRetry := DialContextWithRetry(dialer, backoff, func(error) {
+		retries++
+	})
 	minTotalWaitDur := MinTotalBackoffDuration(backoff)
 
 	start := time.Now()
@@ -42,4 +45,6 @@ func TestDialContextWithRetry(t *testing.T) {
 		elapsed,
 		minTotalWaitDur,
 	)
+	// every dial but the last one is retried
+	r.Equal(backoff.Steps-1, retries)
 }
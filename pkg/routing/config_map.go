@@ -0,0 +1,71 @@
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8scorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// ConfigMapRoutingTableKey is the key in a ConfigMap's data under which
+// the JSON-encoded routing table is stored
+const ConfigMapRoutingTableKey = "routing-table"
+
+// FetchTableFromConfigMap decodes the routing table stored in cm.
+// Returns a non-nil error if cm has no routing table in it, or the
+// routing table was invalid
+func FetchTableFromConfigMap(cm *corev1.ConfigMap) (*Table, error) {
+	tableStr, ok := cm.Data[ConfigMapRoutingTableKey]
+	if !ok {
+		return nil, fmt.Errorf(
+			"no key %s in ConfigMap %s",
+			ConfigMapRoutingTableKey,
+			cm.Name,
+		)
+	}
+	ret := NewTable()
+	if err := json.Unmarshal([]byte(tableStr), ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// StartConfigMapUpdateLoop fetches the ConfigMap called name every time
+// ticker fires and replaces the host entries in table with the routing
+// table it holds. Errors fetching or decoding the ConfigMap are logged
+// and table is left as-is, so a bad update never takes routes away.
+//
+// This function blocks until ctx is done, so you'll usually want to call
+// it in a goroutine
+func StartConfigMapUpdateLoop(
+	ctx context.Context,
+	ticker *time.Ticker,
+	cl k8scorev1.ConfigMapInterface,
+	name string,
+	table *Table,
+) error {
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			cm, err := cl.Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				log.Printf("Error fetching routing table ConfigMap %s (%s)", name, err)
+				continue
+			}
+			newTable, err := FetchTableFromConfigMap(cm)
+			if err != nil {
+				log.Printf("Error decoding routing table in ConfigMap %s (%s)", name, err)
+				continue
+			}
+			table.Replace(newTable)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package routing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestFetchTableFromConfigMap(t *testing.T) {
+	r := require.New(t)
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "testcm"},
+		Data: map[string]string{
+			ConfigMapRoutingTableKey: `{"testhost.com": {"service": "testsvc", "port": 8080, "deployment": "testdepl"}}`,
+		},
+	}
+	table, err := FetchTableFromConfigMap(cm)
+	r.NoError(err)
+	target, err := table.Lookup("testhost.com", "/", nil)
+	r.NoError(err)
+	r.Equal(NewTarget("testsvc", 8080, "testdepl"), target)
+
+	delete(cm.Data, ConfigMapRoutingTableKey)
+	_, err = FetchTableFromConfigMap(cm)
+	r.Error(err)
+}
+
+func TestStartConfigMapUpdateLoop(t *testing.T) {
+	r := require.New(t)
+	const ns = "testns"
+	const name = "testcm"
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns},
+		Data: map[string]string{
+			ConfigMapRoutingTableKey: `{"testhost.com": {"service": "testsvc", "port": 8080, "deployment": "testdepl"}}`,
+		},
+	}
+	cl := k8sfake.NewSimpleClientset(cm).CoreV1().ConfigMaps(ns)
+	table := NewTable()
+
+	ctx, done := context.WithCancel(context.Background())
+	defer done()
+	go StartConfigMapUpdateLoop(ctx, time.NewTicker(10*time.Millisecond), cl, name, table)
+
+	r.Eventually(func() bool {
+		_, err := table.Lookup("testhost.com", "/", nil)
+		return err == nil
+	}, 500*time.Millisecond, 10*time.Millisecond)
+}
@@ -0,0 +1,226 @@
+package routing
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// ErrTargetNotFound is returned from Lookup when there is no target
+// that matches a request and there is no default target
+var ErrTargetNotFound = errors.New("Target not found")
+
+// Table is a routing table that maps hosts to the Targets that requests
+// for that host can be forwarded to. It's how a single interceptor
+// can front many different apps.
+//
+// Each host can have several Targets, distinguished by path prefix and
+// headers. See Lookup for how a Target is chosen.
+//
+// Table is concurrency safe. Always use NewTable to create one of these.
+type Table struct {
+	m             map[string][]Target
+	defaultTarget *Target
+	rwm           *sync.RWMutex
+}
+
+// NewTable creates a new, empty routing table
+func NewTable() *Table {
+	return &Table{
+		m:   make(map[string][]Target),
+		rwm: new(sync.RWMutex),
+	}
+}
+
+// NormalizeHost strips the port, if any, off of host and lowercases it
+// so that lookups match regardless of how the client formatted its
+// Host header. Hosts that normalize to the same string are the same host
+// to the routing table
+func NormalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(host)
+}
+
+// WildcardHost is the host to register targets under so that they
+// match requests for any host that has no matching targets of its own
+const WildcardHost = "*"
+
+// Lookup returns the target for a request to host with the given path and
+// header.
+//
+// Of the targets registered for host, the ones whose path prefix and
+// headers match the request are candidates. The candidate with the
+// longest path prefix is chosen, and ties are broken in favor of the
+// candidate that matches the most headers. If several targets match
+// exactly the same requests as the chosen one, one of them is picked at
+// random according to their weights.
+//
+// If there are no candidates for host, the targets registered for
+// WildcardHost are considered the same way. If there are still no
+// candidates, the default target is returned if one was set with
+// SetDefaultTarget. Otherwise, returns ErrTargetNotFound
+func (t *Table) Lookup(host, path string, header http.Header) (Target, error) {
+	t.rwm.RLock()
+	defer t.rwm.RUnlock()
+	if target := lookupIn(t.m[NormalizeHost(host)], path, header); target != nil {
+		return *target, nil
+	}
+	if target := lookupIn(t.m[WildcardHost], path, header); target != nil {
+		return *target, nil
+	}
+	if t.defaultTarget != nil {
+		return *t.defaultTarget, nil
+	}
+	return Target{}, ErrTargetNotFound
+}
+
+// lookupIn returns the target in targets for a request with the given
+// path and header, as described in Lookup, or nil if none of them match
+func lookupIn(targets []Target, path string, header http.Header) *Target {
+	var best *Target
+	for i := range targets {
+		target := &targets[i]
+		if !target.matchesPath(path) || !target.matchesHeaders(header) {
+			continue
+		}
+		if best == nil ||
+			len(target.PathPrefix) > len(best.PathPrefix) ||
+			(len(target.PathPrefix) == len(best.PathPrefix) &&
+				len(target.Headers) > len(best.Headers)) {
+			best = target
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	return pickWeighted(targets, best)
+}
+
+// pickWeighted randomly picks one of the targets that match the same
+// requests as best, with probability proportional to their weights.
+// Returns best if none of them have a positive weight
+func pickWeighted(targets []Target, best *Target) *Target {
+	total := 0
+	for i := range targets {
+		if targets[i].Weight > 0 && targets[i].sameMatch(*best) {
+			total += targets[i].Weight
+		}
+	}
+	if total == 0 {
+		return best
+	}
+	n := rand.Intn(total)
+	for i := range targets {
+		target := &targets[i]
+		if target.Weight <= 0 || !target.sameMatch(*best) {
+			continue
+		}
+		if n < target.Weight {
+			return target
+		}
+		n -= target.Weight
+	}
+	return best
+}
+
+// AddTarget registers target for host. If host already has a target with
+// the same path prefix and headers, it's overwritten
+func (t *Table) AddTarget(host string, target Target) {
+	t.rwm.Lock()
+	defer t.rwm.Unlock()
+	host = NormalizeHost(host)
+	targets := t.m[host]
+	for i, existing := range targets {
+		if existing.sameMatch(target) {
+			targets[i] = target
+			return
+		}
+	}
+	t.m[host] = append(targets, target)
+}
+
+// RemoveTarget removes all the targets for host. Returns ErrTargetNotFound
+// if there were no targets for host
+func (t *Table) RemoveTarget(host string) error {
+	t.rwm.Lock()
+	defer t.rwm.Unlock()
+	host = NormalizeHost(host)
+	if _, ok := t.m[host]; !ok {
+		return ErrTargetNotFound
+	}
+	delete(t.m, host)
+	return nil
+}
+
+// SetDefaultTarget sets the target that Lookup returns for requests that
+// match no other target
+func (t *Table) SetDefaultTarget(target Target) {
+	t.rwm.Lock()
+	defer t.rwm.Unlock()
+	t.defaultTarget = &target
+}
+
+// Replace replaces all the host entries in t with the ones in newTable.
+// The default target in t is left untouched, since it generally comes
+// from static configuration rather than from the source of newTable
+func (t *Table) Replace(newTable *Table) {
+	newTable.rwm.RLock()
+	newMap := make(map[string][]Target, len(newTable.m))
+	for host, targets := range newTable.m {
+		newMap[host] = append([]Target(nil), targets...)
+	}
+	newTable.rwm.RUnlock()
+
+	t.rwm.Lock()
+	defer t.rwm.Unlock()
+	t.m = newMap
+}
+
+// MarshalJSON implements json.Marshaler. It encodes the host entries in
+// t as a JSON object that maps each host to its list of targets
+func (t *Table) MarshalJSON() ([]byte, error) {
+	t.rwm.RLock()
+	defer t.rwm.RUnlock()
+	return json.Marshal(t.m)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It decodes the format that
+// MarshalJSON produces and replaces all host entries in t with the result.
+//
+// For convenience, a host may also map to a single target object rather
+// than a list of targets
+func (t *Table) UnmarshalJSON(data []byte) error {
+	rawMap := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &rawMap); err != nil {
+		return err
+	}
+	newMap := make(map[string][]Target, len(rawMap))
+	for host, raw := range rawMap {
+		var targets []Target
+		if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '{' {
+			var target Target
+			if err := json.Unmarshal(raw, &target); err != nil {
+				return err
+			}
+			targets = []Target{target}
+		} else if err := json.Unmarshal(raw, &targets); err != nil {
+			return err
+		}
+		host = NormalizeHost(host)
+		newMap[host] = append(newMap[host], targets...)
+	}
+	if t.rwm == nil {
+		t.rwm = new(sync.RWMutex)
+	}
+	t.rwm.Lock()
+	defer t.rwm.Unlock()
+	t.m = newMap
+	return nil
+}
@@ -0,0 +1,251 @@
+package routing
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTableLookup(t *testing.T) {
+	r := require.New(t)
+	table := NewTable()
+	target := NewTarget("testsvc", 8080, "testdepl")
+	table.AddTarget("TestHost.com", target)
+
+	// lookups should ignore case and port
+	for _, host := range []string{"testhost.com", "TESTHOST.COM", "testhost.com:8080"} {
+		ret, err := table.Lookup(host, "/", nil)
+		r.NoError(err, "looking up %s", host)
+		r.Equal(target, ret, "looking up %s", host)
+	}
+
+	_, err := table.Lookup("otherhost.com", "/", nil)
+	r.Equal(ErrTargetNotFound, err)
+
+	r.NoError(table.RemoveTarget("testhost.com"))
+	_, err = table.Lookup("testhost.com", "/", nil)
+	r.Equal(ErrTargetNotFound, err)
+	r.Equal(ErrTargetNotFound, table.RemoveTarget("testhost.com"))
+}
+
+func TestTableDefaultTarget(t *testing.T) {
+	r := require.New(t)
+	table := NewTable()
+	hostTarget := NewTarget("hostsvc", 8080, "hostdepl")
+	defaultTarget := NewTarget("defaultsvc", 8081, "defaultdepl")
+	table.AddTarget("testhost.com", hostTarget)
+	table.SetDefaultTarget(defaultTarget)
+
+	ret, err := table.Lookup("testhost.com", "/", nil)
+	r.NoError(err)
+	r.Equal(hostTarget, ret)
+
+	ret, err = table.Lookup("otherhost.com", "/", nil)
+	r.NoError(err)
+	r.Equal(defaultTarget, ret)
+
+	// replacing the host entries shouldn't remove the default
+	table.Replace(NewTable())
+	ret, err = table.Lookup("testhost.com", "/", nil)
+	r.NoError(err)
+	r.Equal(defaultTarget, ret)
+}
+
+func TestTableJSONRoundTrip(t *testing.T) {
+	r := require.New(t)
+	table := NewTable()
+	table.AddTarget("host1.com", NewTarget("svc1", 8080, "depl1"))
+	table.AddTarget("host2.com", NewTarget("svc2", 8081, "depl2"))
+
+	b, err := json.Marshal(table)
+	r.NoError(err)
+
+	decoded := NewTable()
+	r.NoError(json.Unmarshal(b, decoded))
+	for _, host := range []string{"host1.com", "host2.com"} {
+		expected, err := table.Lookup(host, "/", nil)
+		r.NoError(err)
+		actual, err := decoded.Lookup(host, "/", nil)
+		r.NoError(err)
+		r.Equal(expected, actual)
+	}
+}
+
+func TestTableLookupRules(t *testing.T) {
+	r := require.New(t)
+	const host = "testhost.com"
+	table := NewTable()
+	root := NewTarget("rootsvc", 8080, "rootdepl")
+	api := NewTarget("apisvc", 8080, "apidepl")
+	api.PathPrefix = "/api"
+	apiV2 := NewTarget("apiv2svc", 8080, "apiv2depl")
+	apiV2.PathPrefix = "/api"
+	apiV2.Headers = map[string]string{"X-Api-Version": "2"}
+	admin := NewTarget("adminsvc", 8080, "admindepl")
+	admin.PathPrefix = "/admin/"
+	for _, target := range []Target{root, api, apiV2, admin} {
+		table.AddTarget(host, target)
+	}
+
+	v2Header := http.Header{}
+	v2Header.Set("x-api-version", "2")
+	v3Header := http.Header{}
+	v3Header.Set("X-Api-Version", "3")
+	cases := []struct {
+		path     string
+		header   http.Header
+		expected Target
+	}{
+		{"/", nil, root},
+		{"/apis", nil, root},
+		{"/api", nil, api},
+		{"/api/users", nil, api},
+		{"/api/users", v3Header, api},
+		{"/api/users", v2Header, apiV2},
+		{"/admin", nil, root},
+		{"/admin/users", nil, admin},
+	}
+	for _, c := range cases {
+		ret, err := table.Lookup(host, c.path, c.header)
+		r.NoError(err, "looking up %s", c.path)
+		r.Equal(c.expected, ret, "looking up %s", c.path)
+	}
+
+	// adding a target with the same match should overwrite the old one
+	newAPI := NewTarget("newapisvc", 8080, "newapidepl")
+	newAPI.PathPrefix = "/api"
+	table.AddTarget(host, newAPI)
+	ret, err := table.Lookup(host, "/api", nil)
+	r.NoError(err)
+	r.Equal(newAPI, ret)
+
+	// and header names match whatever their case
+	newAPIV2 := NewTarget("newapiv2svc", 8080, "newapiv2depl")
+	newAPIV2.PathPrefix = "/api"
+	newAPIV2.Headers = map[string]string{"x-api-version": "2"}
+	table.AddTarget(host, newAPIV2)
+	ret, err = table.Lookup(host, "/api", v2Header)
+	r.NoError(err)
+	r.Equal(newAPIV2, ret)
+	r.True(newAPIV2.sameMatch(apiV2))
+
+	// with no root target, unmatched paths shouldn't be found
+	noRoot := NewTable()
+	noRoot.AddTarget(host, api)
+	_, err = noRoot.Lookup(host, "/other", nil)
+	r.Equal(ErrTargetNotFound, err)
+}
+
+func TestTargetStripPath(t *testing.T) {
+	r := require.New(t)
+	target := NewTarget("testsvc", 8080, "testdepl")
+	target.PathPrefix = "/api"
+	r.Equal("/api/users", target.StripPath("/api/users"))
+
+	target.StripPrefix = true
+	r.Equal("/users", target.StripPath("/api/users"))
+	r.Equal("/", target.StripPath("/api"))
+
+	target.PathPrefix = "/api/"
+	r.Equal("/users", target.StripPath("/api/users"))
+}
+
+func TestTableUnmarshalRuleList(t *testing.T) {
+	r := require.New(t)
+	const tableJSON = `{
+		"testhost.com": [
+			{"service": "apisvc", "port": 8080, "deployment": "apidepl", "pathPrefix": "/api", "stripPrefix": true},
+			{"service": "rootsvc", "port": 8080, "deployment": "rootdepl"}
+		]
+	}`
+	table := NewTable()
+	r.NoError(json.Unmarshal([]byte(tableJSON), table))
+
+	ret, err := table.Lookup("testhost.com", "/api/users", nil)
+	r.NoError(err)
+	r.Equal("apisvc", ret.Service)
+	r.True(ret.StripPrefix)
+
+	ret, err = table.Lookup("testhost.com", "/users", nil)
+	r.NoError(err)
+	r.Equal("rootsvc", ret.Service)
+}
+
+func TestTargetScaleTarget(t *testing.T) {
+	r := require.New(t)
+	const tableJSON = `{
+		"depl.com": {"service": "deplsvc", "port": 8080, "deployment": "depl"},
+		"sts.com": {
+			"service": "stssvc",
+			"port": 8080,
+			"scaleTargetRef": {"apiVersion": "apps/v1", "kind": "StatefulSet", "name": "sts"}
+		}
+	}`
+	table := NewTable()
+	r.NoError(json.Unmarshal([]byte(tableJSON), table))
+
+	ret, err := table.Lookup("depl.com", "/", nil)
+	r.NoError(err)
+	r.Equal(NewDeploymentScaleTargetRef("depl"), ret.ScaleTarget())
+	r.True(ret.ScaleTarget().IsDeployment())
+
+	ret, err = table.Lookup("sts.com", "/", nil)
+	r.NoError(err)
+	r.Equal(ScaleTargetRef{
+		APIVersion: "apps/v1",
+		Kind:       "StatefulSet",
+		Name:       "sts",
+	}, ret.ScaleTarget())
+	r.False(ret.ScaleTarget().IsDeployment())
+}
+
+func TestTableWildcardHost(t *testing.T) {
+	r := require.New(t)
+	table := NewTable()
+	hostTarget := NewTarget("hostsvc", 8080, "hostdepl")
+	hostTarget.PathPrefix = "/api"
+	wildcardTarget := NewTarget("wildcardsvc", 8080, "wildcarddepl")
+	table.AddTarget("testhost.com", hostTarget)
+	table.AddTarget(WildcardHost, wildcardTarget)
+	table.SetDefaultTarget(NewTarget("defaultsvc", 8081, "defaultdepl"))
+
+	ret, err := table.Lookup("testhost.com", "/api", nil)
+	r.NoError(err)
+	r.Equal(hostTarget, ret)
+
+	// the wildcard should win over the default, both for hosts with no
+	// targets and for requests that a host's own targets don't match
+	ret, err = table.Lookup("otherhost.com", "/", nil)
+	r.NoError(err)
+	r.Equal(wildcardTarget, ret)
+	ret, err = table.Lookup("testhost.com", "/other", nil)
+	r.NoError(err)
+	r.Equal(wildcardTarget, ret)
+}
+
+func TestTableLookupWeights(t *testing.T) {
+	r := require.New(t)
+	const tableJSON = `{
+		"testhost.com": [
+			{"service": "stablesvc", "port": 8080, "deployment": "stable", "weight": 3},
+			{"service": "canarysvc", "port": 8080, "deployment": "canary", "weight": 1},
+			{"service": "offsvc", "port": 8080, "deployment": "off"}
+		]
+	}`
+	table := NewTable()
+	r.NoError(json.Unmarshal([]byte(tableJSON), table))
+
+	counts := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		ret, err := table.Lookup("testhost.com", "/", nil)
+		r.NoError(err)
+		counts[ret.Service]++
+	}
+	// targets without a weight get nothing when others have weights
+	r.Equal(0, counts["offsvc"])
+	r.Equal(1000, counts["stablesvc"]+counts["canarysvc"])
+	r.Greater(counts["stablesvc"], counts["canarysvc"])
+	r.Greater(counts["canarysvc"], 0)
+}
@@ -0,0 +1,182 @@
+package routing
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ScaleTargetRef identifies the resource that KEDA scales to back a
+// Target. It can be any resource with a /scale subresource, like a
+// Deployment, StatefulSet or Argo Rollout
+type ScaleTargetRef struct {
+	// APIVersion is the API version of the resource, like apps/v1
+	APIVersion string `json:"apiVersion"`
+	// Kind is the kind of the resource, like StatefulSet
+	Kind string `json:"kind"`
+	// Name is the name of the resource
+	Name string `json:"name"`
+}
+
+// NewDeploymentScaleTargetRef creates a ScaleTargetRef for the apps/v1
+// Deployment called name
+func NewDeploymentScaleTargetRef(name string) ScaleTargetRef {
+	return ScaleTargetRef{
+		APIVersion: "apps/v1",
+		Kind:       "Deployment",
+		Name:       name,
+	}
+}
+
+// IsDeployment returns whether s refers to an apps/v1 Deployment
+func (s ScaleTargetRef) IsDeployment() bool {
+	return s.APIVersion == "apps/v1" && s.Kind == "Deployment"
+}
+
+// Target is a single backend that the interceptor can forward requests to.
+// It holds the service (and port on that service) to forward to, and the
+// deployment that backs that service, so the interceptor knows which
+// deployment to wait on before forwarding.
+//
+// A Target can optionally be restricted to requests with a given path
+// prefix and/or header values, so that requests for a single host can be
+// split across several backends
+type Target struct {
+	// Service is the name of the Kubernetes service to forward to
+	Service string `json:"service"`
+	// Port is the port on Service to forward to
+	Port int `json:"port"`
+	// Deployment is the name of the deployment that backs Service. It's
+	// ignored if ScaleTargetRef is set
+	Deployment string `json:"deployment,omitempty"`
+	// ScaleTargetRef, if set, is the resource that backs Service. Use
+	// it instead of Deployment for resources other than Deployments
+	ScaleTargetRef *ScaleTargetRef `json:"scaleTargetRef,omitempty"`
+	// PathPrefix, if set, restricts this target to requests whose path
+	// is PathPrefix or is under PathPrefix
+	PathPrefix string `json:"pathPrefix,omitempty"`
+	// Headers, if set, restricts this target to requests that have all
+	// of these headers with exactly these values
+	Headers map[string]string `json:"headers,omitempty"`
+	// StripPrefix indicates whether PathPrefix should be removed from the
+	// request path before the request is forwarded to Service
+	StripPrefix bool `json:"stripPrefix,omitempty"`
+	// Weight, if positive, is the share of requests that this target gets
+	// relative to the other targets for the same host that match the same
+	// requests. Since AddTarget replaces targets that match the same
+	// requests, weighted targets need to be loaded with UnmarshalJSON
+	Weight int `json:"weight,omitempty"`
+	// Paused indicates that the scaling of the resource that backs
+	// Service is paused, so the interceptor forwards requests to Service
+	// right away instead of waiting for it to have replicas
+	Paused bool `json:"paused,omitempty"`
+}
+
+// NewTarget creates a new Target from the given parameters. The returned
+// Target matches all paths and headers
+func NewTarget(svc string, port int, depl string) Target {
+	return Target{
+		Service:    svc,
+		Port:       port,
+		Deployment: depl,
+	}
+}
+
+// ScaleTarget returns the resource that backs t's service. That's
+// t.ScaleTargetRef if it's set, or the Deployment called t.Deployment
+// otherwise
+func (t *Target) ScaleTarget() ScaleTargetRef {
+	if t.ScaleTargetRef != nil {
+		return *t.ScaleTargetRef
+	}
+	return NewDeploymentScaleTargetRef(t.Deployment)
+}
+
+// ServiceURL formats the target's service name and port into a URL.
+// It returns a newly allocated URL every time, so callers are free to
+// modify it
+func (t *Target) ServiceURL() (*url.URL, error) {
+	urlStr := fmt.Sprintf("http://%s:%d", t.Service, t.Port)
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// matchesPath returns whether path is t.PathPrefix or is under it. The
+// match is on whole path segments, so "/api" matches "/api" and "/api/users"
+// but not "/apis"
+func (t *Target) matchesPath(path string) bool {
+	prefix := t.PathPrefix
+	if prefix == "" || prefix == "/" {
+		return true
+	}
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	return len(path) == len(prefix) ||
+		strings.HasSuffix(prefix, "/") ||
+		path[len(prefix)] == '/'
+}
+
+// matchesHeaders returns whether header has all of t.Headers in it
+func (t *Target) matchesHeaders(header http.Header) bool {
+	for name, val := range t.Headers {
+		if header.Get(name) != val {
+			return false
+		}
+	}
+	return true
+}
+
+// sameMatch returns whether t and other match exactly the same requests.
+// Header names are compared like matchesHeaders compares them, so names
+// that only differ in case are the same
+func (t *Target) sameMatch(other Target) bool {
+	if t.PathPrefix != other.PathPrefix {
+		return false
+	}
+	headers, otherHeaders := canonicalHeaders(t.Headers), canonicalHeaders(other.Headers)
+	if len(headers) != len(otherHeaders) {
+		return false
+	}
+	for name, val := range headers {
+		if otherVal, ok := otherHeaders[name]; !ok || otherVal != val {
+			return false
+		}
+	}
+	return true
+}
+
+// canonicalHeaders returns headers with each name in its canonical form,
+// like http.Header.Get looks them up
+func canonicalHeaders(headers map[string]string) map[string]string {
+	ret := make(map[string]string, len(headers))
+	for name, val := range headers {
+		ret[http.CanonicalHeaderKey(name)] = val
+	}
+	return ret
+}
+
+// StripPath returns path with t.PathPrefix removed from the front of it, if
+// t.StripPrefix is set. Otherwise returns path unchanged. If the prefix was
+// removed, the returned path always has a leading slash
+func (t *Target) StripPath(path string) string {
+	if !t.StripPrefix || t.PathPrefix == "" {
+		return path
+	}
+	stripped := strings.TrimPrefix(path, strings.TrimSuffix(t.PathPrefix, "/"))
+	if !strings.HasPrefix(stripped, "/") {
+		stripped = "/" + stripped
+	}
+	return stripped
+}
+
+// QueueKey returns the key under which requests to host that were routed
+// to t are counted. It's host followed by t.PathPrefix, so all the routes
+// for a host can be found by looking for keys that start with that host
+func (t *Target) QueueKey(host string) string {
+	return NormalizeHost(host) + t.PathPrefix
+}
@@ -0,0 +1,145 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             (unknown)
+// source: proto/queue.proto
+
+package externalscaler
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// QueueReporterClient is the client API for QueueReporter service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type QueueReporterClient interface {
+	// ReportCounts streams count updates from a single interceptor. The
+	// first update on every stream must be a snapshot. The scaler forgets
+	// the interceptor's counts when the stream ends.
+	ReportCounts(ctx context.Context, opts ...grpc.CallOption) (QueueReporter_ReportCountsClient, error)
+}
+
+type queueReporterClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewQueueReporterClient(cc grpc.ClientConnInterface) QueueReporterClient {
+	return &queueReporterClient{cc}
+}
+
+func (c *queueReporterClient) ReportCounts(ctx context.Context, opts ...grpc.CallOption) (QueueReporter_ReportCountsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &QueueReporter_ServiceDesc.Streams[0], "/externalscaler.QueueReporter/ReportCounts", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &queueReporterReportCountsClient{stream}
+	return x, nil
+}
+
+type QueueReporter_ReportCountsClient interface {
+	Send(*QueueCountsUpdate) error
+	CloseAndRecv() (*ReportCountsResponse, error)
+	grpc.ClientStream
+}
+
+type queueReporterReportCountsClient struct {
+	grpc.ClientStream
+}
+
+func (x *queueReporterReportCountsClient) Send(m *QueueCountsUpdate) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *queueReporterReportCountsClient) CloseAndRecv() (*ReportCountsResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(ReportCountsResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// QueueReporterServer is the server API for QueueReporter service.
+// All implementations must embed UnimplementedQueueReporterServer
+// for forward compatibility
+type QueueReporterServer interface {
+	// ReportCounts streams count updates from a single interceptor. The
+	// first update on every stream must be a snapshot. The scaler forgets
+	// the interceptor's counts when the stream ends.
+	ReportCounts(QueueReporter_ReportCountsServer) error
+	mustEmbedUnimplementedQueueReporterServer()
+}
+
+// UnimplementedQueueReporterServer must be embedded to have forward compatible implementations.
+type UnimplementedQueueReporterServer struct {
+}
+
+func (UnimplementedQueueReporterServer) ReportCounts(QueueReporter_ReportCountsServer) error {
+	return status.Errorf(codes.Unimplemented, "method ReportCounts not implemented")
+}
+func (UnimplementedQueueReporterServer) mustEmbedUnimplementedQueueReporterServer() {}
+
+// UnsafeQueueReporterServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to QueueReporterServer will
+// result in compilation errors.
+type UnsafeQueueReporterServer interface {
+	mustEmbedUnimplementedQueueReporterServer()
+}
+
+func RegisterQueueReporterServer(s grpc.ServiceRegistrar, srv QueueReporterServer) {
+	s.RegisterService(&QueueReporter_ServiceDesc, srv)
+}
+
+func _QueueReporter_ReportCounts_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(QueueReporterServer).ReportCounts(&queueReporterReportCountsServer{stream})
+}
+
+type QueueReporter_ReportCountsServer interface {
+	SendAndClose(*ReportCountsResponse) error
+	Recv() (*QueueCountsUpdate, error)
+	grpc.ServerStream
+}
+
+type queueReporterReportCountsServer struct {
+	grpc.ServerStream
+}
+
+func (x *queueReporterReportCountsServer) SendAndClose(m *ReportCountsResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *queueReporterReportCountsServer) Recv() (*QueueCountsUpdate, error) {
+	m := new(QueueCountsUpdate)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// QueueReporter_ServiceDesc is the grpc.ServiceDesc for QueueReporter service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var QueueReporter_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "externalscaler.QueueReporter",
+	HandlerType: (*QueueReporterServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ReportCounts",
+			Handler:       _QueueReporter_ReportCounts_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "proto/queue.proto",
+}
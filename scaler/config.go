@@ -1,6 +1,10 @@
 package main
 
-import "github.com/kelseyhightower/envconfig"
+import (
+	"time"
+
+	"github.com/kelseyhightower/envconfig"
+)
 
 type config struct {
 	// GRPCPort is what port to serve the KEDA-compatible gRPC external scaler interface
@@ -17,6 +21,13 @@ type config struct {
 	// TargetPort is the port on TargetService to which to issue metrics RPC requests to
 	// interceptors
 	TargetPort int `envconfig:"KEDA_HTTP_SCALER_TARGET_ADMIN_PORT" required:"true"`
+	// IdleWindow is how long there must be no pending requests for an app
+	// before this scaler reports it as inactive, which lets KEDA scale it
+	// to zero
+	IdleWindow time.Duration `envconfig:"KEDA_HTTP_SCALER_IDLE_WINDOW" default:"30s"`
+	// StreamIsActiveInterval is how often StreamIsActive checks whether
+	// an app has changed between active and inactive
+	StreamIsActiveInterval time.Duration `envconfig:"KEDA_HTTP_SCALER_STREAM_IS_ACTIVE_INTERVAL" default:"200ms"`
 }
 
 func mustParseConfig() *config {
@@ -6,24 +6,139 @@ package main
 
 import (
 	context "context"
+	"fmt"
 	"math/rand"
+	"strconv"
+	"strings"
 	"time"
 
 	empty "github.com/golang/protobuf/ptypes/empty"
+	"github.com/kedacore/http-add-on/pkg/http"
 	externalscaler "github.com/kedacore/http-add-on/proto"
 )
 
+const (
+	// defaultTargetPendingRequests is the number of pending requests per
+	// replica that KEDA scales to if the ScaledObject doesn't say otherwise
+	defaultTargetPendingRequests = 100
+	// defaultMetricName is the name of the metric reported to KEDA if the
+	// ScaledObject doesn't say otherwise
+	defaultMetricName = "queueSize"
+)
+
 func init() {
 	rand.Seed(time.Now().UnixNano())
 }
 
 type impl struct {
 	pinger *queuePinger
+	// metrics counts the calls that KEDA makes
+	metrics *metrics
+	// idleWindow is how long an app must have no pending requests before
+	// it's considered inactive
+	idleWindow time.Duration
+	// streamInterval is how often StreamIsActive re-checks activity
+	streamInterval time.Duration
 	externalscaler.UnimplementedExternalScalerServer
 }
 
-func newImpl(pinger *queuePinger) *impl {
-	return &impl{pinger: pinger}
+func newImpl(
+	pinger *queuePinger,
+	m *metrics,
+	idleWindow,
+	streamInterval time.Duration,
+) *impl {
+	return &impl{
+		pinger:         pinger,
+		metrics:        m,
+		idleWindow:     idleWindow,
+		streamInterval: streamInterval,
+	}
+}
+
+// keyFilter is the queue keys that a ScaledObject's pending requests are
+// counted under. The operator names the keys of the app's routes, which
+// are matched exactly, so that each route of a host can be scaled on its
+// own. ScaledObjects created by older operators name hosts instead, and
+// every key under them is counted. If a keyFilter names neither, every
+// key is counted
+type keyFilter struct {
+	routes []string
+	hosts  []string
+}
+
+// metadataKeyFilter returns the keyFilter for a ScaledObject with the
+// given metadata, from its comma-separated routes key and from
+// metadataHosts
+func metadataKeyFilter(metadata map[string]string) keyFilter {
+	var routes []string
+	for _, route := range strings.Split(metadata["routes"], ",") {
+		if route = strings.TrimSpace(route); route != "" {
+			routes = append(routes, route)
+		}
+	}
+	return keyFilter{routes: routes, hosts: metadataHosts(metadata)}
+}
+
+// all returns whether f counts every key
+func (f keyFilter) all() bool {
+	return len(f.routes) == 0 && len(f.hosts) == 0
+}
+
+// matches returns whether f counts key
+func (f keyFilter) matches(key string) bool {
+	for _, route := range f.routes {
+		if http.KeyIsForRoute(key, route) {
+			return true
+		}
+	}
+	for _, host := range f.hosts {
+		if http.KeyIsForHost(key, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// metadataHosts returns the hosts named in metadata, from its host key
+// and its comma-separated hosts key, lowercased to match the queue keys
+// that interceptors report. Returns nil if metadata names no hosts
+func metadataHosts(metadata map[string]string) []string {
+	var ret []string
+	if host := metadata["host"]; host != "" {
+		ret = append(ret, strings.ToLower(host))
+	}
+	for _, host := range strings.Split(metadata["hosts"], ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			ret = append(ret, strings.ToLower(host))
+		}
+	}
+	return ret
+}
+
+// count returns the pending requests for the keys that filter counts
+func (e *impl) count(filter keyFilter) int {
+	if filter.all() {
+		return e.pinger.count()
+	}
+	return e.pinger.countMatching(filter.matches)
+}
+
+// isActive returns whether the app whose requests filter counts should be
+// considered active.
+//
+// An app is active if it has pending requests, or if it last had pending
+// requests within the idle window. This way, KEDA doesn't scale an app
+// to zero as soon as its last request finishes
+func (e *impl) isActive(filter keyFilter) bool {
+	if e.count(filter) > 0 {
+		return true
+	}
+	var match func(string) bool
+	if !filter.all() {
+		match = filter.matches
+	}
+	return time.Since(e.pinger.lastActiveTime(match)) < e.idleWindow
 }
 
 func (e *impl) Ping(context.Context, *empty.Empty) (*empty.Empty, error) {
@@ -34,8 +149,11 @@ func (e *impl) IsActive(
 	ctx context.Context,
 	scaledObject *externalscaler.ScaledObjectRef,
 ) (*externalscaler.IsActiveResponse, error) {
+	filter := metadataKeyFilter(scaledObject.GetScalerMetadata())
+	active := e.isActive(filter)
+	e.metrics.countCall("IsActive", activeResult(active))
 	return &externalscaler.IsActiveResponse{
-		Result: true,
+		Result: active,
 	}, nil
 }
 
@@ -44,31 +162,96 @@ func (e *impl) StreamIsActive(
 	server externalscaler.ExternalScaler_StreamIsActiveServer,
 ) error {
 	// this function communicates with KEDA via the 'server' parameter.
-	// we call server.Send (below) every 200ms, which tells it to immediately
-	// ping our IsActive RPC
-	ticker := time.NewTicker(5 * time.Millisecond)
+	// we check activity every time the counts change, so that the first
+	// request for an idle app is signaled right away, and every
+	// e.streamInterval, so that we notice when the idle window passes.
+	// we only call server.Send (below) when the app goes from active to
+	// inactive or vice versa, so that KEDA isn't flooded with redundant
+	// updates
+	filter := metadataKeyFilter(in.GetScalerMetadata())
+	ticker := time.NewTicker(e.streamInterval)
 	defer ticker.Stop()
+	changedCh, unsubscribe := e.pinger.subscribe()
+	defer unsubscribe()
+	// send the initial state right away so KEDA doesn't have to wait for
+	// the first transition
+	lastActive := e.isActive(filter)
+	e.metrics.countCall("StreamIsActive", activeResult(lastActive))
+	if err := server.Send(&externalscaler.IsActiveResponse{
+		Result: lastActive,
+	}); err != nil {
+		return err
+	}
 	for {
 		select {
 		case <-server.Context().Done():
 			return nil
 		case <-ticker.C:
-			server.Send(&externalscaler.IsActiveResponse{
-				Result: true,
-			})
+		case <-changedCh:
+		}
+		active := e.isActive(filter)
+		if active == lastActive {
+			continue
 		}
+		e.metrics.countCall("StreamIsActive", activeResult(active))
+		if err := server.Send(&externalscaler.IsActiveResponse{
+			Result: active,
+		}); err != nil {
+			return err
+		}
+		lastActive = active
+	}
+}
+
+// targetPendingRequests returns the targetPendingRequests value in
+// metadata, or defaultTargetPendingRequests if there isn't one. Returns
+// an error if the value isn't a positive integer
+func targetPendingRequests(metadata map[string]string) (int64, error) {
+	targetStr, ok := metadata["targetPendingRequests"]
+	if !ok || targetStr == "" {
+		return defaultTargetPendingRequests, nil
+	}
+	target, err := strconv.ParseInt(targetStr, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf(
+			"invalid targetPendingRequests %q (%s)",
+			targetStr,
+			err,
+		)
 	}
+	if target <= 0 {
+		return 0, fmt.Errorf(
+			"targetPendingRequests must be positive, got %d",
+			target,
+		)
+	}
+	return target, nil
+}
+
+// metricName returns the metricName value in metadata, or
+// defaultMetricName if there isn't one
+func metricName(metadata map[string]string) string {
+	if name := metadata["metricName"]; name != "" {
+		return name
+	}
+	return defaultMetricName
 }
 
 func (e *impl) GetMetricSpec(
 	_ context.Context,
 	sor *externalscaler.ScaledObjectRef,
 ) (*externalscaler.GetMetricSpecResponse, error) {
+	metadata := sor.GetScalerMetadata()
+	target, err := targetPendingRequests(metadata)
+	e.metrics.countCall("GetMetricSpec", errorResult(err))
+	if err != nil {
+		return nil, err
+	}
 	return &externalscaler.GetMetricSpecResponse{
 		MetricSpecs: []*externalscaler.MetricSpec{
 			{
-				MetricName: "queueSize",
-				TargetSize: 100,
+				MetricName: metricName(metadata),
+				TargetSize: target,
 			},
 		},
 	}, nil
@@ -78,11 +261,15 @@ func (e *impl) GetMetrics(
 	_ context.Context,
 	metricRequest *externalscaler.GetMetricsRequest,
 ) (*externalscaler.GetMetricsResponse, error) {
-	size := int64(e.pinger.count())
+	// if the ScaledObject names routes or hosts, only report the pending
+	// requests for those. otherwise, report the total across all hosts
+	metadata := metricRequest.GetScaledObjectRef().GetScalerMetadata()
+	size := int64(e.count(metadataKeyFilter(metadata)))
+	e.metrics.countCall("GetMetrics", errorResult(nil))
 	return &externalscaler.GetMetricsResponse{
 		MetricValues: []*externalscaler.MetricValue{
 			{
-				MetricName:  "queueSize",
+				MetricName:  metricName(metadata),
 				MetricValue: size,
 			},
 		},
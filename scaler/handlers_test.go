@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	externalscaler "github.com/kedacore/http-add-on/proto"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// newTestQueuePinger creates a queuePinger that never pings any
+// interceptors. Use its updateCounts method to feed it counts
+func newTestQueuePinger(activityRetention time.Duration) *queuePinger {
+	now := time.Now()
+	return &queuePinger{
+		pingMut:           new(sync.RWMutex),
+		lastCounts:        map[string]int{},
+		interceptorCounts: map[string]map[string]int{},
+		streams:           map[string]int{},
+		listeners:         map[chan struct{}]struct{}{},
+		startTime:         now,
+		lastActive:        map[string]time.Time{},
+		lastActiveAny:     now,
+		activityRetention: activityRetention,
+		metrics:           newMetrics(),
+	}
+}
+
+// countForHost returns pinger's aggregate count for all the routes under
+// host
+func countForHost(pinger *queuePinger, host string) int {
+	return pinger.countMatching(keyFilter{hosts: []string{host}}.matches)
+}
+
+// fakeStreamIsActiveServer is an
+// externalscaler.ExternalScaler_StreamIsActiveServer that sends every
+// response it gets down a channel
+type fakeStreamIsActiveServer struct {
+	grpc.ServerStream
+	ctx    context.Context
+	sentCh chan bool
+}
+
+func (f *fakeStreamIsActiveServer) Send(res *externalscaler.IsActiveResponse) error {
+	f.sentCh <- res.Result
+	return nil
+}
+
+func (f *fakeStreamIsActiveServer) Context() context.Context {
+	return f.ctx
+}
+
+func TestIsActive(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+	const (
+		host       = "myhost.com"
+		idleWindow = 100 * time.Millisecond
+	)
+	pinger := newTestQueuePinger(idleWindow)
+	hdl := newImpl(pinger, newMetrics(), idleWindow, time.Millisecond)
+	ref := &externalscaler.ScaledObjectRef{
+		ScalerMetadata: map[string]string{"host": host},
+	}
+
+	// the pinger just started, so the host is still within its idle window
+	res, err := hdl.IsActive(ctx, ref)
+	r.NoError(err)
+	r.True(res.Result)
+
+	// after the idle window with no requests, the host is inactive
+	time.Sleep(idleWindow)
+	res, err = hdl.IsActive(ctx, ref)
+	r.NoError(err)
+	r.False(res.Result)
+
+	// pending requests on any route for the host make it active
+	pinger.updateCounts(map[string]int{host + "/api": 1}, time.Now())
+	res, err = hdl.IsActive(ctx, ref)
+	r.NoError(err)
+	r.True(res.Result)
+
+	// requests for other hosts don't
+	r.False(hdl.isActive(keyFilter{hosts: []string{"otherhost.com"}}))
+	// unless they're listed alongside it
+	r.True(hdl.isActive(metadataKeyFilter(map[string]string{"hosts": "otherhost.com, " + host})))
+	// and they do count when no host is given
+	r.True(hdl.isActive(keyFilter{}))
+
+	// once the requests are done, the host stays active for the idle window
+	pinger.updateCounts(map[string]int{}, time.Now())
+	res, err = hdl.IsActive(ctx, ref)
+	r.NoError(err)
+	r.True(res.Result)
+
+	time.Sleep(idleWindow)
+	res, err = hdl.IsActive(ctx, ref)
+	r.NoError(err)
+	r.False(res.Result)
+}
+
+func TestQueuePingerForgetsIdleHosts(t *testing.T) {
+	r := require.New(t)
+	const retention = time.Minute
+	pinger := newTestQueuePinger(retention)
+	start := time.Now()
+
+	pinger.updateCounts(map[string]int{"a.com": 1, "b.com": 2}, start)
+	r.Equal(3, pinger.count())
+	r.Equal(1, countForHost(pinger, "a.com"))
+	forA := keyFilter{hosts: []string{"a.com"}}.matches
+	r.Equal(start, pinger.lastActiveTime(forA))
+
+	later := start.Add(retention / 2)
+	pinger.updateCounts(map[string]int{"b.com": 1}, later)
+	r.Equal(0, countForHost(pinger, "a.com"))
+	r.Equal(start, pinger.lastActiveTime(forA))
+	r.Equal(later, pinger.lastActiveTime(keyFilter{hosts: []string{"b.com"}}.matches))
+	r.Equal(later, pinger.lastActiveTime(nil))
+
+	// after the retention period, a.com is forgotten
+	pinger.updateCounts(map[string]int{"b.com": 1}, start.Add(retention*2))
+	r.Equal(1, len(pinger.lastActive))
+	_, ok := pinger.lastActive["a.com"]
+	r.False(ok)
+}
+
+func TestStreamIsActiveSendsTransitions(t *testing.T) {
+	r := require.New(t)
+	const (
+		host       = "myhost.com"
+		idleWindow = 50 * time.Millisecond
+	)
+	pinger := newTestQueuePinger(idleWindow)
+	hdl := newImpl(pinger, newMetrics(), idleWindow, time.Millisecond)
+	ctx, done := context.WithCancel(context.Background())
+	defer done()
+	srv := &fakeStreamIsActiveServer{
+		ctx:    ctx,
+		sentCh: make(chan bool, 100),
+	}
+	ref := &externalscaler.ScaledObjectRef{
+		ScalerMetadata: map[string]string{"host": host},
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- hdl.StreamIsActive(ref, srv)
+	}()
+
+	// the initial state is sent right away
+	r.True(<-srv.sentCh)
+	// then nothing is sent until the idle window passes
+	select {
+	case <-srv.sentCh:
+		r.Fail("got a StreamIsActive response without a transition")
+	case <-time.After(idleWindow / 2):
+	}
+	r.False(<-srv.sentCh)
+
+	// requests coming in should make it active again
+	pinger.updateCounts(map[string]int{host: 1}, time.Now())
+	r.True(<-srv.sentCh)
+
+	// and there should be no more updates while the requests are pending
+	select {
+	case <-srv.sentCh:
+		r.Fail("got a StreamIsActive response without a transition")
+	case <-time.After(idleWindow * 2):
+	}
+
+	done()
+	r.NoError(<-errCh)
+}
+
+func TestGetMetricSpec(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+	hdl := newImpl(newTestQueuePinger(time.Minute), newMetrics(), time.Minute, time.Millisecond)
+
+	// with no metadata, the defaults are used
+	res, err := hdl.GetMetricSpec(ctx, &externalscaler.ScaledObjectRef{})
+	r.NoError(err)
+	r.Equal(1, len(res.MetricSpecs))
+	r.Equal(defaultMetricName, res.MetricSpecs[0].MetricName)
+	r.Equal(int64(defaultTargetPendingRequests), res.MetricSpecs[0].TargetSize)
+
+	res, err = hdl.GetMetricSpec(ctx, &externalscaler.ScaledObjectRef{
+		ScalerMetadata: map[string]string{
+			"targetPendingRequests": "5",
+			"metricName":            "myapp",
+		},
+	})
+	r.NoError(err)
+	r.Equal(1, len(res.MetricSpecs))
+	r.Equal("myapp", res.MetricSpecs[0].MetricName)
+	r.Equal(int64(5), res.MetricSpecs[0].TargetSize)
+
+	for _, invalid := range []string{"abc", "0", "-1"} {
+		_, err = hdl.GetMetricSpec(ctx, &externalscaler.ScaledObjectRef{
+			ScalerMetadata: map[string]string{
+				"targetPendingRequests": invalid,
+			},
+		})
+		r.Error(err, "targetPendingRequests %s", invalid)
+	}
+}
+
+func TestGetMetricsUsesMetricName(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+	pinger := newTestQueuePinger(time.Minute)
+	hdl := newImpl(pinger, newMetrics(), time.Minute, time.Millisecond)
+	pinger.updateCounts(map[string]int{"a.com": 3, "b.com": 4}, time.Now())
+
+	res, err := hdl.GetMetrics(ctx, &externalscaler.GetMetricsRequest{
+		ScaledObjectRef: &externalscaler.ScaledObjectRef{
+			ScalerMetadata: map[string]string{
+				"host":       "a.com",
+				"metricName": "myapp",
+			},
+		},
+	})
+	r.NoError(err)
+	r.Equal(1, len(res.MetricValues))
+	r.Equal("myapp", res.MetricValues[0].MetricName)
+	r.Equal(int64(3), res.MetricValues[0].MetricValue)
+
+	// several hosts are summed
+	res, err = hdl.GetMetrics(ctx, &externalscaler.GetMetricsRequest{
+		ScaledObjectRef: &externalscaler.ScaledObjectRef{
+			ScalerMetadata: map[string]string{"hosts": "a.com,b.com"},
+		},
+	})
+	r.NoError(err)
+	r.Equal(int64(7), res.MetricValues[0].MetricValue)
+}
+
+func TestGetMetricsCountsRoutesSeparately(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+	pinger := newTestQueuePinger(time.Minute)
+	hdl := newImpl(pinger, newMetrics(), time.Minute, time.Millisecond)
+	pinger.updateCounts(map[string]int{
+		"a.com/api":    3,
+		"a.com/admin":  4,
+		"a.com/api/v2": 5,
+		"b.com/api":    6,
+	}, time.Now())
+
+	metricValue := func(routes string) int64 {
+		res, err := hdl.GetMetrics(ctx, &externalscaler.GetMetricsRequest{
+			ScaledObjectRef: &externalscaler.ScaledObjectRef{
+				ScalerMetadata: map[string]string{"routes": routes},
+			},
+		})
+		r.NoError(err)
+		return res.MetricValues[0].MetricValue
+	}
+	// each route on a host is counted on its own, not with the other
+	// routes on the host or the ones under it
+	r.Equal(int64(3), metricValue("a.com/api"))
+	r.Equal(int64(4), metricValue("a.com/admin"))
+	r.Equal(int64(7), metricValue("a.com/api,a.com/admin"))
+	// a route for any host counts the path on every host
+	r.Equal(int64(9), metricValue("*/api"))
+}
@@ -31,6 +31,7 @@ func main() {
 	if err != nil {
 		log.Fatalf("Couldn't get a Kubernetes client (%s)", err)
 	}
+	m := newMetrics()
 	pinger := newQueuePinger(
 		context.Background(),
 		k8sCl,
@@ -38,15 +39,28 @@ func main() {
 		svcName,
 		targetPortStr,
 		time.NewTicker(500*time.Millisecond),
+		cfg.IdleWindow,
+		m,
 	)
+	m.watch(pinger)
 
 	grp, ctx := errgroup.WithContext(ctx)
-	grp.Go(startGrpcServer(ctx, grpcPort, pinger))
-	grp.Go(startHealthcheckServer(ctx, healthPort))
+	grp.Go(startGrpcServer(
+		ctx,
+		grpcPort,
+		newImpl(pinger, m, cfg.IdleWindow, cfg.StreamIsActiveInterval),
+		newQueueReporter(pinger),
+	))
+	grp.Go(startHealthcheckServer(ctx, healthPort, m.handler()))
 	log.Fatalf("One or more of the servers failed: %s", grp.Wait())
 }
 
-func startGrpcServer(ctx context.Context, port int, pinger *queuePinger) func() error {
+func startGrpcServer(
+	ctx context.Context,
+	port int,
+	scalerImpl *impl,
+	reporter *queueReporter,
+) func() error {
 	return func() error {
 		addr := fmt.Sprintf("0.0.0.0:%d", port)
 		log.Printf("Serving external scaler on %s", addr)
@@ -56,7 +70,8 @@ func startGrpcServer(ctx context.Context, port int, pinger *queuePinger) func()
 		}
 
 		grpcServer := grpc.NewServer()
-		externalscaler.RegisterExternalScalerServer(grpcServer, newImpl(pinger))
+		externalscaler.RegisterExternalScalerServer(grpcServer, scalerImpl)
+		externalscaler.RegisterQueueReporterServer(grpcServer, reporter)
 		reflection.Register(grpcServer)
 		go func() {
 			<-ctx.Done()
@@ -66,7 +81,13 @@ func startGrpcServer(ctx context.Context, port int, pinger *queuePinger) func()
 	}
 }
 
-func startHealthcheckServer(ctx context.Context, port int) func() error {
+// startHealthcheckServer returns a function that serves the health
+// checks, and metricsHandler at /metrics, on port
+func startHealthcheckServer(
+	ctx context.Context,
+	port int,
+	metricsHandler http.Handler,
+) func() error {
 	return func() error {
 		mux := http.NewServeMux()
 		mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
@@ -75,6 +96,7 @@ func startHealthcheckServer(ctx context.Context, port int) func() error {
 		mux.HandleFunc("/livez", func(w http.ResponseWriter, r *http.Request) {
 			w.WriteHeader(200)
 		})
+		mux.Handle("/metrics", metricsHandler)
 		srv := &http.Server{
 			Addr:    fmt.Sprintf(":%d", port),
 			Handler: mux,
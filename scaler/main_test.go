@@ -17,7 +17,7 @@ func TestHealthChecks(t *testing.T) {
 	ctx, done := context.WithCancel(context.Background())
 	defer done()
 	errgrp, ctx := errgroup.WithContext(ctx)
-	srvFunc := startHealthcheckServer(ctx, port)
+	srvFunc := startHealthcheckServer(ctx, port, newMetrics().handler())
 	errgrp.Go(srvFunc)
 	time.Sleep(500 * time.Millisecond)
 
@@ -28,4 +28,8 @@ func TestHealthChecks(t *testing.T) {
 	res, err = http.Get(fmt.Sprintf("http://0.0.0.0:%d/livez", port))
 	r.NoError(err)
 	r.Equal(200, res.StatusCode)
+
+	res, err = http.Get(fmt.Sprintf("http://0.0.0.0:%d/metrics", port))
+	r.NoError(err)
+	r.Equal(200, res.StatusCode)
 }
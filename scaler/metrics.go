@@ -0,0 +1,141 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metricsNamespace is the prefix of the names of all the scaler's metrics
+const metricsNamespace = "scaler"
+
+// metrics are the Prometheus metrics that the scaler serves on its health
+// check server. Each metrics has its own registry, so tests can create as
+// many as they like
+type metrics struct {
+	registry     *prometheus.Registry
+	pingDuration prometheus.Histogram
+	pingErrors   *prometheus.CounterVec
+	calls        *prometheus.CounterVec
+}
+
+func newMetrics() *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		pingDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "ping_duration_seconds",
+			Help:      "How long polling an interceptor for its counts took",
+			Buckets:   prometheus.DefBuckets,
+		}),
+		pingErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "ping_errors_total",
+			Help:      "Failures to poll interceptors for their counts, by the step that failed: endpoints, request or decode",
+		}, []string{"step"}),
+		calls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "calls_total",
+			Help:      "Calls from KEDA, by method and result",
+		}, []string{"method", "result"}),
+	}
+	m.registry.MustRegister(
+		m.pingDuration,
+		m.pingErrors,
+		m.calls,
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// watch registers a collector that reports pinger's counts and when it
+// last pinged the interceptors successfully, as of when they're scraped
+func (m *metrics) watch(pinger *queuePinger) {
+	m.registry.MustRegister(newPingerCollector(pinger))
+}
+
+// handler returns a handler that serves m in the Prometheus text format
+func (m *metrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// countCall counts a call to method that had the given result, like
+// active or inactive for IsActive
+func (m *metrics) countCall(method, result string) {
+	m.calls.WithLabelValues(method, result).Inc()
+}
+
+// activeResult returns the result label for an IsActive call that
+// returned active
+func activeResult(active bool) string {
+	if active {
+		return "active"
+	}
+	return "inactive"
+}
+
+// errorResult returns the result label for a call that returned err
+func errorResult(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "success"
+}
+
+// pingerCollector is a prometheus.Collector for the state of a
+// queuePinger
+type pingerCollector struct {
+	pinger          *queuePinger
+	pendingDesc     *prometheus.Desc
+	interceptorDesc *prometheus.Desc
+	lastPingAgeDesc *prometheus.Desc
+}
+
+func newPingerCollector(pinger *queuePinger) *pingerCollector {
+	return &pingerCollector{
+		pinger: pinger,
+		pendingDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(metricsNamespace, "", "pending_requests"),
+			"Pending requests across all interceptors, hosts and routes, as last aggregated",
+			nil,
+			nil,
+		),
+		interceptorDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(metricsNamespace, "", "interceptor_pending_requests"),
+			"Pending requests that each interceptor last reported, across all hosts and routes",
+			[]string{"interceptor"},
+			nil,
+		),
+		lastPingAgeDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(metricsNamespace, "", "last_successful_ping_age_seconds"),
+			"How long ago the interceptors were last polled without errors",
+			nil,
+			nil,
+		),
+	}
+}
+
+func (c *pingerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.pendingDesc
+	ch <- c.interceptorDesc
+	ch <- c.lastPingAgeDesc
+}
+
+func (c *pingerCollector) Collect(ch chan<- prometheus.Metric) {
+	total, perInterceptor, lastPing := c.pinger.stats()
+	ch <- prometheus.MustNewConstMetric(c.pendingDesc, prometheus.GaugeValue, float64(total))
+	for id, count := range perInterceptor {
+		ch <- prometheus.MustNewConstMetric(c.interceptorDesc, prometheus.GaugeValue, float64(count), id)
+	}
+	// there's no age until the first successful ping
+	if !lastPing.IsZero() {
+		ch <- prometheus.MustNewConstMetric(
+			c.lastPingAgeDesc,
+			prometheus.GaugeValue,
+			time.Since(lastPing).Seconds(),
+		)
+	}
+}
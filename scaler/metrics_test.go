@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	externalscaler "github.com/kedacore/http-add-on/proto"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestMetricsCountCalls(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+	pinger := newTestQueuePinger(time.Minute)
+	m := newMetrics()
+	hdl := newImpl(pinger, m, time.Millisecond, time.Hour)
+	ref := &externalscaler.ScaledObjectRef{
+		ScalerMetadata: map[string]string{"host": "myhost.com"},
+	}
+
+	// wait for the idle window to pass
+	time.Sleep(5 * time.Millisecond)
+	_, err := hdl.IsActive(ctx, ref)
+	r.NoError(err)
+	pinger.updateCounts(map[string]int{"myhost.com": 1}, time.Now())
+	_, err = hdl.IsActive(ctx, ref)
+	r.NoError(err)
+	_, err = hdl.GetMetrics(ctx, &externalscaler.GetMetricsRequest{ScaledObjectRef: ref})
+	r.NoError(err)
+	ref.ScalerMetadata["targetPendingRequests"] = "none"
+	_, err = hdl.GetMetricSpec(ctx, ref)
+	r.Error(err)
+
+	r.Equal(1.0, testutil.ToFloat64(m.calls.WithLabelValues("IsActive", "inactive")))
+	r.Equal(1.0, testutil.ToFloat64(m.calls.WithLabelValues("IsActive", "active")))
+	r.Equal(1.0, testutil.ToFloat64(m.calls.WithLabelValues("GetMetrics", "success")))
+	r.Equal(1.0, testutil.ToFloat64(m.calls.WithLabelValues("GetMetricSpec", "error")))
+}
+
+func TestMetricsReportPings(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`{"counts": {"a.com": 2, "b.com/api": 3}}`))
+	}))
+	defer srv.Close()
+	host, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	r.NoError(err)
+
+	const ns, svcName = "testns", "testsvc"
+	k8sCl := fake.NewSimpleClientset(&corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: svcName},
+		Subsets: []corev1.EndpointSubset{
+			{Addresses: []corev1.EndpointAddress{{IP: host}}},
+		},
+	})
+	m := newMetrics()
+	// the ticker never fires, so the test pings by hand
+	ticker := time.NewTicker(time.Hour)
+	pinger := newQueuePinger(ctx, k8sCl, ns, svcName, port, ticker, time.Minute, m)
+	m.watch(pinger)
+
+	// nothing's been pinged yet
+	r.NoError(testutil.CollectAndCompare(newPingerCollector(pinger), strings.NewReader(`
+# HELP scaler_pending_requests Pending requests across all interceptors, hosts and routes, as last aggregated
+# TYPE scaler_pending_requests gauge
+scaler_pending_requests 0
+`)))
+
+	r.NoError(pinger.requestCounts(ctx))
+	r.NoError(testutil.CollectAndCompare(newPingerCollector(pinger), strings.NewReader(`
+# HELP scaler_pending_requests Pending requests across all interceptors, hosts and routes, as last aggregated
+# TYPE scaler_pending_requests gauge
+scaler_pending_requests 5
+# HELP scaler_interceptor_pending_requests Pending requests that each interceptor last reported, across all hosts and routes
+# TYPE scaler_interceptor_pending_requests gauge
+scaler_interceptor_pending_requests{interceptor="`+host+`"} 5
+`), "scaler_pending_requests", "scaler_interceptor_pending_requests"))
+	r.Equal(1, testutil.CollectAndCount(m.pingDuration))
+	_, _, lastPing := pinger.stats()
+	r.False(lastPing.IsZero())
+
+	// a ping that fails doesn't count as successful
+	srv.Close()
+	r.NoError(pinger.requestCounts(ctx))
+	r.Equal(1.0, testutil.ToFloat64(m.pingErrors.WithLabelValues("request")))
+	_, _, lastFailedPing := pinger.stats()
+	r.Equal(lastPing, lastFailedPing)
+
+	// and neither does one that can't find the interceptors
+	r.NoError(k8sCl.CoreV1().Endpoints(ns).Delete(ctx, svcName, metav1.DeleteOptions{}))
+	r.Error(pinger.requestCounts(ctx))
+	r.Equal(1.0, testutil.ToFloat64(m.pingErrors.WithLabelValues("endpoints")))
+}
@@ -7,39 +7,88 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"net/http"
+	nethttp "net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/kedacore/http-add-on/pkg/http"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 )
 
+// queuePinger keeps track of the pending request counts of every
+// interceptor, and the aggregate of those counts.
+//
+// Interceptors can either push their counts to the scaler over a
+// QueueReporter stream, or be polled for them over HTTP. Each interceptor
+// is identified by its pod IP, so the pinger doesn't poll interceptors
+// that are already pushing
 type queuePinger struct {
-	k8sCl        *kubernetes.Clientset
+	k8sCl        kubernetes.Interface
 	ns           string
 	svcName      string
 	adminPort    string
 	pingMut      *sync.RWMutex
 	lastPingTime time.Time
 	lastCount    int
+	// lastCounts holds the counts for each host and route, aggregated
+	// across all interceptors
+	lastCounts map[string]int
+	// interceptorCounts holds the counts for each host and route for each
+	// interceptor, keyed by interceptor ID
+	interceptorCounts map[string]map[string]int
+	// streams holds the number of open QueueReporter streams for each
+	// interceptor ID
+	streams map[string]int
+	// listeners are notified every time the aggregate counts change
+	listeners map[chan struct{}]struct{}
+	// startTime is when this pinger was created. It's treated as the
+	// last active time for hosts that haven't had any requests yet
+	startTime time.Time
+	// lastActive holds the last time that each host and route had a
+	// non-zero count
+	lastActive map[string]time.Time
+	// lastActiveAny is the last time that any host or route had a
+	// non-zero count
+	lastActiveAny time.Time
+	// activityRetention is how long to remember the last active time of a
+	// host or route after its count dropped to zero
+	activityRetention time.Duration
+	// lastSuccessfulPing is the last time that every interceptor that
+	// doesn't push its counts was polled for them without errors
+	lastSuccessfulPing time.Time
+	// metrics records how polling the interceptors goes
+	metrics *metrics
 }
 
 func newQueuePinger(
 	ctx context.Context,
-	k8sCl *kubernetes.Clientset,
+	k8sCl kubernetes.Interface,
 	ns,
 	svcName,
 	adminPort string,
 	pingTicker *time.Ticker,
+	activityRetention time.Duration,
+	m *metrics,
 ) *queuePinger {
 	pingMut := new(sync.RWMutex)
+	now := time.Now()
 	pinger := &queuePinger{
-		k8sCl:     k8sCl,
-		ns:        ns,
-		svcName:   svcName,
-		adminPort: adminPort,
-		pingMut:   pingMut,
+		k8sCl:             k8sCl,
+		ns:                ns,
+		svcName:           svcName,
+		adminPort:         adminPort,
+		pingMut:           pingMut,
+		lastCounts:        map[string]int{},
+		interceptorCounts: map[string]map[string]int{},
+		streams:           map[string]int{},
+		listeners:         map[chan struct{}]struct{}{},
+		startTime:         now,
+		lastActive:        map[string]time.Time{},
+		lastActiveAny:     now,
+		activityRetention: activityRetention,
+		metrics:           m,
 	}
 
 	go func() {
@@ -64,58 +113,265 @@ func (q *queuePinger) count() int {
 	return q.lastCount
 }
 
+// countMatching returns the aggregate count for all the keys that match
+// returns true for
+func (q *queuePinger) countMatching(match func(key string) bool) int {
+	q.pingMut.RLock()
+	defer q.pingMut.RUnlock()
+	total := 0
+	for key, count := range q.lastCounts {
+		if match(key) {
+			total += count
+		}
+	}
+	return total
+}
+
+// lastActiveTime returns the last time that any key that match returns
+// true for had pending requests. If match is nil, returns the last time
+// that any key at all had pending requests. If there haven't been any
+// pending requests since this pinger started, returns the time it started
+func (q *queuePinger) lastActiveTime(match func(key string) bool) time.Time {
+	q.pingMut.RLock()
+	defer q.pingMut.RUnlock()
+	if match == nil {
+		return q.lastActiveAny
+	}
+	ret := q.startTime
+	for key, t := range q.lastActive {
+		if match(key) && t.After(ret) {
+			ret = t
+		}
+	}
+	return ret
+}
+
+// stats returns the aggregate count, the total count that each
+// interceptor last reported, keyed by interceptor ID, and the last time
+// that the interceptors were polled without errors
+func (q *queuePinger) stats() (int, map[string]int, time.Time) {
+	q.pingMut.RLock()
+	defer q.pingMut.RUnlock()
+	perInterceptor := make(map[string]int, len(q.interceptorCounts))
+	for id, counts := range q.interceptorCounts {
+		perInterceptor[id] = http.Total(counts)
+	}
+	return q.lastCount, perInterceptor, q.lastSuccessfulPing
+}
+
+// isStreaming returns whether the interceptor with the given ID is
+// pushing its counts over a QueueReporter stream
+func (q *queuePinger) isStreaming(id string) bool {
+	q.pingMut.RLock()
+	defer q.pingMut.RUnlock()
+	return q.streams[id] > 0
+}
+
 func (q *queuePinger) requestCounts(ctx context.Context) error {
 	log.Printf("queuePinger.requestCounts")
 	endpointsCl := q.k8sCl.CoreV1().Endpoints(q.ns)
 	endpoints, err := endpointsCl.Get(ctx, q.svcName, metav1.GetOptions{})
 	if err != nil {
+		q.metrics.pingErrors.WithLabelValues("endpoints").Inc()
 		return err
 	}
 
-	queueSizeCh := make(chan int)
+	type interceptorCounts struct {
+		addr   string
+		counts map[string]int
+	}
+	queueCountsCh := make(chan interceptorCounts)
 	var wg sync.WaitGroup
+	liveAddrs := map[string]bool{}
+	// pingErrs counts the interceptors that couldn't be polled
+	var pingErrs int32
 
 	for _, subset := range endpoints.Subsets {
 		for _, addr := range subset.Addresses {
+			liveAddrs[addr.IP] = true
+			if q.isStreaming(addr.IP) {
+				// this interceptor pushes its counts, so there's no need
+				// to ask it for them
+				continue
+			}
 			wg.Add(1)
 			go func(addr string) {
 				defer wg.Done()
-				completeAddr := fmt.Sprintf("http://%s:%s/queue", addr, q.adminPort)
-				resp, err := http.Get(completeAddr)
+				completeAddr := fmt.Sprintf("http://%s:%s/queue_counts", addr, q.adminPort)
+				start := time.Now()
+				resp, err := nethttp.Get(completeAddr)
 				if err != nil {
 					log.Printf("Error in pinger with GET %s (%s)", completeAddr, err)
+					q.metrics.pingErrors.WithLabelValues("request").Inc()
+					atomic.AddInt32(&pingErrs, 1)
 					return
 				}
 				defer resp.Body.Close()
-				respData := map[string]int{}
+				respData := map[string]map[string]int{}
 				if err := json.NewDecoder(resp.Body).Decode(&respData); err != nil {
 					log.Printf("Error decoding request to %s (%s)", completeAddr, err)
+					q.metrics.pingErrors.WithLabelValues("decode").Inc()
+					atomic.AddInt32(&pingErrs, 1)
 					return
 				}
-				curSize := respData["current_size"]
-				log.Printf("\n--\ncurSize for address %s: %d\n--\n", addr, curSize)
-				queueSizeCh <- curSize
-				log.Printf("Sent curSize %d for address %s", curSize, addr)
+				q.metrics.pingDuration.Observe(time.Since(start).Seconds())
+				counts := respData["counts"]
+				log.Printf("\n--\ncounts for address %s: %v\n--\n", addr, counts)
+				queueCountsCh <- interceptorCounts{addr: addr, counts: counts}
+				log.Printf("Sent counts %v for address %s", counts, addr)
 			}(addr.IP)
 		}
 	}
 
 	go func() {
 		wg.Wait()
-		close(queueSizeCh)
+		close(queueCountsCh)
 	}()
 
-	total := 0
-	for count := range queueSizeCh {
-		total += count
+	polled := map[string]map[string]int{}
+	for res := range queueCountsCh {
+		polled[res.addr] = res.counts
 	}
 
 	q.pingMut.Lock()
 	defer q.pingMut.Unlock()
-	q.lastCount = total
-	q.lastPingTime = time.Now()
+	for addr, counts := range polled {
+		// the interceptor may have started pushing its counts while we
+		// were polling it, in which case the pushed counts are newer
+		if q.streams[addr] == 0 {
+			q.interceptorCounts[addr] = counts
+		}
+	}
+	// forget about interceptors that have gone away
+	for id := range q.interceptorCounts {
+		if !liveAddrs[id] && q.streams[id] == 0 {
+			delete(q.interceptorCounts, id)
+		}
+	}
+	now := time.Now()
+	q.aggregateLocked(now)
+	if pingErrs == 0 {
+		q.lastSuccessfulPing = now
+	}
 	log.Printf("Finished getting aggregate current size %d", q.lastCount)
 
 	return nil
+}
 
+// startStream records that the interceptor with the given ID opened a
+// QueueReporter stream. While it has one open, it won't be polled
+func (q *queuePinger) startStream(id string) {
+	q.pingMut.Lock()
+	defer q.pingMut.Unlock()
+	q.streams[id]++
+}
+
+// endStream records that a QueueReporter stream from the interceptor
+// with the given ID ended. Once it has no streams left, its counts are
+// forgotten until it's polled or it opens a new stream
+func (q *queuePinger) endStream(id string) {
+	q.pingMut.Lock()
+	defer q.pingMut.Unlock()
+	q.streams[id]--
+	if q.streams[id] > 0 {
+		return
+	}
+	delete(q.streams, id)
+	delete(q.interceptorCounts, id)
+	q.aggregateLocked(time.Now())
+}
+
+// applyPushedCounts updates the counts for the interceptor with the given
+// ID with counts that it pushed at now. If snapshot is true, counts
+// replaces all the interceptor's counts. Otherwise, counts holds the
+// changes to its counts.
+//
+// A batch of changes can hold a request's decrement before its increment,
+// so a count can go negative for a while. It's only forgotten once it's
+// back to exactly 0, like in a MemoryQueue
+func (q *queuePinger) applyPushedCounts(
+	id string,
+	counts map[string]int,
+	snapshot bool,
+	now time.Time,
+) {
+	q.pingMut.Lock()
+	defer q.pingMut.Unlock()
+	existing, ok := q.interceptorCounts[id]
+	if snapshot || !ok {
+		existing = map[string]int{}
+		q.interceptorCounts[id] = existing
+	}
+	for key, count := range counts {
+		existing[key] += count
+		if existing[key] == 0 {
+			delete(existing, key)
+		}
+	}
+	q.aggregateLocked(now)
+}
+
+// aggregateLocked adds up the counts from all the interceptors and
+// records the result as the latest aggregate counts. Callers must
+// hold q.pingMut for writing
+func (q *queuePinger) aggregateLocked(now time.Time) {
+	aggCounts := map[string]int{}
+	for _, counts := range q.interceptorCounts {
+		for key, count := range counts {
+			aggCounts[key] += count
+		}
+	}
+	q.updateCountsLocked(aggCounts, now)
+}
+
+// subscribe returns a channel that receives a value every time the
+// aggregate counts change, and a function to stop receiving them. The
+// channel is buffered, so multiple changes that happen while the
+// receiver is busy are only signaled once
+func (q *queuePinger) subscribe() (<-chan struct{}, func()) {
+	q.pingMut.Lock()
+	defer q.pingMut.Unlock()
+	ch := make(chan struct{}, 1)
+	q.listeners[ch] = struct{}{}
+	return ch, func() {
+		q.pingMut.Lock()
+		defer q.pingMut.Unlock()
+		delete(q.listeners, ch)
+	}
+}
+
+// updateCounts records counts as the latest aggregate counts, fetched
+// at now, and updates the last active times for every host and route
+// with a non-zero count
+func (q *queuePinger) updateCounts(counts map[string]int, now time.Time) {
+	q.pingMut.Lock()
+	defer q.pingMut.Unlock()
+	q.updateCountsLocked(counts, now)
+}
+
+// updateCountsLocked is updateCounts for callers that already hold
+// q.pingMut for writing
+func (q *queuePinger) updateCountsLocked(counts map[string]int, now time.Time) {
+	q.lastCounts = counts
+	q.lastCount = http.Total(counts)
+	q.lastPingTime = now
+	for key, count := range counts {
+		if count > 0 {
+			q.lastActive[key] = now
+			q.lastActiveAny = now
+		}
+	}
+	// forget about hosts and routes that have been idle for long enough
+	// that nobody cares when they were last active
+	for key, t := range q.lastActive {
+		if now.Sub(t) > q.activityRetention {
+			delete(q.lastActive, key)
+		}
+	}
+	for ch := range q.listeners {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
 }
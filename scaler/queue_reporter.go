@@ -0,0 +1,69 @@
+// This file contains the implementation of the QueueReporter gRPC service,
+// which interceptors use to push their pending request counts to this
+// scaler
+package main
+
+import (
+	"io"
+	"time"
+
+	externalscaler "github.com/kedacore/http-add-on/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type queueReporter struct {
+	pinger *queuePinger
+	externalscaler.UnimplementedQueueReporterServer
+}
+
+func newQueueReporter(pinger *queuePinger) *queueReporter {
+	return &queueReporter{pinger: pinger}
+}
+
+func (r *queueReporter) ReportCounts(
+	stream externalscaler.QueueReporter_ReportCountsServer,
+) error {
+	// the ID of the interceptor on the other end of the stream. it's set
+	// from the first update, which must be a snapshot
+	id := ""
+	defer func() {
+		if id != "" {
+			r.pinger.endStream(id)
+		}
+	}()
+	for {
+		update, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(&externalscaler.ReportCountsResponse{})
+		}
+		if err != nil {
+			return err
+		}
+		if id == "" {
+			if update.InterceptorID == "" {
+				return status.Error(codes.InvalidArgument, "missing interceptor ID")
+			}
+			if !update.Snapshot {
+				return status.Error(
+					codes.InvalidArgument,
+					"the first update on a stream must be a snapshot",
+				)
+			}
+			id = update.InterceptorID
+			r.pinger.startStream(id)
+		} else if update.InterceptorID != id {
+			return status.Errorf(
+				codes.InvalidArgument,
+				"interceptor ID changed from %s to %s",
+				id,
+				update.InterceptorID,
+			)
+		}
+		counts := make(map[string]int, len(update.Counts))
+		for key, count := range update.Counts {
+			counts[key] = int(count)
+		}
+		r.pinger.applyPushedCounts(id, counts, update.Snapshot, time.Now())
+	}
+}
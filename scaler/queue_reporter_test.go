@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	externalscaler "github.com/kedacore/http-add-on/proto"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// startTestQueueReporter serves a queueReporter for pinger over an
+// in-memory connection and returns a client for it
+func startTestQueueReporter(
+	ctx context.Context,
+	t *testing.T,
+	pinger *queuePinger,
+) externalscaler.QueueReporterClient {
+	t.Helper()
+	lis := bufconn.Listen(1024 * 1024)
+	srv := grpc.NewServer()
+	externalscaler.RegisterQueueReporterServer(srv, newQueueReporter(pinger))
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	conn, err := grpc.DialContext(
+		ctx,
+		"bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return lis.Dial()
+		}),
+		grpc.WithInsecure(),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return externalscaler.NewQueueReporterClient(conn)
+}
+
+func TestReportCounts(t *testing.T) {
+	r := require.New(t)
+	ctx, done := context.WithCancel(context.Background())
+	defer done()
+	pinger := newTestQueuePinger(time.Minute)
+	cl := startTestQueueReporter(ctx, t, pinger)
+
+	stream, err := cl.ReportCounts(ctx)
+	r.NoError(err)
+	r.NoError(stream.Send(&externalscaler.QueueCountsUpdate{
+		InterceptorID: "1.2.3.4",
+		Counts:        map[string]int64{"a.com": 2, "b.com": 1},
+		Snapshot:      true,
+	}))
+	r.Eventually(func() bool {
+		return pinger.count() == 3
+	}, time.Second, 5*time.Millisecond)
+	r.True(pinger.isStreaming("1.2.3.4"))
+
+	r.NoError(stream.Send(&externalscaler.QueueCountsUpdate{
+		InterceptorID: "1.2.3.4",
+		Counts:        map[string]int64{"a.com": -2, "c.com": 4},
+	}))
+	r.Eventually(func() bool {
+		return pinger.count() == 5
+	}, time.Second, 5*time.Millisecond)
+	r.Equal(0, countForHost(pinger, "a.com"))
+	r.Equal(1, countForHost(pinger, "b.com"))
+	r.Equal(4, countForHost(pinger, "c.com"))
+
+	// once the stream is closed, the interceptor's counts are forgotten
+	_, err = stream.CloseAndRecv()
+	r.NoError(err)
+	r.Eventually(func() bool {
+		return pinger.count() == 0
+	}, time.Second, 5*time.Millisecond)
+	r.False(pinger.isStreaming("1.2.3.4"))
+}
+
+func TestReportCountsOutOfOrderChanges(t *testing.T) {
+	r := require.New(t)
+	ctx, done := context.WithCancel(context.Background())
+	defer done()
+	pinger := newTestQueuePinger(time.Minute)
+	cl := startTestQueueReporter(ctx, t, pinger)
+
+	stream, err := cl.ReportCounts(ctx)
+	r.NoError(err)
+	r.NoError(stream.Send(&externalscaler.QueueCountsUpdate{
+		InterceptorID: "1.2.3.4",
+		Counts:        map[string]int64{},
+		Snapshot:      true,
+	}))
+	// a request's decrement can arrive before its increment. the
+	// negative count has to be kept, so that the increment cancels it out
+	r.NoError(stream.Send(&externalscaler.QueueCountsUpdate{
+		InterceptorID: "1.2.3.4",
+		Counts:        map[string]int64{"a.com": -1},
+	}))
+	r.NoError(stream.Send(&externalscaler.QueueCountsUpdate{
+		InterceptorID: "1.2.3.4",
+		Counts:        map[string]int64{"a.com": 1, "b.com": 1},
+	}))
+	r.Eventually(func() bool {
+		return countForHost(pinger, "b.com") == 1
+	}, time.Second, 5*time.Millisecond)
+	r.Equal(0, countForHost(pinger, "a.com"))
+	r.Equal(1, pinger.count())
+}
+
+func TestReportCountsRequiresSnapshot(t *testing.T) {
+	r := require.New(t)
+	ctx, done := context.WithCancel(context.Background())
+	defer done()
+	pinger := newTestQueuePinger(time.Minute)
+	cl := startTestQueueReporter(ctx, t, pinger)
+
+	stream, err := cl.ReportCounts(ctx)
+	r.NoError(err)
+	r.NoError(stream.Send(&externalscaler.QueueCountsUpdate{
+		InterceptorID: "1.2.3.4",
+		Counts:        map[string]int64{"a.com": 1},
+	}))
+	_, err = stream.CloseAndRecv()
+	r.Error(err)
+	r.Equal(0, pinger.count())
+}
+
+func TestReportCountsSignalsStreamIsActive(t *testing.T) {
+	r := require.New(t)
+	const host = "myhost.com"
+	ctx, done := context.WithCancel(context.Background())
+	defer done()
+	// a short idle window and retention, so the app starts out idle
+	pinger := newTestQueuePinger(time.Millisecond)
+	pinger.startTime = time.Now().Add(-time.Hour)
+	pinger.lastActiveAny = pinger.startTime
+	// a very long stream interval, so StreamIsActive can only find out
+	// about new requests from the pushed counts
+	hdl := newImpl(pinger, newMetrics(), time.Millisecond, time.Hour)
+	cl := startTestQueueReporter(ctx, t, pinger)
+
+	srv := &fakeStreamIsActiveServer{
+		ctx:    ctx,
+		sentCh: make(chan bool, 100),
+	}
+	go hdl.StreamIsActive(&externalscaler.ScaledObjectRef{
+		ScalerMetadata: map[string]string{"host": host},
+	}, srv)
+	r.False(<-srv.sentCh)
+
+	stream, err := cl.ReportCounts(ctx)
+	r.NoError(err)
+	r.NoError(stream.Send(&externalscaler.QueueCountsUpdate{
+		InterceptorID: "1.2.3.4",
+		Counts:        map[string]int64{},
+		Snapshot:      true,
+	}))
+	r.NoError(stream.Send(&externalscaler.QueueCountsUpdate{
+		InterceptorID: "1.2.3.4",
+		Counts:        map[string]int64{host: 1},
+	}))
+	select {
+	case active := <-srv.sentCh:
+		r.True(active)
+	case <-time.After(time.Second):
+		r.Fail("StreamIsActive didn't signal the first request")
+	}
+}